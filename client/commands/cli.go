@@ -0,0 +1,357 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/jahnu05/Assignment-2/P-3/paymentclient"
+)
+
+// Exit codes. A failed gateway call exits with exitGRPCBase plus its gRPC code, e.g. 15
+// for NotFound, 17 for PermissionDenied and 24 for Unavailable.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitGRPCBase = 10
+)
+
+// Command is a client subcommand.
+type Command struct {
+	Name    string
+	Args    string // positional arguments, for the usage line
+	Summary string
+	// Setup defines the command's flags on fs and returns the function that runs it
+	// with the remaining positional arguments.
+	Setup func(fs *flag.FlagSet) func(e *Env, args []string) error
+}
+
+// usageError is a command line mistake. It exits with exitUsage.
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, a ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, a...)}
+}
+
+// requireFlags fails unless every named flag was given.
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var missing []string
+	for _, name := range names {
+		if !set[name] {
+			missing = append(missing, "--"+name)
+		}
+	}
+	if len(missing) > 0 {
+		return usagef("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ExitCode maps the error of a command to the process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+	if s, ok := status.FromError(err); ok {
+		return exitGRPCBase + int(s.Code())
+	}
+	return exitError
+}
+
+// Options are the settings shared by every command. Each can be given as a flag before
+// or after the command name or taken from the profile file.
+type Options struct {
+	Config   string `json:"-"`
+	Profile  string `json:"-"`
+	Gateway  string `json:"gateway,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Cert     string `json:"cert,omitempty"`
+	Key      string `json:"key,omitempty"`
+	CA       string `json:"ca,omitempty"`
+	Output   string `json:"output,omitempty"`
+}
+
+// defaultOptions apply when neither a flag nor the profile sets a value.
+var defaultOptions = Options{
+	Gateway: "localhost:50051",
+	Cert:    "certs/client.crt",
+	Key:     "certs/client.key",
+	CA:      "certs/ca.crt",
+	Output:  "table",
+}
+
+// register defines the global flags on fs, keeping the values already parsed.
+func (o *Options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.Config, "config", o.Config, "Profile file (default $PAYMENT_CLIENT_CONFIG or ~/.payment_client.json)")
+	fs.StringVar(&o.Profile, "profile", o.Profile, "Profile to use instead of the file's current profile")
+	fs.StringVar(&o.Gateway, "gateway", o.Gateway, "Payment Gateway address (default "+defaultOptions.Gateway+")")
+	fs.StringVar(&o.User, "user", o.User, "User to authenticate as")
+	fs.StringVar(&o.Password, "password", o.Password, "Password of --user (default $PAYMENT_CLIENT_PASSWORD)")
+	fs.StringVar(&o.Password, "senderPass", o.Password, "Same as --password")
+	fs.StringVar(&o.Cert, "cert", o.Cert, "Client certificate file (default "+defaultOptions.Cert+")")
+	fs.StringVar(&o.Key, "key", o.Key, "Client private key file (default "+defaultOptions.Key+")")
+	fs.StringVar(&o.CA, "ca", o.CA, "CA certificate file (default "+defaultOptions.CA+")")
+	fs.StringVar(&o.Output, "output", o.Output, "Output format: table, json or yaml (default table)")
+	fs.StringVar(&o.Output, "o", o.Output, "Same as --output")
+}
+
+// globalFlags are the names defined by register, for completion.
+var globalFlags = []string{"config", "profile", "gateway", "user", "password", "senderPass", "cert", "key", "ca", "output"}
+
+// merge fills the fields of o that are empty from other.
+func (o *Options) merge(other Options) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&o.Gateway, other.Gateway)
+	fill(&o.User, other.User)
+	fill(&o.Password, other.Password)
+	fill(&o.Cert, other.Cert)
+	fill(&o.Key, other.Key)
+	fill(&o.CA, other.CA)
+	fill(&o.Output, other.Output)
+}
+
+// resolve completes o from the environment, the profile file and the defaults.
+func (o *Options) resolve() error {
+	if o.Password == "" {
+		o.Password = os.Getenv("PAYMENT_CLIENT_PASSWORD")
+	}
+	profiles, err := loadProfiles(o.configFile())
+	if err != nil {
+		return err
+	}
+	name := o.Profile
+	if name == "" {
+		name = profiles.Current
+	}
+	if name != "" {
+		p, ok := profiles.Profiles[name]
+		if !ok && o.Profile != "" {
+			return usagef("profile %q not found in %s", name, o.configFile())
+		}
+		o.merge(p)
+	}
+	o.merge(defaultOptions)
+	switch o.Output {
+	case "table", "json", "yaml":
+	default:
+		return usagef("unknown output format %q, expected table, json or yaml", o.Output)
+	}
+	return nil
+}
+
+// Env is what a command runs with: the resolved options, the output and a gateway
+// connection opened on first use.
+type Env struct {
+	Options
+	Out io.Writer
+	// explicit holds the options as given on the command line, before resolve.
+	explicit Options
+	client   *paymentclient.Client
+	ctx      context.Context
+}
+
+// Context returns the context for gateway calls. In the shell it is canceled by Ctrl-C.
+func (e *Env) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// base returns the connection, opening it on first use.
+func (e *Env) base() (*paymentclient.Client, error) {
+	if e.client == nil {
+		client, err := paymentclient.New(e.Gateway, paymentclient.WithTLSFiles(e.Cert, e.Key, e.CA))
+		if err != nil {
+			return nil, err
+		}
+		e.client = client
+	}
+	return e.client, nil
+}
+
+// Client returns a client that authenticates as the current user.
+func (e *Env) Client() (*paymentclient.Client, error) {
+	if e.User == "" {
+		return nil, usagef("no user given; use --user or set one in the profile")
+	}
+	client, err := e.base()
+	if err != nil {
+		return nil, err
+	}
+	return client.As(e.User, e.Password), nil
+}
+
+// Close closes the connection, if one was opened.
+func (e *Env) Close() {
+	if e.client != nil {
+		e.client.Close()
+		e.client = nil
+	}
+}
+
+// findCommand returns the command called name, or nil.
+func findCommand(name string) *Command {
+	for _, cmd := range commandList {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// programName is the name the client was started as, for usage and completion.
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// commandFlags parses the arguments of cmd, including the global flags, into opts and
+// returns the function to run.
+func commandFlags(cmd *Command, opts *Options, args []string) (func(e *Env, args []string) error, []string, error) {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	run := cmd.Setup(fs)
+	opts.register(fs)
+	// Flags may come before, between or after the positional arguments.
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				printCommandUsage(os.Stdout, cmd)
+				return nil, nil, nil
+			}
+			return nil, nil, usagef("%s: %v", cmd.Name, err)
+		}
+		if fs.NArg() == 0 {
+			return run, positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseCommand finds the command named by the first word of line and parses the rest of
+// line into opts. run is nil when only help was asked for.
+func parseCommand(line []string, opts *Options) (cmd *Command, run func(e *Env, args []string) error, args []string, err error) {
+	if len(line) == 0 {
+		return nil, nil, nil, usagef("no command given")
+	}
+	cmd = findCommand(line[0])
+	if cmd == nil {
+		return nil, nil, nil, usagef("unknown command %q; run %s help for the list of commands", line[0], programName())
+	}
+	run, args, err = commandFlags(cmd, opts, line[1:])
+	return cmd, run, args, err
+}
+
+// runCommand runs one command line (without the program name) in e.
+func runCommand(e *Env, line []string) error {
+	_, run, args, err := parseCommand(line, &e.Options)
+	if err != nil || run == nil {
+		return err
+	}
+	e.explicit = e.Options
+	if err := e.Options.resolve(); err != nil {
+		return err
+	}
+	return run(e, args)
+}
+
+// Main runs the client with the command line arguments and returns the exit code.
+func Main(args []string) int {
+	log.SetFlags(0)
+	var opts Options
+	fs := flag.NewFlagSet(programName(), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			PrintUsage()
+			return exitOK
+		}
+		log.Printf("Error: %v", err)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		PrintUsage()
+		return exitUsage
+	}
+
+	e := &Env{Options: opts, Out: os.Stdout}
+	defer e.Close()
+	err := runCommand(e, fs.Args())
+	if err != nil {
+		log.Printf("Error: %v", err)
+		if _, ok := err.(*usageError); ok {
+			if cmd := findCommand(fs.Arg(0)); cmd != nil {
+				printCommandUsage(os.Stderr, cmd)
+			}
+		}
+	}
+	return ExitCode(err)
+}
+
+// PrintUsage prints the usage instructions for the client.
+func PrintUsage() {
+	fmt.Printf("Usage: %s [global flags] <command> [flags] [arguments]\n\nCommands:\n", programName())
+	names := make([]string, 0, len(commandList))
+	width := 0
+	for _, cmd := range commandList {
+		names = append(names, cmd.Name)
+		if len(cmd.Name) > width {
+			width = len(cmd.Name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-*s  %s\n", width, name, findCommand(name).Summary)
+	}
+	fmt.Printf("\nGlobal flags:\n")
+	var opts Options
+	fs := flag.NewFlagSet("global", flag.ContinueOnError)
+	opts.register(fs)
+	fs.SetOutput(os.Stdout)
+	fs.PrintDefaults()
+	fmt.Printf("\nRun %s help <command> for the flags of a command.\n", programName())
+}
+
+// printCommandUsage prints the usage line and flags of cmd.
+func printCommandUsage(w io.Writer, cmd *Command) {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	cmd.Setup(fs)
+	usage := fmt.Sprintf("%s %s [flags]", programName(), cmd.Name)
+	if cmd.Args != "" {
+		usage += " " + cmd.Args
+	}
+	fmt.Fprintf(w, "Usage: %s\n%s\n", usage, cmd.Summary)
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
@@ -0,0 +1,1474 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// commandList holds every client command. It is filled in init because help and
+// completion refer back to it.
+var commandList []*Command
+
+func init() {
+	commandList = []*Command{
+		{Name: "register", Summary: "Register --user with --password at a bank", Setup: registerCommand},
+		{Name: "pay", Summary: "Pay another user, queuing the payment offline if the gateway is unreachable", Setup: payCommand},
+		{Name: "getbalance", Summary: "Show the balance of --user", Setup: getBalanceCommand},
+		{Name: "gethistory", Summary: "Show the transaction history of --user", Setup: getHistoryCommand},
+		{Name: "unregister", Summary: "Unregister --user", Setup: unregisterCommand},
+		{Name: "settlement", Summary: "Show the net inter-bank positions (operators)", Setup: settlementCommand},
+		{Name: "settle", Summary: "Post the net inter-bank positions to the banks (operators)", Setup: settleCommand},
+		{Name: "reloadfx", Summary: "Reload the gateway's FX rates (operators)", Setup: reloadFXCommand},
+		{Name: "refund", Summary: "Refund all or part of a committed payment", Setup: refundCommand},
+		{Name: "schedule", Summary: "Schedule a one-off or recurring payment", Setup: scheduleCommand},
+		{Name: "schedules", Summary: "List the scheduled payments of --user", Setup: listSchedulesCommand},
+		{Name: "pauseschedule", Summary: "Pause a scheduled payment", Setup: changeScheduleCommand},
+		{Name: "resumeschedule", Summary: "Resume a paused scheduled payment", Setup: changeScheduleCommand},
+		{Name: "cancelschedule", Summary: "Cancel a scheduled payment", Setup: changeScheduleCommand},
+		{Name: "requestpay", Summary: "Request a payment from another user", Setup: requestPaymentCommand},
+		{Name: "paymentrequests", Summary: "List payment requests sent or received by --user", Setup: listPaymentRequestsCommand},
+		{Name: "approverequest", Summary: "Approve and pay a payment request", Setup: changePaymentRequestCommand},
+		{Name: "declinerequest", Summary: "Decline a payment request", Setup: changePaymentRequestCommand},
+		{Name: "cancelrequest", Summary: "Cancel a payment request you sent", Setup: changePaymentRequestCommand},
+		{Name: "escrow", Summary: "Hold a payment in escrow until released, refunded or its deadline", Setup: createEscrowCommand},
+		{Name: "escrows", Summary: "List the escrows of --user", Setup: listEscrowsCommand},
+		{Name: "releaseescrow", Summary: "Release an escrow to its receiver", Setup: settleEscrowCommand},
+		{Name: "refundescrow", Summary: "Refund an escrow to its sender", Setup: settleEscrowCommand},
+		{Name: "splitpay", Args: "receiver[@bank]=amount...", Summary: "Pay several receivers at once, all or nothing", Setup: multiPaymentCommand},
+		{Name: "poolpay", Args: "sender[@bank]=amount...", Summary: "Collect from several senders into one receiver (operators)", Setup: multiPaymentCommand},
+		{Name: "batch", Summary: "Submit a CSV or JSONL file of payments and follow it to completion", Setup: submitBatchCommand},
+		{Name: "batchstatus", Summary: "Show the status of a payment batch", Setup: getBatchCommand},
+		{Name: "setlimit", Summary: "Set the spending limit of a user or role (operators)", Setup: setSpendingLimitCommand},
+		{Name: "removelimit", Summary: "Remove the spending limit of a user or role (operators)", Setup: setSpendingLimitCommand},
+		{Name: "setrole", Summary: "Assign a user to a spending limit role (operators)", Setup: setUserRoleCommand},
+		{Name: "headroom", Summary: "Show what is left of a user's spending limits", Setup: headroomCommand},
+		{Name: "reviews", Summary: "List payments held for risk review (operators)", Setup: listReviewsCommand},
+		{Name: "approvereview", Summary: "Approve and execute a held payment (operators)", Setup: decideReviewCommand},
+		{Name: "rejectreview", Summary: "Reject a held payment (operators)", Setup: decideReviewCommand},
+		{Name: "dispute", Summary: "Dispute a committed payment", Setup: openDisputeCommand},
+		{Name: "disputes", Summary: "List disputes", Setup: listDisputesCommand},
+		{Name: "investigatedispute", Summary: "Start investigating a dispute (operators)", Setup: changeDisputeCommand},
+		{Name: "acceptdispute", Summary: "Accept a dispute and charge the payment back (operators)", Setup: changeDisputeCommand},
+		{Name: "rejectdispute", Summary: "Reject a dispute (operators)", Setup: changeDisputeCommand},
+		{Name: "createkey", Summary: "Create an API key for merchant --user", Setup: createApiKeyCommand},
+		{Name: "apikeys", Summary: "List the API keys of merchant --user", Setup: listApiKeysCommand},
+		{Name: "rotatekey", Summary: "Replace the secret of an API key", Setup: changeApiKeyCommand},
+		{Name: "revokekey", Summary: "Revoke an API key", Setup: changeApiKeyCommand},
+		{Name: "createinvoice", Args: "description:quantity:unit_price...", Summary: "Create an invoice from line items", Setup: createInvoiceCommand},
+		{Name: "invoice", Summary: "Show an invoice", Setup: getInvoiceCommand},
+		{Name: "invoices", Summary: "List the invoices of merchant --user", Setup: listInvoicesCommand},
+		{Name: "payinvoice", Summary: "Pay an invoice by its reference", Setup: payInvoiceCommand},
+		{Name: "webhook", Summary: "Register a webhook endpoint", Setup: registerWebhookCommand},
+		{Name: "webhooks", Summary: "List the webhook endpoints of --user", Setup: listWebhooksCommand},
+		{Name: "deletewebhook", Summary: "Delete a webhook endpoint", Setup: deleteWebhookCommand},
+		{Name: "deliveries", Summary: "List undelivered webhook events", Setup: listDeliveriesCommand},
+		{Name: "redeliver", Summary: "Queue a dead webhook delivery again", Setup: redeliverCommand},
+		{Name: "webhookreceiver", Summary: "Receive and verify webhook deliveries locally", Setup: webhookReceiverCommand},
+		{Name: "events", Summary: "Stream payment and balance events, resuming after interruptions", Setup: eventsCommand},
+		{Name: "submitpay", Summary: "Submit a payment for asynchronous execution", Setup: submitPaymentCommand},
+		{Name: "paymentstatus", Summary: "Show or wait for the status of an asynchronous payment", Setup: paymentStatusCommand},
+		{Name: "queuedpayments", Summary: "List payments the gateway queued for an unavailable bank", Setup: queuedPaymentsCommand},
+		{Name: "queue", Args: "list|retry|drop", Summary: "Manage the client's offline payment queue", Setup: offlineQueueCommand},
+		{Name: "shell", Summary: "Run commands interactively over one connection, with history and completion", Setup: shellCommand},
+		{Name: "profile", Args: "list|show|set|use", Summary: "Manage the profiles in the profile file", Setup: profileCommand},
+		{Name: "completion", Args: "bash|zsh|fish", Summary: "Print a shell completion script", Setup: completionCommand},
+		{Name: "help", Args: "[command]", Summary: "Show the commands or the flags of one command", Setup: helpCommand},
+	}
+}
+
+func helpCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if len(args) == 0 {
+			PrintUsage()
+			return nil
+		}
+		cmd := findCommand(args[0])
+		if cmd == nil {
+			return usagef("unknown command %q", args[0])
+		}
+		printCommandUsage(e.Out, cmd)
+		return nil
+	}
+}
+
+// noArgs fails when a command that takes only flags gets positional arguments.
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return usagef("unexpected argument %q", args[0])
+	}
+	return nil
+}
+
+// paymentArgs are the flags of the commands that pay another user.
+type paymentArgs struct {
+	senderBank, receiverBank, receiver, currency *string
+	amount                                       *float64
+}
+
+func paymentFlags(fs *flag.FlagSet) *paymentArgs {
+	return &paymentArgs{
+		senderBank:   fs.String("sender_bank", "", "Address of the sender's bank server"),
+		receiverBank: fs.String("receiver_bank", "", "Address of the receiver's bank server"),
+		receiver:     fs.String("receiver", "", "User to pay"),
+		amount:       fs.Float64("amount", 0, "Amount in the sender's currency"),
+		currency:     fs.String("currency", "", "Currency of the amount (default the sender account's)"),
+	}
+}
+
+// request builds the payment of --user with a new transaction id and idempotency key.
+func (p *paymentArgs) request(fs *flag.FlagSet, e *Env) (*paymentpb.TransactionRequest, error) {
+	if err := requireFlags(fs, "sender_bank", "receiver_bank", "receiver", "amount"); err != nil {
+		return nil, err
+	}
+	return &paymentpb.TransactionRequest{
+		TransactionId:    fmt.Sprintf("%d", time.Now().UnixNano()),
+		SenderUsername:   e.User,
+		ReceiverUsername: *p.receiver,
+		Amount:           *p.amount,
+		SenderBank:       *p.senderBank,
+		ReceiverBank:     *p.receiverBank,
+		IdempotencyKey:   uuid.New().String(),
+		Currency:         *p.currency,
+		// The gateway keeps the payment until an unavailable bank is back.
+		QueueIfBankDown: true,
+	}, nil
+}
+
+// paymentResult is the output of pay and payinvoice: the gateway's response together
+// with the ids the client generated for the payment.
+type paymentResult struct {
+	TransactionId  string  `json:"transactionId"`
+	IdempotencyKey string  `json:"idempotencyKey"`
+	Success        bool    `json:"success"`
+	Message        string  `json:"message,omitempty"`
+	Fee            float64 `json:"fee"`
+	ReviewId       string  `json:"reviewId,omitempty"`
+	QueueId        string  `json:"queueId,omitempty"`
+	OfflineQueued  bool    `json:"offlineQueued,omitempty"`
+}
+
+func paymentResultOf(req *paymentpb.TransactionRequest, resp *paymentpb.TransactionResponse) paymentResult {
+	return paymentResult{
+		TransactionId:  req.TransactionId,
+		IdempotencyKey: req.IdempotencyKey,
+		Success:        resp.Success,
+		Message:        resp.Message,
+		Fee:            resp.Fee,
+		ReviewId:       resp.ReviewId,
+		QueueId:        resp.QueueId,
+	}
+}
+
+func registerCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	bank := fs.String("bank", "", "Address of the user's bank server")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "bank"); err != nil {
+			return err
+		}
+		if e.User == "" || e.Password == "" {
+			return usagef("register needs --user and --password")
+		}
+		// Registration is not authenticated, so the connection without credentials is used.
+		client, err := e.base()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Register(e.Context(), &paymentpb.RegisterRequest{
+			Username: e.User,
+			Password: e.Password,
+			BankName: *bank,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func payCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	p := paymentFlags(fs)
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		txReq, err := p.request(fs, e)
+		if err != nil {
+			return err
+		}
+
+		resp, err := sendPayment(client, txReq)
+		if detail := limitExceeded(err); detail != nil {
+			// Retrying would break the same limit, so the payment is not queued.
+			log.Printf("Payment rejected: %s limit of %.2f exceeded (used %.2f, remaining %.2f, resets %s)",
+				detail.Limit, detail.LimitValue, detail.Used, detail.Remaining, detail.ResetsAt)
+			return err
+		}
+		if err != nil && !retryable(err) {
+			// Rejected by the gateway, e.g. by risk screening; retrying would not help.
+			return err
+		}
+		if err != nil {
+			log.Printf("Payment failed; added to offline queue: %v", err)
+			if err := queuePayment(txReq, err); err != nil {
+				return fmt.Errorf("cannot queue payment: %w", err)
+			}
+			return e.Print(paymentResult{
+				TransactionId:  txReq.TransactionId,
+				IdempotencyKey: txReq.IdempotencyKey,
+				Message:        "Queued for retry: " + status.Convert(err).Message(),
+				OfflineQueued:  true,
+			})
+		}
+
+		// The gateway is reachable, so send any queued payments that are due.
+		retryDuePayments(client)
+		return e.Print(paymentResultOf(txReq, resp))
+	}
+}
+
+func getBalanceCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.GetBalance(e.Context(), &paymentpb.BalanceRequest{Username: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func getHistoryCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.GetTransactionHistory(e.Context(), &paymentpb.HistoryRequest{Username: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func unregisterCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.Unregister(e.Context(), &paymentpb.UnregisterRequest{Username: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// settlementCommand prints the net amounts owed between banks from the latest netting run.
+func settlementCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.GetSettlementReport(e.Context(), &paymentpb.SettlementReportRequest{})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// settleCommand posts the current net inter-bank positions to the banks' settlement accounts.
+func settleCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.SettlePositions(e.Context(), &paymentpb.SettlePositionsRequest{})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// reloadFXCommand asks the gateway to re-read its FX rate table.
+func reloadFXCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ReloadFXRates(e.Context(), &paymentpb.ReloadFXRatesRequest{})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// refundCommand refunds all or part of a committed payment back to its sender.
+func refundCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	transactionID := fs.String("transaction", "", "Transaction id of the payment to refund")
+	amount := fs.Float64("amount", 0, "Amount to refund (default what has not been refunded yet)")
+	reason := fs.String("reason", "", "Reason for the refund")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "transaction"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.RefundPayment(e.Context(), &paymentpb.RefundRequest{
+			OriginalTransactionId: *transactionID,
+			Amount:                *amount,
+			IdempotencyKey:        uuid.New().String(),
+			Reason:                *reason,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// scheduleCommand schedules a future payment, optionally recurring daily, weekly or
+// monthly. Times are RFC 3339, e.g. 2025-04-01T09:00:00Z.
+func scheduleCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	p := paymentFlags(fs)
+	executeAt := fs.String("execute_at", "", "Time of the first payment (RFC 3339)")
+	frequency := fs.String("frequency", "", "Repeat daily, weekly or monthly (default once)")
+	count := fs.Int("count", 0, "Number of payments to make (default no limit)")
+	endDate := fs.String("end_date", "", "Time after which no more payments are made (RFC 3339)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "sender_bank", "receiver_bank", "receiver", "amount", "execute_at"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.SchedulePayment(e.Context(), &paymentpb.SchedulePaymentRequest{
+			SenderUsername:   e.User,
+			ReceiverUsername: *p.receiver,
+			Amount:           *p.amount,
+			SenderBank:       *p.senderBank,
+			ReceiverBank:     *p.receiverBank,
+			ExecuteAt:        *executeAt,
+			Frequency:        *frequency,
+			Count:            int32(*count),
+			EndDate:          *endDate,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// listSchedulesCommand prints the scheduled payments of a user.
+func listSchedulesCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListSchedules(e.Context(), &paymentpb.ListSchedulesRequest{Username: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// changeScheduleCommand pauses, resumes or cancels a scheduled payment.
+func changeScheduleCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Schedule id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		ctx := e.Context()
+		actionReq := &paymentpb.ScheduleActionRequest{ScheduleId: *id}
+
+		var resp *paymentpb.ScheduleActionResponse
+		switch fs.Name() {
+		case "pauseschedule":
+			resp, err = client.PauseSchedule(ctx, actionReq)
+		case "resumeschedule":
+			resp, err = client.ResumeSchedule(ctx, actionReq)
+		default:
+			resp, err = client.CancelSchedule(ctx, actionReq)
+		}
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func requestPaymentCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	payer := fs.String("payer", "", "User asked to pay")
+	amount := fs.Float64("amount", 0, "Amount requested")
+	memo := fs.String("memo", "", "Note shown to the payer")
+	expiresAt := fs.String("expires_at", "", "Expiry of the request (RFC 3339, default in seven days)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "payer", "amount"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.RequestPayment(e.Context(), &paymentpb.RequestPaymentRequest{
+			Requester: e.User,
+			Payer:     *payer,
+			Amount:    *amount,
+			Memo:      *memo,
+			ExpiresAt: *expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listPaymentRequestsCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	pendingOnly := fs.Bool("pending", false, "Only list requests waiting for an answer")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListPaymentRequests(e.Context(), &paymentpb.ListPaymentRequestsRequest{Username: e.User, PendingOnly: *pendingOnly})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func changePaymentRequestCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Payment request id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		ctx := e.Context()
+		actionReq := &paymentpb.PaymentRequestAction{RequestId: *id}
+
+		var resp *paymentpb.PaymentRequestActionResponse
+		switch fs.Name() {
+		case "approverequest":
+			actionReq.IdempotencyKey = uuid.New().String()
+			resp, err = client.ApprovePaymentRequest(ctx, actionReq)
+		case "declinerequest":
+			resp, err = client.DeclinePaymentRequest(ctx, actionReq)
+		default:
+			resp, err = client.CancelPaymentRequest(ctx, actionReq)
+		}
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func createEscrowCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	p := paymentFlags(fs)
+	deadline := fs.String("deadline", "", "Time the escrow is refunded unless released (RFC 3339)")
+	description := fs.String("description", "", "What the escrow is for")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "sender_bank", "receiver_bank", "receiver", "amount", "deadline"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.CreateEscrow(e.Context(), &paymentpb.CreateEscrowRequest{
+			SenderUsername:   e.User,
+			ReceiverUsername: *p.receiver,
+			Amount:           *p.amount,
+			SenderBank:       *p.senderBank,
+			ReceiverBank:     *p.receiverBank,
+			IdempotencyKey:   uuid.New().String(),
+			Deadline:         *deadline,
+			Description:      *description,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listEscrowsCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListEscrows(e.Context(), &paymentpb.ListEscrowsRequest{Username: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func settleEscrowCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Escrow id")
+	reason := fs.String("reason", "", "Reason, kept with the escrow")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		actionReq := &paymentpb.EscrowAction{EscrowId: *id, Reason: *reason}
+
+		var resp *paymentpb.EscrowResponse
+		if fs.Name() == "releaseescrow" {
+			resp, err = client.ReleaseEscrow(e.Context(), actionReq)
+		} else {
+			resp, err = client.RefundEscrow(e.Context(), actionReq)
+		}
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// parseParty parses a multi-party payment argument of the form user[@bank][=amount].
+// Without a bank the gateway uses the bank the user registered with.
+func parseParty(arg string) (*paymentpb.PaymentParty, error) {
+	party := &paymentpb.PaymentParty{}
+	if i := strings.LastIndex(arg, "="); i >= 0 {
+		amt, err := strconv.ParseFloat(arg[i+1:], 64)
+		if err != nil {
+			return nil, usagef("invalid amount in %s: %v", arg, err)
+		}
+		party.Amount = amt
+		arg = arg[:i]
+	}
+	if i := strings.Index(arg, "@"); i >= 0 {
+		party.Bank = arg[i+1:]
+		arg = arg[:i]
+	}
+	party.Username = arg
+	return party, nil
+}
+
+// multiPaymentCommand implements splitpay, where --user pays every receiver given as an
+// argument, and poolpay, where an operator collects from every sender into --receiver.
+func multiPaymentCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	bank := fs.String("bank", "", "Bank of --user (splitpay) or of --receiver (poolpay), default the registered one")
+	receiver := fs.String("receiver", "", "User receiving the pooled payment (poolpay)")
+	return func(e *Env, args []string) error {
+		if len(args) == 0 {
+			return usagef("no parties given")
+		}
+		if fs.Name() == "poolpay" {
+			if err := requireFlags(fs, "receiver"); err != nil {
+				return err
+			}
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		req := &paymentpb.MultiPaymentRequest{
+			TransactionId:  fmt.Sprintf("%d", time.Now().UnixNano()),
+			IdempotencyKey: uuid.New().String(),
+		}
+		var parties []*paymentpb.PaymentParty
+		for _, arg := range args {
+			party, err := parseParty(arg)
+			if err != nil {
+				return err
+			}
+			parties = append(parties, party)
+		}
+		if fs.Name() == "splitpay" {
+			req.Senders = []*paymentpb.PaymentParty{{Username: e.User, Bank: *bank}}
+			req.Receivers = parties
+		} else {
+			req.Receivers = []*paymentpb.PaymentParty{{Username: *receiver, Bank: *bank}}
+			req.Senders = parties
+		}
+
+		resp, err := client.ProcessMultiPayment(e.Context(), req)
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// readBatchFile reads payments for a batch. CSV files have the columns
+// sender_bank,receiver_bank,sender,receiver,amount[,currency[,idempotency_key]] with an
+// optional header row; .jsonl files hold one pending-transaction JSON object per line.
+func readBatchFile(filename string) ([]*paymentpb.TransactionRequest, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var items []*paymentpb.TransactionRequest
+	if filepath.Ext(filename) == ".jsonl" {
+		scanner := bufio.NewScanner(f)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var offTx OfflineTransaction
+			if err := json.Unmarshal(scanner.Bytes(), &offTx); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			items = append(items, &paymentpb.TransactionRequest{
+				TransactionId:    offTx.TransactionId,
+				SenderUsername:   offTx.SenderUsername,
+				ReceiverUsername: offTx.ReceiverUsername,
+				Amount:           offTx.Amount,
+				SenderBank:       offTx.SenderBank,
+				ReceiverBank:     offTx.ReceiverBank,
+				IdempotencyKey:   offTx.IdempotencyKey,
+				Currency:         offTx.Currency,
+			})
+		}
+		return items, scanner.Err()
+	}
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if i == 0 && row[0] == "sender_bank" {
+			continue
+		}
+		if len(row) < 5 || len(row) > 7 {
+			return nil, fmt.Errorf("line %d: expected 5 to 7 columns, got %d", i+1, len(row))
+		}
+		amt, err := strconv.ParseFloat(strings.TrimSpace(row[4]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount: %v", i+1, err)
+		}
+		item := &paymentpb.TransactionRequest{
+			SenderBank:       strings.TrimSpace(row[0]),
+			ReceiverBank:     strings.TrimSpace(row[1]),
+			SenderUsername:   strings.TrimSpace(row[2]),
+			ReceiverUsername: strings.TrimSpace(row[3]),
+			Amount:           amt,
+		}
+		if len(row) >= 6 {
+			item.Currency = strings.TrimSpace(row[5])
+		}
+		if len(row) == 7 {
+			item.IdempotencyKey = strings.TrimSpace(row[6])
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// submitBatchCommand submits a batch and follows it until it completes, logging each
+// payment as it finishes and printing the final status.
+func submitBatchCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	file := fs.String("file", "", "CSV or .jsonl file of payments")
+	concurrency := fs.Int("concurrency", 0, "Payments executed at the same time (default the gateway's)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "file"); err != nil {
+			return err
+		}
+		items, err := readBatchFile(*file)
+		if err != nil {
+			return fmt.Errorf("cannot read batch file: %w", err)
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		ctx := e.Context()
+		req := &paymentpb.PaymentBatchRequest{BatchId: uuid.New().String(), Items: items, Concurrency: int32(*concurrency)}
+
+		submitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		resp, err := client.SubmitPaymentBatch(submitCtx, req)
+		if err != nil {
+			return err
+		}
+		log.Printf("%s (Batch ID: %s, %d payments)", resp.Message, resp.BatchId, resp.ItemCount)
+
+		stream, err := client.WatchPaymentBatch(ctx, &paymentpb.PaymentBatchStatusRequest{BatchId: resp.BatchId})
+		if err != nil {
+			return err
+		}
+		reported := make(map[int32]bool)
+		for {
+			update, err := stream.Recv()
+			if err != nil {
+				log.Printf("Lost the batch stream; check progress with batchstatus --id %s", resp.BatchId)
+				return err
+			}
+			for _, item := range update.Items {
+				if reported[item.Index] || (item.Status != "succeeded" && item.Status != "failed") {
+					continue
+				}
+				reported[item.Index] = true
+				log.Printf("[%d] %s -> %s %.2f: %s %s", item.Index, item.SenderUsername, item.ReceiverUsername, item.Amount, item.Status, item.Message)
+			}
+			if update.Completed {
+				return e.Print(update)
+			}
+		}
+	}
+}
+
+func getBatchCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Batch id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.GetPaymentBatch(e.Context(), &paymentpb.PaymentBatchStatusRequest{BatchId: *id})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// limitExceeded returns the spending limit details of a RESOURCE_EXHAUSTED error, or nil.
+func limitExceeded(err error) *paymentpb.LimitExceeded {
+	if err == nil {
+		return nil
+	}
+	for _, d := range status.Convert(err).Details() {
+		if detail, ok := d.(*paymentpb.LimitExceeded); ok {
+			return detail
+		}
+	}
+	return nil
+}
+
+func setSpendingLimitCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	username := fs.String("username", "", "User whose limit is set")
+	role := fs.String("role", "", "Role whose limit is set")
+	perTransaction := fs.Float64("per_transaction", 0, "Largest single payment (0 = no limit)")
+	daily := fs.Float64("daily", 0, "Total per UTC day (0 = no limit)")
+	monthly := fs.Float64("monthly", 0, "Total per UTC month (0 = no limit)")
+	maxCount := fs.Int("max_count", 0, "Payments per --count_window (0 = no limit)")
+	countWindow := fs.String("count_window", "", "Window of --max_count, e.g. 1h")
+	return func(e *Env, args []string) error {
+		if (*username == "") == (*role == "") {
+			return usagef("give exactly one of --username and --role")
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		req := &paymentpb.SetSpendingLimitRequest{Username: *username, Role: *role, Remove: fs.Name() == "removelimit"}
+		if !req.Remove {
+			req.Limit = &paymentpb.SpendingLimit{
+				PerTransaction: *perTransaction,
+				Daily:          *daily,
+				Monthly:        *monthly,
+				MaxCount:       int32(*maxCount),
+				CountWindow:    *countWindow,
+			}
+		}
+		resp, err := client.SetSpendingLimit(e.Context(), req)
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func setUserRoleCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	username := fs.String("username", "", "User to assign")
+	role := fs.String("role", "", "Role to assign the user to")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "username", "role"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.SetUserRole(e.Context(), &paymentpb.SetUserRoleRequest{Username: *username, Role: *role})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// headroomCommand shows the spending limits of a user and what is left of them. A limit
+// of 0 and a remaining amount of -1 mean no limit.
+func headroomCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	username := fs.String("username", "", "User to show (default --user)")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		target := *username
+		if target == "" {
+			target = e.User
+		}
+		resp, err := client.GetSpendingHeadroom(e.Context(), &paymentpb.HeadroomRequest{Username: target})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listReviewsCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	pendingOnly := fs.Bool("pending", false, "Only list undecided reviews")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListReviews(e.Context(), &paymentpb.ListReviewsRequest{PendingOnly: *pendingOnly})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func decideReviewCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Review id")
+	note := fs.String("note", "", "Note kept with the decision")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		decision := &paymentpb.ReviewDecision{ReviewId: *id, Note: *note}
+
+		var resp *paymentpb.ReviewDecisionResponse
+		if fs.Name() == "approvereview" {
+			resp, err = client.ApproveReview(e.Context(), decision)
+		} else {
+			resp, err = client.RejectReview(e.Context(), decision)
+		}
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func openDisputeCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	transactionID := fs.String("transaction", "", "Transaction id of the disputed payment")
+	reason := fs.String("reason", "", "Why the payment is disputed")
+	evidence := fs.String("evidence", "", "Supporting evidence")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "transaction", "reason"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.OpenDispute(e.Context(), &paymentpb.OpenDisputeRequest{
+			TransactionId: *transactionID,
+			Reason:        *reason,
+			Evidence:      *evidence,
+		})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listDisputesCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	openOnly := fs.Bool("open", false, "Only list undecided disputes")
+	all := fs.Bool("all", false, "List the disputes of every user (operators)")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		listReq := &paymentpb.ListDisputesRequest{Username: e.User, OpenOnly: *openOnly}
+		if *all {
+			listReq.Username = ""
+		}
+		resp, err := client.ListDisputes(e.Context(), listReq)
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func changeDisputeCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Dispute id")
+	note := fs.String("note", "", "Note kept with the dispute")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		ctx := e.Context()
+		action := &paymentpb.DisputeAction{DisputeId: *id, Note: *note}
+
+		var resp *paymentpb.DisputeResponse
+		switch fs.Name() {
+		case "investigatedispute":
+			resp, err = client.InvestigateDispute(ctx, action)
+		case "acceptdispute":
+			resp, err = client.AcceptDispute(ctx, action)
+		default:
+			resp, err = client.RejectDispute(ctx, action)
+		}
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func createApiKeyCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	scopes := fs.String("scopes", "", "Comma-separated scopes: payments, refunds, history")
+	label := fs.String("label", "", "Label to tell keys apart")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "scopes"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.CreateApiKey(e.Context(), &paymentpb.CreateApiKeyRequest{
+			Merchant: e.User,
+			Scopes:   strings.Split(*scopes, ","),
+			Label:    *label,
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Store this key now; it cannot be shown again.")
+		return e.Print(resp)
+	}
+}
+
+func listApiKeysCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListApiKeys(e.Context(), &paymentpb.ListApiKeysRequest{Merchant: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func changeApiKeyCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Key id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		action := &paymentpb.ApiKeyAction{KeyId: *id}
+
+		var resp *paymentpb.ApiKeyResponse
+		if fs.Name() == "rotatekey" {
+			resp, err = client.RotateApiKey(e.Context(), action)
+		} else {
+			resp, err = client.RevokeApiKey(e.Context(), action)
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Secret != "" {
+			log.Println("Store this key now; it cannot be shown again.")
+		}
+		return e.Print(resp)
+	}
+}
+
+// createInvoiceCommand creates an invoice of merchant --user with one line item per
+// argument of the form description:quantity:unit_price.
+func createInvoiceCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	customer := fs.String("customer", "", "Only user allowed to pay (default anyone, as a payment link)")
+	dueDate := fs.String("due_date", "", "Due date (RFC 3339)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "due_date"); err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return usagef("no line items given")
+		}
+		invoiceReq := &paymentpb.CreateInvoiceRequest{Merchant: e.User, Customer: *customer, DueDate: *dueDate}
+		for _, arg := range args {
+			parts := strings.Split(arg, ":")
+			if len(parts) != 3 {
+				return usagef("invalid line item %q, expected description:quantity:unit_price", arg)
+			}
+			quantity, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return usagef("invalid quantity in %q: %v", arg, err)
+			}
+			price, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil {
+				return usagef("invalid unit price in %q: %v", arg, err)
+			}
+			invoiceReq.LineItems = append(invoiceReq.LineItems, &paymentpb.InvoiceLineItem{Description: parts[0], Quantity: int32(quantity), UnitPrice: price})
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.CreateInvoice(e.Context(), invoiceReq)
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func getInvoiceCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	reference := fs.String("reference", "", "Invoice reference")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "reference"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.GetInvoice(e.Context(), &paymentpb.GetInvoiceRequest{Reference: *reference})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listInvoicesCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListInvoices(e.Context(), &paymentpb.ListInvoicesRequest{Merchant: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func payInvoiceCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	reference := fs.String("reference", "", "Invoice reference")
+	amount := fs.Float64("amount", 0, "Amount to pay (default everything still due)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "reference"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		txReq := &paymentpb.TransactionRequest{
+			TransactionId:    fmt.Sprintf("%d", time.Now().UnixNano()),
+			SenderUsername:   e.User,
+			IdempotencyKey:   uuid.New().String(),
+			InvoiceReference: *reference,
+			Amount:           *amount,
+		}
+		resp, err := client.ProcessPayment(e.Context(), txReq)
+		if err != nil {
+			return err
+		}
+		return e.Print(paymentResultOf(txReq, resp))
+	}
+}
+
+func registerWebhookCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	url := fs.String("url", "", "HTTPS endpoint to deliver events to")
+	events := fs.String("events", "", "Comma-separated events (default all)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "url"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		webhookReq := &paymentpb.RegisterWebhookRequest{Owner: e.User, Url: *url}
+		if *events != "" {
+			webhookReq.Events = strings.Split(*events, ",")
+		}
+		resp, err := client.RegisterWebhook(e.Context(), webhookReq)
+		if err != nil {
+			return err
+		}
+		log.Println("Store this signing secret now; it cannot be shown again.")
+		return e.Print(resp)
+	}
+}
+
+func listWebhooksCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListWebhooks(e.Context(), &paymentpb.ListWebhooksRequest{Owner: e.User})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func deleteWebhookCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Endpoint id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.DeleteWebhook(e.Context(), &paymentpb.WebhookAction{EndpointId: *id})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func listDeliveriesCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	deadOnly := fs.Bool("dead", false, "Only list deliveries that gave up")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListWebhookDeliveries(e.Context(), &paymentpb.ListWebhookDeliveriesRequest{Owner: e.User, DeadOnly: *deadOnly})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func redeliverCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Delivery id")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.RedeliverWebhook(e.Context(), &paymentpb.WebhookDeliveryAction{DeliveryId: *id})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+// webhookDelivery is what webhookreceiver prints for each delivery it accepts.
+type webhookDelivery struct {
+	Id        string          `json:"id"`
+	Event     string          `json:"event"`
+	Timestamp string          `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// webhookReceiverCommand listens for webhook deliveries, checks their signature and
+// prints them. It is meant for trying out webhooks locally.
+func webhookReceiverCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	listenAddr := fs.String("listen", ":8080", "Address to listen on")
+	secret := fs.String("secret", "", "Signing secret of the endpoint")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "secret"); err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "cannot read body", http.StatusBadRequest)
+				return
+			}
+			timestamp := r.Header.Get("X-Webhook-Timestamp")
+			mac := hmac.New(sha256.New, []byte(*secret))
+			mac.Write([]byte(timestamp + "."))
+			mac.Write(body)
+			expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Webhook-Signature"))) {
+				log.Printf("Rejected webhook %s: bad signature", r.Header.Get("X-Webhook-Id"))
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+			if !json.Valid(body) {
+				body, _ = json.Marshal(string(body))
+			}
+			e.Emit(webhookDelivery{
+				Id:        r.Header.Get("X-Webhook-Id"),
+				Event:     r.Header.Get("X-Webhook-Event"),
+				Timestamp: timestamp,
+				Payload:   body,
+			})
+			w.WriteHeader(http.StatusNoContent)
+		})
+		server := &http.Server{Addr: *listenAddr, Handler: mux}
+		go func() {
+			<-e.Context().Done()
+			server.Close()
+		}()
+		log.Printf("Webhook receiver listening on %s", *listenAddr)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return e.Context().Err()
+	}
+}
+
+// eventsCommand prints payment and balance events as they happen. When the stream breaks
+// it reconnects from the last event received, and starts over with new events if the
+// gateway can no longer replay the missed ones.
+func eventsCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	from := fs.Uint64("from_sequence", 0, "Resume after this sequence number (needs --epoch)")
+	epoch := fs.String("epoch", "", "Epoch of --from_sequence")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		subReq := &paymentpb.SubscribeEventsRequest{FromSequence: *from, Epoch: *epoch}
+		ctx := e.Context()
+
+		for {
+			stream, err := client.SubscribeEvents(ctx, subReq)
+			if err == nil {
+				log.Printf("Subscribed to events of %s (from sequence %d)", e.User, subReq.FromSequence)
+				for {
+					var event *paymentpb.GatewayEvent
+					event, err = stream.Recv()
+					if err != nil {
+						break
+					}
+					subReq.FromSequence = event.Sequence
+					subReq.Epoch = event.Epoch
+					if err := e.Emit(event); err != nil {
+						return err
+					}
+				}
+			}
+			switch status.Code(err) {
+			case codes.Unavailable:
+				log.Printf("Event stream interrupted, reconnecting: %v", err)
+				time.Sleep(2 * time.Second)
+			case codes.OutOfRange:
+				log.Printf("%v; reload balances and history, continuing with new events", status.Convert(err).Message())
+				subReq = &paymentpb.SubscribeEventsRequest{}
+			default:
+				return err
+			}
+		}
+	}
+}
+
+// submitPaymentCommand hands a payment to the gateway's worker pool and returns at once
+// with its payment id; paymentstatus reports the outcome.
+func submitPaymentCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	p := paymentFlags(fs)
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		txReq, err := p.request(fs, e)
+		if err != nil {
+			return err
+		}
+		resp, err := client.SubmitPayment(e.Context(), txReq)
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
+
+func paymentStatusCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Payment id returned by submitpay")
+	wait := fs.Int("wait", 0, "Seconds to wait for the payment to finish (default do not wait)")
+	return func(e *Env, args []string) error {
+		if err := requireFlags(fs, "id"); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		ctx := e.Context()
+
+		var resp *paymentpb.PaymentStatus
+		if *wait > 0 {
+			resp, err = client.WaitForPayment(ctx, &paymentpb.WaitForPaymentRequest{PaymentId: *id, TimeoutSeconds: int32(*wait)})
+		} else {
+			resp, err = client.GetPaymentStatus(ctx, &paymentpb.PaymentStatusRequest{PaymentId: *id})
+		}
+		if err != nil {
+			return err
+		}
+		if *wait > 0 && !resp.Done {
+			log.Printf("Payment still %s after %ds", resp.Status, *wait)
+		}
+		return e.Print(resp)
+	}
+}
+
+func queuedPaymentsCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	all := fs.Bool("all", false, "Include delivered, failed and expired payments")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		client, err := e.Client()
+		if err != nil {
+			return err
+		}
+		resp, err := client.ListQueuedPayments(e.Context(), &paymentpb.ListQueuedPaymentsRequest{Username: e.User, IncludeFinished: *all})
+		if err != nil {
+			return err
+		}
+		return e.Print(resp)
+	}
+}
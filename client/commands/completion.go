@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commandFlagNames returns the flags of cmd, without the global ones.
+func commandFlagNames(cmd *Command) []string {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	cmd.Setup(fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	return names
+}
+
+// commandWords returns the positional words cmd takes, such as list|retry|drop.
+func commandWords(cmd *Command) []string {
+	if cmd.Name == "help" {
+		return commandNames()
+	}
+	if cmd.Args == "" || strings.ContainsAny(cmd.Args, "[.=:") {
+		return nil
+	}
+	return strings.Split(cmd.Args, "|")
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commandList))
+	for _, cmd := range commandList {
+		names = append(names, cmd.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func dashed(names []string) string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = "--" + n
+	}
+	return strings.Join(out, " ")
+}
+
+// fileFlags are completed with file names.
+var fileFlags = "--cert|--key|--ca|--config|--file"
+
+// bashCompletion returns a bash completion script for the client. The zsh script wraps
+// it with bashcompinit.
+func bashCompletion(prog string) string {
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", prog)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur prev cmd i\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    case \"$prev\" in\n")
+	b.WriteString("        --output|-o) COMPREPLY=($(compgen -W \"table json yaml\" -- \"$cur\")); return ;;\n")
+	fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", fileFlags)
+	b.WriteString("    esac\n")
+	b.WriteString("    cmd=\"\"\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        case \"${COMP_WORDS[i]}\" in\n")
+	b.WriteString("            --*=*) ;;\n")
+	fmt.Fprintf(&b, "            %s|-o) ((i++)) ;;\n", strings.Replace(dashed(globalFlags), " ", "|", -1))
+	b.WriteString("            -*) ;;\n")
+	b.WriteString("            *) cmd=\"${COMP_WORDS[i]}\"; break ;;\n")
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	fmt.Fprintf(&b, "        \"\") COMPREPLY=($(compgen -W \"%s %s\" -- \"$cur\")) ;;\n", strings.Join(commandNames(), " "), dashed(globalFlags))
+	for _, name := range commandNames() {
+		cmd := findCommand(name)
+		words := append(commandWords(cmd), strings.Fields(dashed(commandFlagNames(cmd)))...)
+		fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"%s %s\" -- \"$cur\")) ;;\n", name, strings.Join(words, " "), dashed(globalFlags))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, prog)
+	return b.String()
+}
+
+func zshCompletion(prog string) string {
+	return fmt.Sprintf("#compdef %s\nautoload -U +X bashcompinit && bashcompinit\n%s", prog, bashCompletion(prog))
+}
+
+func fishCompletion(prog string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", prog)
+	fmt.Fprintf(&b, "complete -c %s -f\n", prog)
+	for _, name := range globalFlags {
+		switch name {
+		case "output":
+			fmt.Fprintf(&b, "complete -c %s -l output -s o -x -a 'table json yaml'\n", prog)
+		case "cert", "key", "ca", "config":
+			fmt.Fprintf(&b, "complete -c %s -l %s -r -F\n", prog, name)
+		default:
+			fmt.Fprintf(&b, "complete -c %s -l %s -x\n", prog, name)
+		}
+	}
+	for _, name := range commandNames() {
+		cmd := findCommand(name)
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a %s -d %q\n", prog, name, cmd.Summary)
+		if words := commandWords(cmd); len(words) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from %s' -a '%s'\n", prog, name, strings.Join(words, " "))
+		}
+		for _, f := range commandFlagNames(cmd) {
+			if f == "file" {
+				fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from %s' -l %s -r -F\n", prog, name, f)
+				continue
+			}
+			fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from %s' -l %s\n", prog, name, f)
+		}
+	}
+	return b.String()
+}
+
+// completionCommand prints a completion script, e.g. for bash:
+//
+//	source <(client completion bash)
+func completionCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	return func(e *Env, args []string) error {
+		if len(args) != 1 {
+			return usagef("expected bash, zsh or fish")
+		}
+		prog := programName()
+		var script string
+		switch args[0] {
+		case "bash":
+			script = bashCompletion(prog)
+		case "zsh":
+			script = zshCompletion(prog)
+		case "fish":
+			script = fishCompletion(prog)
+		default:
+			return usagef("unknown shell %q, expected bash, zsh or fish", args[0])
+		}
+		_, err := fmt.Fprint(e.Out, script)
+		return err
+	}
+}
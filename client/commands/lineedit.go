@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines from a terminal in raw mode, with cursor movement, history on
+// the arrow keys and tab completion.
+type lineEditor struct {
+	fd      int
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	// complete returns where the word being completed starts and the candidates for it.
+	complete func(line []rune, pos int) (start int, candidates []string)
+}
+
+// addHistory appends a line to the history, skipping blank lines and repeats.
+func (l *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(l.history); n > 0 && l.history[n-1] == line {
+		return
+	}
+	l.history = append(l.history, line)
+}
+
+// readLine shows the prompt and returns the line entered. It returns io.EOF for Ctrl-D
+// on an empty line and errInterrupted for Ctrl-C.
+func (l *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := setRawMode(l.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	var line []rune
+	pos := 0
+	hist := len(l.history)
+	saved := ""
+	redraw := func() {
+		fmt.Fprintf(l.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(l.out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(i int) {
+		if hist == len(l.history) {
+			saved = string(line)
+		}
+		hist = i
+		if hist == len(l.history) {
+			line = []rune(saved)
+		} else {
+			line = []rune(l.history[hist])
+		}
+		pos = len(line)
+	}
+
+	fmt.Fprint(l.out, prompt)
+	for {
+		r, _, err := l.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(l.out, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(l.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(l.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(l.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			if hist > 0 {
+				recall(hist - 1)
+			}
+		case 14: // Ctrl-N
+			if hist < len(l.history) {
+				recall(hist + 1)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case '\t':
+			line, pos = l.completeAt(line, pos)
+		case 27: // escape sequence
+			key := l.readEscape()
+			switch key {
+			case "A":
+				if hist > 0 {
+					recall(hist - 1)
+				}
+			case "B":
+				if hist < len(l.history) {
+					recall(hist + 1)
+				}
+			case "C":
+				if pos < len(line) {
+					pos++
+				}
+			case "D":
+				if pos > 0 {
+					pos--
+				}
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(line)
+			case "3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if r >= ' ' {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// readEscape reads the rest of a CSI or SS3 sequence such as ESC [ A and returns what
+// follows the bracket.
+func (l *lineEditor) readEscape() string {
+	r, _, err := l.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+	var seq []rune
+	for {
+		r, _, err := l.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if r < '0' || r > '9' {
+			return string(seq)
+		}
+	}
+}
+
+// completeAt completes the word before the cursor. A single candidate is inserted with
+// a trailing space; several are extended to their common prefix, or listed when there is
+// nothing to add.
+func (l *lineEditor) completeAt(line []rune, pos int) ([]rune, int) {
+	if l.complete == nil {
+		return line, pos
+	}
+	start, candidates := l.complete(line, pos)
+	word := string(line[start:pos])
+	var insert string
+	switch len(candidates) {
+	case 0:
+		fmt.Fprint(l.out, "\a")
+		return line, pos
+	case 1:
+		insert = strings.TrimPrefix(candidates[0], word) + " "
+	default:
+		common := candidates[0]
+		for _, c := range candidates[1:] {
+			for !strings.HasPrefix(c, common) {
+				common = common[:len(common)-1]
+			}
+		}
+		insert = strings.TrimPrefix(common, word)
+		if insert == "" {
+			fmt.Fprintf(l.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			return line, pos
+		}
+	}
+	ins := []rune(insert)
+	line = append(line[:pos], append(ins, line[pos:]...)...)
+	return line, pos + len(ins)
+}
+
+// readPassword reads a line without echoing it.
+func (l *lineEditor) readPassword(prompt string) (string, error) {
+	restore, err := setNoEcho(l.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	fmt.Fprint(l.out, prompt)
+	pass, err := l.in.ReadString('\n')
+	fmt.Fprint(l.out, "\n")
+	if err != nil && pass == "" {
+		return "", err
+	}
+	return strings.TrimRight(pass, "\r\n"), nil
+}
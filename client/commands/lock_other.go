@@ -0,0 +1,13 @@
+//go:build !unix
+
+package commands
+
+import "os"
+
+// lockFile does not lock on platforms without flock; concurrent client processes may
+// then overwrite each other's changes to the offline queue.
+func lockFile(f *os.File, wait bool) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) {}
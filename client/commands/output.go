@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Results are printed through their JSON form: protobuf messages with protojson, other
+// values with encoding/json. YAML and tables are made from the JSON, so every format
+// shows the same fields under the same names.
+
+func marshalJSON(v interface{}, emitUnpopulated bool) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.MarshalOptions{EmitUnpopulated: emitUnpopulated}.Marshal(m)
+	}
+	return json.Marshal(v)
+}
+
+// toNode parses JSON into a YAML node tree, which keeps the order of the fields.
+func toNode(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	clearStyle(&doc)
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+		return doc.Content[0], nil
+	}
+	return &doc, nil
+}
+
+// clearStyle drops the JSON flow style and quoting so the YAML is written in block style.
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
+
+// Print writes a command's result in the selected output format.
+func (e *Env) Print(v interface{}) error {
+	switch e.Output {
+	case "json":
+		data, err := marshalJSON(v, false)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err = e.Out.Write(out.Bytes())
+		return err
+	case "yaml":
+		return e.writeYAML(v)
+	}
+	data, err := marshalJSON(v, true)
+	if err != nil {
+		return err
+	}
+	node, err := toNode(data)
+	if err != nil {
+		return err
+	}
+	return writeTable(e.Out, node)
+}
+
+// Emit writes one item of a stream: a JSON line, a YAML document or a line of
+// key=value pairs.
+func (e *Env) Emit(v interface{}) error {
+	switch e.Output {
+	case "json":
+		data, err := marshalJSON(v, false)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := json.Compact(&out, data); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err = e.Out.Write(out.Bytes())
+		return err
+	case "yaml":
+		if _, err := io.WriteString(e.Out, "---\n"); err != nil {
+			return err
+		}
+		return e.writeYAML(v)
+	}
+	data, err := marshalJSON(v, false)
+	if err != nil {
+		return err
+	}
+	node, err := toNode(data)
+	if err != nil {
+		return err
+	}
+	var pairs []string
+	for _, kv := range flatten("", node) {
+		if !emptyValue(kv.value) {
+			pairs = append(pairs, kv.key+"="+quoteCell(kv.value))
+		}
+	}
+	_, err = fmt.Fprintln(e.Out, strings.Join(pairs, " "))
+	return err
+}
+
+func (e *Env) writeYAML(v interface{}) error {
+	data, err := marshalJSON(v, false)
+	if err != nil {
+		return err
+	}
+	node, err := toNode(data)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(e.Out)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+type keyValue struct {
+	key   string
+	value *yaml.Node
+}
+
+// flatten lists the fields of a mapping, with nested mappings as prefix.field. Lists are
+// kept as one value.
+func flatten(prefix string, n *yaml.Node) []keyValue {
+	if n.Kind != yaml.MappingNode {
+		return []keyValue{{prefix, n}}
+	}
+	var out []keyValue
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		out = append(out, flatten(key, n.Content[i+1])...)
+	}
+	return out
+}
+
+// emptyValue reports whether a value is not worth a row or column: null, an empty string,
+// list or mapping, zero or false.
+func emptyValue(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!null":
+			return true
+		case "!!str":
+			return n.Value == ""
+		case "!!int", "!!float":
+			return n.Value == "0"
+		case "!!bool":
+			return n.Value == "false"
+		}
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(n.Content) == 0
+	}
+	return false
+}
+
+// blank reports whether n holds nothing at all. Unlike emptyValue it keeps zero and
+// false, which are meaningful in a single result.
+func blank(n *yaml.Node) bool {
+	if n.Kind == yaml.ScalarNode {
+		return n.Tag == "!!null" || n.Tag == "!!str" && n.Value == ""
+	}
+	return len(n.Content) == 0
+}
+
+// cell renders a value for a table: lists of scalars comma-separated, anything deeper as
+// a count.
+func cell(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return ""
+		}
+		return n.Value
+	case yaml.SequenceNode:
+		parts := make([]string, 0, len(n.Content))
+		for _, c := range n.Content {
+			if c.Kind != yaml.ScalarNode {
+				return fmt.Sprintf("%d items", len(n.Content))
+			}
+			parts = append(parts, c.Value)
+		}
+		return strings.Join(parts, ",")
+	case yaml.MappingNode:
+		var pairs []string
+		for _, kv := range flatten("", n) {
+			if !emptyValue(kv.value) {
+				pairs = append(pairs, kv.key+"="+cell(kv.value))
+			}
+		}
+		return strings.Join(pairs, " ")
+	}
+	return ""
+}
+
+func quoteCell(n *yaml.Node) string {
+	s := cell(n)
+	if strings.ContainsAny(s, " \t\"") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// header turns a JSON field name such as transactionId into TRANSACTION_ID.
+func header(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if r == '.' {
+			b.WriteByte('_')
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// isTable reports whether n is a list of mappings.
+func isTable(n *yaml.Node) bool {
+	if n.Kind != yaml.SequenceNode {
+		return false
+	}
+	for _, c := range n.Content {
+		if c.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTable prints a result for people: the fields of a message as name/value rows,
+// followed by each list of messages as a table with one column per field that is set in
+// any row.
+func writeTable(w io.Writer, n *yaml.Node) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch {
+	case n.Kind == yaml.MappingNode:
+		var tables []keyValue
+		rows := 0
+		for _, kv := range flatten("", n) {
+			// An empty list may be a table; it is only shown when there is nothing else.
+			if isTable(kv.value) && (len(kv.value.Content) > 0 || !strings.Contains(kv.key, ".")) {
+				tables = append(tables, kv)
+				continue
+			}
+			if blank(kv.value) {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", header(kv.key), cell(kv.value))
+			rows++
+		}
+		var filled []keyValue
+		for _, t := range tables {
+			if len(t.value.Content) > 0 {
+				filled = append(filled, t)
+			}
+		}
+		if rows == 0 && len(filled) == 0 && len(tables) > 0 {
+			// A list result with nothing in it.
+			fmt.Fprintln(tw, "(none)")
+		}
+		for _, t := range filled {
+			if rows > 0 || len(filled) > 1 {
+				fmt.Fprintf(tw, "\n%s\n", header(t.key))
+			}
+			writeRows(tw, t.value)
+		}
+	case isTable(n):
+		if len(n.Content) == 0 {
+			fmt.Fprintln(tw, "(none)")
+		}
+		writeRows(tw, n)
+	default:
+		fmt.Fprintln(tw, cell(n))
+	}
+	return tw.Flush()
+}
+
+// writeRows prints a list of mappings as a table.
+func writeRows(tw *tabwriter.Writer, list *yaml.Node) {
+	var columns []string
+	seen := make(map[string]bool)
+	used := make(map[string]bool)
+	var rows []map[string]*yaml.Node
+	for _, item := range list.Content {
+		row := make(map[string]*yaml.Node)
+		for _, kv := range flatten("", item) {
+			if !seen[kv.key] {
+				seen[kv.key] = true
+				columns = append(columns, kv.key)
+			}
+			if !emptyValue(kv.value) {
+				used[kv.key] = true
+			}
+			row[kv.key] = kv.value
+		}
+		rows = append(rows, row)
+	}
+	var shown []string
+	for _, c := range columns {
+		if used[c] {
+			shown = append(shown, c)
+		}
+	}
+	headers := make([]string, len(shown))
+	for i, c := range shown {
+		headers[i] = header(c)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(shown))
+		for i, c := range shown {
+			if v, ok := row[c]; ok {
+				cells[i] = cell(v)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// profileFile is the client configuration file: named profiles and the one used when
+// --profile is not given.
+type profileFile struct {
+	Current  string             `json:"current,omitempty"`
+	Profiles map[string]Options `json:"profiles"`
+}
+
+// configFile returns the path of the profile file.
+func (o *Options) configFile() string {
+	if o.Config != "" {
+		return o.Config
+	}
+	if env := os.Getenv("PAYMENT_CLIENT_CONFIG"); env != "" {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".payment_client.json"
+	}
+	return filepath.Join(home, ".payment_client.json")
+}
+
+// loadProfiles reads the profile file. A missing file means no profiles.
+func loadProfiles(filename string) (*profileFile, error) {
+	profiles := &profileFile{Profiles: make(map[string]Options)}
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, profiles)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read profile file %s: %w", filename, err)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = make(map[string]Options)
+	}
+	return profiles, nil
+}
+
+func (p *profileFile) save(filename string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file may hold passwords.
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// profileSummary is how a profile is printed; the password is never shown.
+type profileSummary struct {
+	Name        string `json:"name"`
+	Current     bool   `json:"current"`
+	Gateway     string `json:"gateway,omitempty"`
+	User        string `json:"user,omitempty"`
+	Cert        string `json:"cert,omitempty"`
+	Key         string `json:"key,omitempty"`
+	CA          string `json:"ca,omitempty"`
+	Output      string `json:"output,omitempty"`
+	HasPassword bool   `json:"hasPassword"`
+}
+
+func summarizeProfile(name string, p Options, current bool) profileSummary {
+	return profileSummary{
+		Name:        name,
+		Current:     current,
+		Gateway:     p.Gateway,
+		User:        p.User,
+		Cert:        p.Cert,
+		Key:         p.Key,
+		CA:          p.CA,
+		Output:      p.Output,
+		HasPassword: p.Password != "",
+	}
+}
+
+// profileCommand implements client profile list|show|set|use. Options given on the
+// command line are written to the profile by set; the rest keep their saved values.
+func profileCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	name := fs.String("name", "", "Profile to set or use (default the --profile or current profile)")
+	return func(e *Env, args []string) error {
+		if len(args) != 1 {
+			return usagef("expected list, show, set or use")
+		}
+		filename := e.configFile()
+		profiles, err := loadProfiles(filename)
+		if err != nil {
+			return err
+		}
+		target := *name
+		if target == "" {
+			target = e.Profile
+		}
+		if target == "" {
+			target = profiles.Current
+		}
+
+		switch args[0] {
+		case "list":
+			names := make([]string, 0, len(profiles.Profiles))
+			for n := range profiles.Profiles {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			list := struct {
+				Profiles []profileSummary `json:"profiles"`
+			}{Profiles: []profileSummary{}}
+			for _, n := range names {
+				list.Profiles = append(list.Profiles, summarizeProfile(n, profiles.Profiles[n], n == profiles.Current))
+			}
+			return e.Print(list)
+
+		case "show":
+			// The settings commands run with, after flags, profile and defaults.
+			return e.Print(summarizeProfile(target, e.Options, target == profiles.Current))
+
+		case "set":
+			if target == "" {
+				return usagef("give the profile to set with --name")
+			}
+			given := e.explicit
+			p := profiles.Profiles[target]
+			given.merge(p)
+			given.Config, given.Profile = "", ""
+			profiles.Profiles[target] = given
+			if profiles.Current == "" {
+				profiles.Current = target
+			}
+			if err := profiles.save(filename); err != nil {
+				return err
+			}
+			return e.Print(summarizeProfile(target, given, target == profiles.Current))
+
+		case "use":
+			if _, ok := profiles.Profiles[target]; !ok {
+				return usagef("profile %q not found in %s", target, filename)
+			}
+			profiles.Current = target
+			if err := profiles.save(filename); err != nil {
+				return err
+			}
+			return e.Print(summarizeProfile(target, profiles.Profiles[target], true))
+		}
+		return usagef("expected list, show, set or use")
+	}
+}
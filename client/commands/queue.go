@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jahnu05/Assignment-2/P-3/paymentclient"
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// offlineQueueFile holds payments that could not reach the gateway. It is shared by every
+// client process started from the same directory.
+const offlineQueueFile = "pending_transactions.json"
+
+const (
+	queueBaseDelay = 5 * time.Second
+	queueMaxDelay  = 10 * time.Minute
+)
+
+// OfflineTransaction is a queued payment as stored in the offline queue file. The
+// idempotency key is kept across retries, so a payment that reached the gateway before
+// its response was lost is not executed twice.
+type OfflineTransaction struct {
+	TransactionId    string  `json:"transactionId"`
+	SenderUsername   string  `json:"senderUsername"`
+	ReceiverUsername string  `json:"receiverUsername"`
+	Amount           float64 `json:"amount"`
+	SenderBank       string  `json:"senderBank"`
+	ReceiverBank     string  `json:"receiverBank"`
+	IdempotencyKey   string  `json:"idempotencyKey"`
+	Currency         string  `json:"currency,omitempty"`
+	QueuedAt         string  `json:"queuedAt,omitempty"`
+	Attempts         int     `json:"attempts,omitempty"`
+	NextAttemptAt    string  `json:"nextAttemptAt,omitempty"`
+	LastError        string  `json:"lastError,omitempty"`
+}
+
+func offlineTransactionOf(req *paymentpb.TransactionRequest) OfflineTransaction {
+	return OfflineTransaction{
+		TransactionId:    req.TransactionId,
+		SenderUsername:   req.SenderUsername,
+		ReceiverUsername: req.ReceiverUsername,
+		Amount:           req.Amount,
+		SenderBank:       req.SenderBank,
+		ReceiverBank:     req.ReceiverBank,
+		IdempotencyKey:   req.IdempotencyKey,
+		Currency:         req.Currency,
+		QueuedAt:         time.Now().Format(time.RFC3339),
+	}
+}
+
+func (tx *OfflineTransaction) request() *paymentpb.TransactionRequest {
+	return &paymentpb.TransactionRequest{
+		TransactionId:    tx.TransactionId,
+		SenderUsername:   tx.SenderUsername,
+		ReceiverUsername: tx.ReceiverUsername,
+		Amount:           tx.Amount,
+		SenderBank:       tx.SenderBank,
+		ReceiverBank:     tx.ReceiverBank,
+		IdempotencyKey:   tx.IdempotencyKey,
+		Currency:         tx.Currency,
+		QueueIfBankDown:  true,
+	}
+}
+
+// due reports whether the payment's backoff has passed.
+func (tx *OfflineTransaction) due(now time.Time) bool {
+	next, err := time.Parse(time.RFC3339, tx.NextAttemptAt)
+	return err != nil || !now.Before(next)
+}
+
+// queueBackoff returns the delay before the next attempt: 5s doubling per attempt, capped
+// at 10 minutes.
+func queueBackoff(attempts int) time.Duration {
+	delay := queueBaseDelay
+	for i := 1; i < attempts && delay < queueMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > queueMaxDelay {
+		delay = queueMaxDelay
+	}
+	return delay
+}
+
+// retryable reports whether a payment error is temporary, so the payment should stay
+// queued. Anything else, such as PermissionDenied or FailedPrecondition, will fail the
+// same way on every retry.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// offlineQueue is the offline queue file, locked against other client processes while
+// it is open.
+type offlineQueue struct {
+	lock  *os.File
+	items []OfflineTransaction
+}
+
+// openOfflineQueue locks and reads the offline queue. With wait false it returns nil
+// when another client process holds the lock. A missing file means an empty queue.
+func openOfflineQueue(wait bool) (*offlineQueue, error) {
+	lock, err := os.OpenFile(offlineQueueFile+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := lockFile(lock, wait)
+	if err != nil || !locked {
+		lock.Close()
+		return nil, err
+	}
+	q := &offlineQueue{lock: lock}
+	data, err := ioutil.ReadFile(offlineQueueFile)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &q.items)
+	}
+	if err != nil {
+		q.close()
+		return nil, fmt.Errorf("cannot read %s: %w", offlineQueueFile, err)
+	}
+	return q, nil
+}
+
+// save writes the queue back to its file.
+func (q *offlineQueue) save() error {
+	items := q.items
+	if items == nil {
+		items = []OfflineTransaction{}
+	}
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(offlineQueueFile, data, 0644)
+}
+
+// close releases the lock.
+func (q *offlineQueue) close() {
+	unlockFile(q.lock)
+	q.lock.Close()
+}
+
+// queuePayment adds a payment to the offline queue.
+func queuePayment(req *paymentpb.TransactionRequest, cause error) error {
+	q, err := openOfflineQueue(true)
+	if err != nil {
+		return err
+	}
+	defer q.close()
+	tx := offlineTransactionOf(req)
+	tx.Attempts = 1
+	tx.LastError = status.Convert(cause).Message()
+	tx.NextAttemptAt = time.Now().Add(queueBackoff(1)).Format(time.RFC3339)
+	q.items = append(q.items, tx)
+	return q.save()
+}
+
+// sendPayment sends a payment to the gateway as the client's user.
+func sendPayment(client *paymentclient.Client, req *paymentpb.TransactionRequest) (*paymentpb.TransactionResponse, error) {
+	return client.ProcessPayment(context.Background(), req)
+}
+
+// retryOfflineQueue sends the queued payments of the client's user that are due, or all
+// of them when force is set, or only the one with the given transaction id. Payments of
+// other users are left alone, since they need their own credentials. Delivered payments
+// and payments that failed permanently leave the queue; the others are retried later
+// with backoff. It returns how many of the selected payments are left and when the next
+// one is due.
+func retryOfflineQueue(client *paymentclient.Client, q *offlineQueue, transactionId string, force bool) (int, time.Time) {
+	now := time.Now()
+	selected := func(tx OfflineTransaction) bool {
+		return tx.SenderUsername == client.Username() && (transactionId == "" || tx.TransactionId == transactionId)
+	}
+	var kept []OfflineTransaction
+	var next time.Time
+	for _, tx := range q.items {
+		if !selected(tx) || (!force && !tx.due(now)) {
+			kept = append(kept, tx)
+			continue
+		}
+		resp, err := sendPayment(client, tx.request())
+		switch {
+		case err == nil:
+			log.Printf("Queued transaction %s delivered after %d failed attempts: %s", tx.TransactionId, tx.Attempts, resp.Message)
+			continue
+		case !retryable(err):
+			log.Printf("Queued transaction %s failed permanently and was dropped: %v", tx.TransactionId, status.Convert(err).Message())
+			continue
+		}
+		tx.Attempts++
+		tx.LastError = status.Convert(err).Message()
+		tx.NextAttemptAt = time.Now().Add(queueBackoff(tx.Attempts)).Format(time.RFC3339)
+		log.Printf("Queued transaction %s failed again (attempt %d), next attempt at %s: %s", tx.TransactionId, tx.Attempts, tx.NextAttemptAt, tx.LastError)
+		kept = append(kept, tx)
+	}
+	left := 0
+	for _, tx := range kept {
+		if !selected(tx) {
+			continue
+		}
+		left++
+		if t, err := time.Parse(time.RFC3339, tx.NextAttemptAt); err == nil && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	q.items = kept
+	if err := q.save(); err != nil {
+		log.Printf("Error writing offline queue: %v", err)
+	}
+	return left, next
+}
+
+// retryDuePayments sends the due queued payments of the client's user, unless another
+// client process is already working on the queue.
+func retryDuePayments(client *paymentclient.Client) {
+	q, err := openOfflineQueue(false)
+	if err != nil {
+		log.Printf("Error opening offline queue: %v", err)
+		return
+	}
+	if q == nil {
+		return
+	}
+	defer q.close()
+	if len(q.items) > 0 {
+		retryOfflineQueue(client, q, "", false)
+	}
+}
+
+// offlineQueueCommand implements client queue list|retry|drop. retry sends the payments
+// of --user; list and drop work on the whole queue.
+func offlineQueueCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	id := fs.String("id", "", "Transaction id (default every queued payment)")
+	wait := fs.Bool("wait", false, "retry: keep retrying until the selected payments leave the queue")
+	return func(e *Env, args []string) error {
+		if len(args) != 1 {
+			return usagef("expected list, retry or drop")
+		}
+		switch args[0] {
+		case "list":
+			q, err := openOfflineQueue(true)
+			if err != nil {
+				return err
+			}
+			defer q.close()
+			list := struct {
+				Payments []OfflineTransaction `json:"payments"`
+			}{Payments: q.items}
+			if list.Payments == nil {
+				list.Payments = []OfflineTransaction{}
+			}
+			return e.Print(list)
+
+		case "retry":
+			client, err := e.Client()
+			if err != nil {
+				return err
+			}
+			force := true
+			for {
+				q, err := openOfflineQueue(true)
+				if err != nil {
+					return err
+				}
+				left, next := retryOfflineQueue(client, q, *id, force)
+				q.close()
+				if left == 0 || !*wait {
+					return e.Print(struct {
+						Left int `json:"left"`
+					}{left})
+				}
+				log.Printf("Waiting until %s for the next attempt", next.Format(time.RFC3339))
+				time.Sleep(time.Until(next))
+				force = false
+			}
+
+		case "drop":
+			q, err := openOfflineQueue(true)
+			if err != nil {
+				return err
+			}
+			defer q.close()
+			var kept []OfflineTransaction
+			for _, tx := range q.items {
+				if *id != "" && tx.TransactionId != *id {
+					kept = append(kept, tx)
+				}
+			}
+			dropped := len(q.items) - len(kept)
+			if *id != "" && dropped == 0 {
+				return fmt.Errorf("transaction %s is not queued", *id)
+			}
+			q.items = kept
+			if err := q.save(); err != nil {
+				return err
+			}
+			return e.Print(struct {
+				Dropped int `json:"dropped"`
+			}{dropped})
+		}
+		return usagef("expected list, retry or drop")
+	}
+}
@@ -0,0 +1,485 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// shellBuiltins are the commands the shell handles itself, with their summaries.
+var shellBuiltins = map[string]string{
+	"login":   "Log in as a user for the following commands: login <user> [--password]",
+	"logout":  "Forget the logged-in user",
+	"whoami":  "Show the logged-in user and the gateway",
+	"history": "Show the commands entered so far",
+	"exit":    "Leave the shell (also quit or Ctrl-D)",
+	"quit":    "Leave the shell",
+}
+
+// maxShellHistory is how many lines the history file keeps.
+const maxShellHistory = 1000
+
+// splitWords splits a shell line into words. Single and double quotes group words and
+// a backslash escapes the next character outside single quotes.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, usagef("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// joinWords is the inverse of splitWords, quoting the words that need it.
+func joinWords(words []string) string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		if w != "" && !strings.ContainsAny(w, " \t'\"\\#") {
+			out[i] = w
+			continue
+		}
+		out[i] = "'" + strings.Replace(w, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(out, " ")
+}
+
+// redactPasswords removes --password and --senderPass with their values, so recorded
+// scripts and the history file never hold a password.
+func redactPasswords(words []string) []string {
+	var out []string
+	for i := 0; i < len(words); i++ {
+		name := strings.TrimLeft(words[i], "-")
+		if !strings.HasPrefix(words[i], "-") {
+			out = append(out, words[i])
+			continue
+		}
+		if name == "password" || name == "senderPass" {
+			i++ // the value is the next word
+			continue
+		}
+		if strings.HasPrefix(name, "password=") || strings.HasPrefix(name, "senderPass=") {
+			continue
+		}
+		out = append(out, words[i])
+	}
+	return out
+}
+
+// shell is an interactive session: one connection, the logged-in user and the history.
+type shell struct {
+	env *Env
+	// start are the options the shell was started with, for the password of login.
+	start       Options
+	editor      *lineEditor
+	lines       *bufio.Scanner
+	echo        bool
+	record      io.Writer
+	historyFile string
+}
+
+// shellCommand implements client shell. Commands are read with line editing, history
+// and tab completion when stdin is a terminal, and as a plain script otherwise.
+func shellCommand(fs *flag.FlagSet) func(e *Env, args []string) error {
+	record := fs.String("record", "", "Append the commands of the session to this file as a replayable script")
+	replay := fs.String("replay", "", "Run the commands of a recorded script instead of reading them from stdin")
+	keepGoing := fs.Bool("keep_going", false, "Go on with a script after a command fails")
+	return func(e *Env, args []string) error {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		sh := &shell{env: e, start: e.Options}
+		switch {
+		case *replay != "":
+			f, err := os.Open(*replay)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			sh.lines = bufio.NewScanner(f)
+			sh.echo = true
+		case isTerminal(int(os.Stdin.Fd())):
+			sh.editor = &lineEditor{
+				fd:       int(os.Stdin.Fd()),
+				in:       bufio.NewReader(os.Stdin),
+				out:      os.Stdout,
+				complete: completeShellLine,
+			}
+			if home, err := os.UserHomeDir(); err == nil {
+				sh.historyFile = filepath.Join(home, ".payment_client_history")
+				sh.loadHistory()
+			}
+		default:
+			sh.lines = bufio.NewScanner(os.Stdin)
+		}
+		if *record != "" {
+			f, err := os.OpenFile(*record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			fmt.Fprintf(f, "# %s shell session, %s\n", programName(), time.Now().Format(time.RFC3339))
+			sh.record = f
+		}
+		if sh.editor != nil {
+			fmt.Fprintf(os.Stdout, "Connected to %s. Type help for the commands, exit to leave.\n", e.Gateway)
+		}
+		return sh.run(*keepGoing || sh.editor != nil)
+	}
+}
+
+// prompt shows the logged-in user and the gateway.
+func (sh *shell) prompt() string {
+	if sh.env.User == "" {
+		return sh.env.Gateway + "> "
+	}
+	return sh.env.User + "@" + sh.env.Gateway + "> "
+}
+
+// readLine returns the next line from the terminal or the script.
+func (sh *shell) readLine() (string, error) {
+	if sh.editor != nil {
+		return sh.editor.readLine(sh.prompt())
+	}
+	if !sh.lines.Scan() {
+		if err := sh.lines.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return sh.lines.Text(), nil
+}
+
+// run reads and runs commands until exit or the end of the input. Without keepGoing the
+// first failing command ends the session with its error; with it, a script returns its
+// first error at the end.
+func (sh *shell) run(keepGoing bool) error {
+	var failed error
+	for n := 1; ; n++ {
+		line, err := sh.readLine()
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		words, err := splitWords(line)
+		if err == nil && (len(words) == 0 || strings.HasPrefix(words[0], "#")) {
+			continue
+		}
+		if err == nil && sh.echo {
+			fmt.Fprintf(os.Stderr, "%s%s\n", sh.prompt(), joinWords(redactPasswords(words)))
+		}
+		if sh.editor != nil {
+			sh.addHistory(words, line)
+		}
+		if err == nil {
+			if words[0] == "exit" || words[0] == "quit" {
+				break
+			}
+			err = sh.exec(words)
+		}
+		if err == nil || errors.Is(err, context.Canceled) {
+			sh.recordLine(words)
+			continue
+		}
+		var usage *usageError
+		if !errors.As(err, &usage) {
+			sh.recordLine(words)
+		}
+		if sh.editor == nil {
+			err = fmt.Errorf("line %d: %w", n, err)
+		}
+		if !keepGoing {
+			return err
+		}
+		log.Printf("Error: %v", err)
+		if failed == nil {
+			failed = err
+		}
+	}
+	if sh.editor != nil {
+		// Errors were shown as they happened; leaving the shell is not a failure.
+		return nil
+	}
+	return failed
+}
+
+// exec runs one command line of the shell.
+func (sh *shell) exec(words []string) error {
+	switch words[0] {
+	case "logout", "whoami", "history":
+		if len(words) > 1 {
+			return usagef("%s takes no arguments", words[0])
+		}
+	}
+	switch words[0] {
+	case "login":
+		return sh.login(words[1:])
+	case "logout":
+		sh.env.User, sh.env.Password = "", ""
+		sh.env.explicit.User, sh.env.explicit.Password = "", ""
+		return nil
+	case "whoami":
+		return sh.env.Print(struct {
+			User    string `json:"user"`
+			Gateway string `json:"gateway"`
+		}{sh.env.User, sh.env.Gateway})
+	case "history":
+		if sh.editor != nil {
+			for i, line := range sh.editor.history {
+				fmt.Fprintf(sh.env.Out, "%5d  %s\n", i+1, line)
+			}
+		}
+		return nil
+	case "help":
+		if len(words) == 1 {
+			PrintUsage()
+			fmt.Printf("\nShell commands:\n")
+			names := make([]string, 0, len(shellBuiltins))
+			for name := range shellBuiltins {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("  %-8s  %s\n", name, shellBuiltins[name])
+			}
+			return nil
+		}
+	case "shell":
+		return usagef("already in the shell")
+	}
+
+	var given Options
+	cmd, run, args, err := parseCommand(words, &given)
+	if err != nil || run == nil {
+		return err
+	}
+	session := sh.env
+	for _, setting := range []struct{ name, given, current string }{
+		{"gateway", given.Gateway, session.Gateway},
+		{"cert", given.Cert, session.Cert},
+		{"key", given.Key, session.Key},
+		{"ca", given.CA, session.CA},
+	} {
+		if setting.given != "" && setting.given != setting.current {
+			return usagef("--%s cannot be changed in the shell; start a new shell instead", setting.name)
+		}
+	}
+
+	// Options given on the line apply to this command only; the rest come from the
+	// session. Another user does not get the logged-in user's password.
+	opts, explicit := given, given
+	base, baseExplicit := session.Options, session.explicit
+	if given.User != "" && given.User != session.User && given.Password == "" {
+		base.Password, baseExplicit.Password = "", ""
+	}
+	opts.merge(base)
+	explicit.merge(baseExplicit)
+	if opts.Config == "" {
+		opts.Config = session.Config
+	}
+	if opts.Profile == "" {
+		opts.Profile = session.Profile
+	}
+	if err := opts.resolve(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	e := &Env{Options: opts, Out: session.Out, explicit: explicit, client: session.client, ctx: ctx}
+	err = run(e, args)
+	session.client = e.client
+	if ctx.Err() != nil {
+		log.Printf("%s interrupted", cmd.Name)
+		return context.Canceled
+	}
+	return err
+}
+
+// login makes user the user of the following commands after checking the password with
+// the gateway. The password is taken from --password, the options the shell was started
+// with (for the same user), $PAYMENT_CLIENT_PASSWORD or a prompt, in that order.
+func (sh *shell) login(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usagef("usage: login <user> [--password password]")
+	}
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	password := fs.String("password", "", "Password of the user")
+	if err := fs.Parse(args[1:]); err != nil {
+		return usagef("login: %v", err)
+	}
+	user, pass := args[0], *password
+	if pass == "" && user == sh.start.User {
+		pass = sh.start.Password
+	}
+	if pass == "" {
+		pass = os.Getenv("PAYMENT_CLIENT_PASSWORD")
+	}
+	if pass == "" && sh.editor != nil {
+		var err error
+		if pass, err = sh.editor.readPassword("Password for " + user + ": "); err != nil {
+			return err
+		}
+	}
+	if pass == "" {
+		return usagef("no password for %s; give --password or set PAYMENT_CLIENT_PASSWORD", user)
+	}
+
+	client, err := sh.env.base()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = client.As(user, pass).GetBalance(ctx, &paymentpb.BalanceRequest{Username: user})
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.Unavailable, codes.DeadlineExceeded:
+		return err
+	}
+	// Other errors, such as an unreachable bank, do not mean the credentials are wrong.
+	sh.env.User, sh.env.Password = user, pass
+	sh.env.explicit.User, sh.env.explicit.Password = user, ""
+	log.Printf("Logged in as %s", user)
+	return nil
+}
+
+// recordLine appends a command to the recorded script, without passwords.
+func (sh *shell) recordLine(words []string) {
+	if sh.record == nil || words[0] == "history" {
+		return
+	}
+	fmt.Fprintln(sh.record, joinWords(redactPasswords(words)))
+}
+
+// addHistory adds a line to the history and the history file. Lines with passwords are
+// stored without them.
+func (sh *shell) addHistory(words []string, line string) {
+	line = strings.TrimSpace(line)
+	if words != nil {
+		if redacted := redactPasswords(words); len(redacted) != len(words) {
+			line = joinWords(redacted)
+		}
+	}
+	n := len(sh.editor.history)
+	sh.editor.addHistory(line)
+	if sh.historyFile == "" || len(sh.editor.history) == n {
+		return
+	}
+	f, err := os.OpenFile(sh.historyFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// loadHistory reads the history file, keeping its last maxShellHistory lines.
+func (sh *shell) loadHistory() {
+	f, err := os.Open(sh.historyFile)
+	if err != nil {
+		return
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sh.editor.addHistory(scanner.Text())
+	}
+	f.Close()
+	if n := len(sh.editor.history); n > maxShellHistory {
+		sh.editor.history = sh.editor.history[n-maxShellHistory:]
+		// Rewrite the file so it does not keep growing.
+		ioutil.WriteFile(sh.historyFile, []byte(strings.Join(sh.editor.history, "\n")+"\n"), 0600)
+	}
+}
+
+// completeShellLine completes command names as the first word, then the flags and
+// positional words of the command.
+func completeShellLine(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && line[start-1] != ' ' {
+		start--
+	}
+	word := string(line[start:pos])
+	before := strings.Fields(string(line[:start]))
+
+	var options []string
+	if len(before) == 0 {
+		options = commandNames()
+		for name := range shellBuiltins {
+			options = append(options, name)
+		}
+	} else if cmd := findCommand(before[0]); cmd != nil {
+		if strings.HasPrefix(word, "-") {
+			for _, f := range append(commandFlagNames(cmd), globalFlags...) {
+				options = append(options, "--"+f)
+			}
+		} else {
+			options = commandWords(cmd)
+		}
+	} else if before[0] == "login" && strings.HasPrefix(word, "-") {
+		options = []string{"--password"}
+	}
+
+	var candidates []string
+	for _, o := range options {
+		if strings.HasPrefix(o, word) {
+			candidates = append(candidates, o)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
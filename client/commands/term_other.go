@@ -0,0 +1,20 @@
+//go:build !linux
+
+package commands
+
+import "errors"
+
+// Line editing needs termios, which is only wired up on Linux; elsewhere the shell reads
+// plain lines without history keys or completion.
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func setRawMode(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func setNoEcho(fd int) (func(), error) {
+	return nil, errors.New("turning off echo is not supported on this platform")
+}
@@ -8,6 +8,26 @@ var (
     TransactionHistory   = "./transaction_history.json"
     AccountsBankA        = "./accounts_bank_a.json"
     AccountsBankB        = "./accounts_bank_b.json"
+    SettlementLedger     = "./settlement_ledger.json"
+    FXRates              = "./fx_rates.json"
+    FeeSchedule          = "./fee_schedule.json"
+    Schedules            = "./scheduled_payments.json"
+    PaymentRequests      = "./payment_requests.json"
+    Escrows              = "./escrows.json"
+    EscrowAccount        = "escrow"
+    SettlementAccount    = "settlement"
+    PaymentBatches       = "./payment_batches.json"
+    SpendingLimits       = "./spending_limits.json"
+    RiskRules            = "./risk_rules.json"
+    RiskReviews          = "./risk_reviews.json"
+    Disputes             = "./disputes.json"
+    ApiKeys              = "./api_keys.json"
+    Invoices             = "./invoices.json"
+    Webhooks             = "./webhooks.json"
+    WebhookOutbox        = "./webhook_outbox.json"
+    AsyncPayments        = "./async_payments.json"
+    BankQueue            = "./bank_queue.json"
+    Operators            = "./operators.json"
     DefaultServerAddress = ":50051"
 )
 
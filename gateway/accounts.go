@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/jahnu05/Assignment-2/P-3/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
@@ -18,15 +20,38 @@ type registeredUser struct {
 	bank     string
 }
 
-// Register registers a new user.
+// Register registers a new user. A registered name is not taken over, and operator
+// names are reserved.
 func (s *PaymentGatewayServer) Register(ctx context.Context, req *paymentpb.RegisterRequest) (*paymentpb.RegisterResponse, error) {
+	if req.Username == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Username is required")
+	}
+	if isOperator(req.Username) || s.isPooledAccount(req.Username) {
+		return nil, status.Errorf(codes.PermissionDenied, "Username %s is reserved", req.Username)
+	}
 	log.Printf("Registering user: %s for bank: %s", req.Username, req.BankName)
-	s.users.Store(req.Username, registeredUser{password: req.Password, bank: req.BankName})
+	if _, exists := s.users.LoadOrStore(req.Username, registeredUser{password: req.Password, bank: req.BankName}); exists {
+		return nil, status.Errorf(codes.AlreadyExists, "User %s is already registered", req.Username)
+	}
 	return &paymentpb.RegisterResponse{Success: true, Message: "User registered successfully"}, nil
 }
 
+// isPooledAccount reports whether name is an account the gateway or the banks hold for
+// everyone: escrow, fees or inter-bank settlement. Registering it would let the
+// registrant spend other users' money.
+func (s *PaymentGatewayServer) isPooledAccount(name string) bool {
+	if name == config.EscrowAccount || name == config.SettlementAccount || strings.HasPrefix(name, config.SettlementAccount+"_") {
+		return true
+	}
+	return s.fees != nil && name == s.fees.FeeAccount
+}
+
 // Unregister removes a user from the gateway’s registry.
 func (s *PaymentGatewayServer) Unregister(ctx context.Context, req *paymentpb.UnregisterRequest) (*paymentpb.UnregisterResponse, error) {
+	// Otherwise anyone could free a name and register it again with their own password.
+	if caller := authenticatedUser(ctx); caller != req.Username && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "Cannot unregister %s", req.Username)
+	}
 	_, exists := s.users.Load(req.Username)
 	if !exists {
 		return &paymentpb.UnregisterResponse{Success: false, Message: "User not registered"}, nil
@@ -56,5 +81,5 @@ func (s *PaymentGatewayServer) GetBalance(ctx context.Context, req *paymentpb.Ba
 	if err != nil {
 		return nil, fmt.Errorf("error from bank server: %v", err)
 	}
-	return &paymentpb.BalanceResponse{Balance: bResp.Balance}, nil
+	return &paymentpb.BalanceResponse{Balance: bResp.Balance, Currency: bResp.Currency}, nil
 }
@@ -0,0 +1,297 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// apiKeyPrefix starts every merchant API key: mk_<keyId>.<secret>.
+const apiKeyPrefix = "mk_"
+
+// API key scopes and the RPCs each one allows. Methods not listed here, such as key
+// management and operator RPCs, cannot be called with an API key. "payments" accepts
+// payments: the merchant asks customers for money with payment requests and invoices,
+// and follows payments it made with a password login. Sending money (ProcessPayment,
+// SubmitPayment, SubmitPaymentBatch) pays out of the merchant's account and needs a
+// password login.
+var apiKeyScopes = map[string][]string{
+	"payments": {
+		"/payment.PaymentGateway/GetPaymentStatus",
+		"/payment.PaymentGateway/WaitForPayment",
+		"/payment.PaymentGateway/ListQueuedPayments",
+		"/payment.PaymentGateway/RequestPayment",
+		"/payment.PaymentGateway/ListPaymentRequests",
+		"/payment.PaymentGateway/CancelPaymentRequest",
+		"/payment.PaymentGateway/GetPaymentBatch",
+		"/payment.PaymentGateway/WatchPaymentBatch",
+		"/payment.PaymentGateway/CreateInvoice",
+		"/payment.PaymentGateway/GetInvoice",
+		"/payment.PaymentGateway/ListInvoices",
+	},
+	"refunds": {
+		"/payment.PaymentGateway/RefundPayment",
+	},
+	"history": {
+		"/payment.PaymentGateway/GetTransactionHistory",
+		"/payment.PaymentGateway/GetBalance",
+		"/payment.PaymentGateway/ListDisputes",
+		"/payment.PaymentGateway/SubscribeEvents",
+	},
+}
+
+// MerchantKey is a merchant's API key. Only a SHA-256 hash of the secret is stored.
+type MerchantKey struct {
+	KeyId      string   `json:"keyId"`
+	Merchant   string   `json:"merchant"`
+	Label      string   `json:"label,omitempty"`
+	Scopes     []string `json:"scopes"`
+	SecretHash string   `json:"secretHash"`
+	CreatedAt  string   `json:"createdAt"`
+	RotatedAt  string   `json:"rotatedAt,omitempty"`
+	RevokedAt  string   `json:"revokedAt,omitempty"`
+	// LastUsedAt is updated in memory on every use and saved with the next key change.
+	LastUsedAt string `json:"lastUsedAt,omitempty"`
+}
+
+func (k *MerchantKey) toProto() *paymentpb.ApiKey {
+	return &paymentpb.ApiKey{
+		KeyId:      k.KeyId,
+		Merchant:   k.Merchant,
+		Label:      k.Label,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		RotatedAt:  k.RotatedAt,
+		RevokedAt:  k.RevokedAt,
+		LastUsedAt: k.LastUsedAt,
+	}
+}
+
+// allows reports whether the key's scopes cover the RPC.
+func (k *MerchantKey) allows(method string) bool {
+	for _, scope := range k.Scopes {
+		for _, m := range apiKeyScopes[scope] {
+			if m == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecret gives the key a fresh secret, stores its hash and returns the full API key.
+func (k *MerchantKey) newSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Error generating API key: %v", err)
+	}
+	k.SecretHash = hashSecret(secret)
+	return apiKeyPrefix + k.KeyId + "." + secret, nil
+}
+
+// LoadApiKeys loads the merchant API keys. A missing file means there are none yet.
+func (s *PaymentGatewayServer) LoadApiKeys(filename string) error {
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+
+	s.apiKeysFile = filename
+	s.apiKeys = make(map[string]*MerchantKey)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*MerchantKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, k := range list {
+		s.apiKeys[k.KeyId] = k
+	}
+	return nil
+}
+
+// saveApiKeys writes all API keys to disk. The caller must hold apiKeyMu.
+func (s *PaymentGatewayServer) saveApiKeys() {
+	list := make([]*MerchantKey, 0, len(s.apiKeys))
+	for _, k := range s.apiKeys {
+		list = append(list, k)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling API keys: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.apiKeysFile, data, 0600); err != nil {
+		log.Printf("Error writing API keys: %v", err)
+	}
+}
+
+// authenticateApiKey checks an API key and that its scopes allow the RPC, and returns
+// the merchant it belongs to.
+func (s *PaymentGatewayServer) authenticateApiKey(apiKey, method string) (string, error) {
+	keyId, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, apiKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return "", status.Errorf(codes.Unauthenticated, "malformed API key")
+	}
+
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+	k, exists := s.apiKeys[keyId]
+	if !exists || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.SecretHash)) != 1 {
+		return "", status.Errorf(codes.Unauthenticated, "invalid API key")
+	}
+	if k.RevokedAt != "" {
+		return "", status.Errorf(codes.Unauthenticated, "API key %s was revoked", k.KeyId)
+	}
+	if _, registered := s.users.Load(k.Merchant); !registered {
+		return "", status.Errorf(codes.Unauthenticated, "merchant %s is not registered", k.Merchant)
+	}
+	if !k.allows(method) {
+		return "", status.Errorf(codes.PermissionDenied, "unauthorized: API key %s is not allowed to call %s", k.KeyId, method)
+	}
+	k.LastUsedAt = time.Now().Format(time.RFC3339)
+	return k.Merchant, nil
+}
+
+// CreateApiKey issues a new API key for a merchant. The full key is only returned here.
+func (s *PaymentGatewayServer) CreateApiKey(ctx context.Context, req *paymentpb.CreateApiKeyRequest) (*paymentpb.ApiKeyResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot create API keys for %s", caller, req.Merchant)
+	}
+	if _, ok := s.users.Load(req.Merchant); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "Merchant %s is not registered", req.Merchant)
+	}
+	if isOperator(req.Merchant) {
+		return nil, status.Errorf(codes.FailedPrecondition, "Operators cannot have API keys")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "At least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if _, ok := apiKeyScopes[scope]; !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown scope %q (use payments, refunds or history)", scope)
+		}
+	}
+
+	keyId, err := randomHex(8)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error generating API key: %v", err)
+	}
+	k := &MerchantKey{
+		KeyId:     keyId,
+		Merchant:  req.Merchant,
+		Label:     req.Label,
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	apiKey, err := k.newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+	s.apiKeys[k.KeyId] = k
+	s.saveApiKeys()
+	log.Printf("API key %s created for merchant %s with scopes %s", k.KeyId, k.Merchant, strings.Join(k.Scopes, ","))
+	return &paymentpb.ApiKeyResponse{Success: true, Message: "API key created", Key: k.toProto(), Secret: apiKey}, nil
+}
+
+// ListApiKeys returns a merchant's API keys without their secrets.
+func (s *PaymentGatewayServer) ListApiKeys(ctx context.Context, req *paymentpb.ListApiKeysRequest) (*paymentpb.ListApiKeysResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list API keys of %s", caller, req.Merchant)
+	}
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+	var list []*paymentpb.ApiKey
+	for _, k := range s.apiKeys {
+		if k.Merchant == req.Merchant {
+			list = append(list, k.toProto())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListApiKeysResponse{Keys: list}, nil
+}
+
+// activeKeyFor returns an unrevoked key the caller may manage: their own, or any key for
+// operators. The caller must hold apiKeyMu.
+func (s *PaymentGatewayServer) activeKeyFor(ctx context.Context, keyId string) (*MerchantKey, error) {
+	k, ok := s.apiKeys[keyId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "API key %s not found", keyId)
+	}
+	if caller := authenticatedUser(ctx); caller != k.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot manage API keys of %s", caller, k.Merchant)
+	}
+	if k.RevokedAt != "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "API key %s was revoked at %s", k.KeyId, k.RevokedAt)
+	}
+	return k, nil
+}
+
+// RotateApiKey replaces the secret of a key, keeping its id and scopes. The old secret
+// stops working immediately.
+func (s *PaymentGatewayServer) RotateApiKey(ctx context.Context, req *paymentpb.ApiKeyAction) (*paymentpb.ApiKeyResponse, error) {
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+	k, err := s.activeKeyFor(ctx, req.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := k.newSecret()
+	if err != nil {
+		return nil, err
+	}
+	k.RotatedAt = time.Now().Format(time.RFC3339)
+	s.saveApiKeys()
+	log.Printf("API key %s of merchant %s rotated", k.KeyId, k.Merchant)
+	return &paymentpb.ApiKeyResponse{Success: true, Message: "API key rotated", Key: k.toProto(), Secret: apiKey}, nil
+}
+
+// RevokeApiKey permanently disables a key.
+func (s *PaymentGatewayServer) RevokeApiKey(ctx context.Context, req *paymentpb.ApiKeyAction) (*paymentpb.ApiKeyResponse, error) {
+	s.apiKeyMu.Lock()
+	defer s.apiKeyMu.Unlock()
+	k, err := s.activeKeyFor(ctx, req.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	k.RevokedAt = time.Now().Format(time.RFC3339)
+	s.saveApiKeys()
+	log.Printf("API key %s of merchant %s revoked", k.KeyId, k.Merchant)
+	return &paymentpb.ApiKeyResponse{Success: true, Message: fmt.Sprintf("API key %s revoked", k.KeyId), Key: k.toProto()}, nil
+}
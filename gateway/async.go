@@ -0,0 +1,328 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Asynchronous payment states.
+const (
+	asyncPending    = "PENDING"
+	asyncProcessing = "PROCESSING"
+	asyncCommitted  = "COMMITTED"
+	asyncFailed     = "FAILED"
+	// The payment was held by risk screening; ReviewId names the review.
+	asyncHeldForReview = "HELD_FOR_REVIEW"
+	// A bank was unavailable and the payment moved to the bank queue; QueueId names it.
+	asyncQueuedForBank = "QUEUED_FOR_BANK"
+)
+
+const (
+	// maxQueuedPayments bounds how many accepted payments may wait for a worker.
+	maxQueuedPayments  = 1024
+	defaultPaymentWait = 30 * time.Second
+	maxPaymentWait     = 5 * time.Minute
+)
+
+// AsyncPayment is a payment accepted by SubmitPayment and executed by the worker pool.
+type AsyncPayment struct {
+	PaymentId   string                        `json:"paymentId"`
+	SubmittedBy string                        `json:"submittedBy"`
+	Request     *paymentpb.TransactionRequest `json:"request"`
+	Status      string                        `json:"status"`
+	Message     string                        `json:"message,omitempty"`
+	ErrorCode   string                        `json:"errorCode,omitempty"`
+	Fee         float64                       `json:"fee,omitempty"`
+	ReviewId    string                        `json:"reviewId,omitempty"`
+	QueueId     string                        `json:"queueId,omitempty"`
+	SubmittedAt string                        `json:"submittedAt"`
+	CompletedAt string                        `json:"completedAt,omitempty"`
+
+	// done is closed when the payment reaches a final state.
+	done chan struct{}
+}
+
+func (p *AsyncPayment) final() bool {
+	return p.Status != asyncPending && p.Status != asyncProcessing
+}
+
+// toProto converts the payment. The caller must hold asyncMu.
+func (p *AsyncPayment) toProto() *paymentpb.PaymentStatus {
+	return &paymentpb.PaymentStatus{
+		PaymentId:        p.PaymentId,
+		TransactionId:    p.Request.TransactionId,
+		SenderUsername:   p.Request.SenderUsername,
+		ReceiverUsername: p.Request.ReceiverUsername,
+		Amount:           p.Request.Amount,
+		Currency:         p.Request.Currency,
+		IdempotencyKey:   p.Request.IdempotencyKey,
+		Status:           p.Status,
+		Done:             p.final(),
+		Message:          p.Message,
+		ErrorCode:        p.ErrorCode,
+		Fee:              p.Fee,
+		ReviewId:         p.ReviewId,
+		QueueId:          p.QueueId,
+		SubmittedAt:      p.SubmittedAt,
+		CompletedAt:      p.CompletedAt,
+	}
+}
+
+// LoadAsyncPayments loads the asynchronous payments. Payments that were being processed
+// when the gateway stopped are marked failed, since their outcome is unknown; pending ones
+// are queued again by StartPaymentWorkers.
+func (s *PaymentGatewayServer) LoadAsyncPayments(filename string) error {
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+
+	s.asyncFile = filename
+	s.asyncPayments = make(map[string]*AsyncPayment)
+	s.asyncByKey = make(map[string]string)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*AsyncPayment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, p := range list {
+		p.done = make(chan struct{})
+		if p.Status == asyncProcessing {
+			p.Status = asyncFailed
+			p.ErrorCode = codes.Unknown.String()
+			p.Message = "Interrupted by a gateway restart; check the transaction history before resubmitting"
+			p.CompletedAt = time.Now().Format(time.RFC3339)
+		}
+		if p.final() {
+			close(p.done)
+		}
+		s.asyncPayments[p.PaymentId] = p
+		s.asyncByKey[p.Request.IdempotencyKey] = p.PaymentId
+	}
+	s.saveAsyncPayments()
+	return nil
+}
+
+// saveAsyncPayments writes all asynchronous payments to disk. The caller must hold asyncMu.
+func (s *PaymentGatewayServer) saveAsyncPayments() {
+	list := make([]*AsyncPayment, 0, len(s.asyncPayments))
+	for _, p := range s.asyncPayments {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SubmittedAt < list[j].SubmittedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling asynchronous payments: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.asyncFile, data, 0644); err != nil {
+		log.Printf("Error writing asynchronous payments: %v", err)
+	}
+}
+
+// StartPaymentWorkers starts the workers that execute submitted payments, and queues the
+// payments still pending from before a restart.
+func (s *PaymentGatewayServer) StartPaymentWorkers(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	s.asyncMu.Lock()
+	s.paymentQueue = make(chan *AsyncPayment, maxQueuedPayments)
+	var pending []*AsyncPayment
+	for _, p := range s.asyncPayments {
+		if p.Status == asyncPending {
+			pending = append(pending, p)
+		}
+	}
+	s.asyncMu.Unlock()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for p := range s.paymentQueue {
+				s.runAsyncPayment(p)
+			}
+		}()
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].SubmittedAt < pending[j].SubmittedAt })
+	go func() {
+		for _, p := range pending {
+			log.Printf("Resuming asynchronous payment %s", p.PaymentId)
+			s.paymentQueue <- p
+		}
+	}()
+}
+
+// runAsyncPayment executes a payment through ProcessPayment and records the outcome.
+func (s *PaymentGatewayServer) runAsyncPayment(p *AsyncPayment) {
+	s.asyncMu.Lock()
+	p.Status = asyncProcessing
+	s.saveAsyncPayments()
+	// ProcessPayment may fill in the request, e.g. for invoices, so it works on a copy.
+	req := proto.Clone(p.Request).(*paymentpb.TransactionRequest)
+	s.asyncMu.Unlock()
+
+	resp, err := s.payNow(context.Background(), req)
+
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+	switch {
+	case err != nil:
+		p.Status = asyncFailed
+		p.ErrorCode = status.Code(err).String()
+		p.Message = status.Convert(err).Message()
+	case resp.ReviewId != "":
+		p.Status = asyncHeldForReview
+		p.ReviewId = resp.ReviewId
+		p.Message = resp.Message
+	case resp.QueueId != "":
+		p.Status = asyncQueuedForBank
+		p.QueueId = resp.QueueId
+		p.Message = resp.Message
+	default:
+		p.Status = asyncCommitted
+		p.Message = resp.Message
+		p.Fee = resp.Fee
+	}
+	p.CompletedAt = time.Now().Format(time.RFC3339)
+	s.saveAsyncPayments()
+	close(p.done)
+	log.Printf("Asynchronous payment %s %s: %s", p.PaymentId, p.Status, p.Message)
+}
+
+// SubmitPayment accepts a payment and returns at once with status PENDING. The payment is
+// executed by the worker pool; GetPaymentStatus and WaitForPayment report its outcome.
+// Submitting the same idempotency key again returns the payment already accepted.
+func (s *PaymentGatewayServer) SubmitPayment(ctx context.Context, req *paymentpb.TransactionRequest) (*paymentpb.PaymentStatus, error) {
+	caller := authenticatedUser(ctx)
+	if req.SenderUsername != caller && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot pay on behalf of %s", caller, req.SenderUsername)
+	}
+	if req.IdempotencyKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
+	}
+	if _, ok := s.users.Load(req.SenderUsername); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "Sender %s is not registered", req.SenderUsername)
+	}
+	// Invoice payments take their receiver and amount from the invoice when processed.
+	if req.InvoiceReference == "" {
+		if _, ok := s.users.Load(req.ReceiverUsername); !ok {
+			return nil, status.Errorf(codes.FailedPrecondition, "Receiver %s is not registered", req.ReceiverUsername)
+		}
+		if req.Amount <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Amount must be positive")
+		}
+	}
+
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+	if s.paymentQueue == nil {
+		return nil, status.Errorf(codes.Unavailable, "Asynchronous payments are not enabled")
+	}
+	if id, exists := s.asyncByKey[req.IdempotencyKey]; exists {
+		p := s.asyncPayments[id]
+		if p.SubmittedBy != caller {
+			return nil, status.Errorf(codes.AlreadyExists, "Idempotency key %s is already in use", req.IdempotencyKey)
+		}
+		return p.toProto(), nil
+	}
+	if req.TransactionId == "" {
+		req.TransactionId = uuid.New().String()
+	}
+	p := &AsyncPayment{
+		PaymentId:   uuid.New().String(),
+		SubmittedBy: caller,
+		Request:     req,
+		Status:      asyncPending,
+		SubmittedAt: time.Now().Format(time.RFC3339),
+		done:        make(chan struct{}),
+	}
+	select {
+	case s.paymentQueue <- p:
+	default:
+		return nil, status.Errorf(codes.ResourceExhausted, "Payment queue is full, try again later")
+	}
+	s.asyncPayments[p.PaymentId] = p
+	s.asyncByKey[req.IdempotencyKey] = p.PaymentId
+	s.saveAsyncPayments()
+	log.Printf("Asynchronous payment %s accepted from %s", p.PaymentId, caller)
+	return p.toProto(), nil
+}
+
+// asyncPaymentFor returns a payment the caller may see: one they submitted or sent, or any
+// payment for operators. The caller must hold asyncMu.
+func (s *PaymentGatewayServer) asyncPaymentFor(ctx context.Context, paymentId string) (*AsyncPayment, error) {
+	p, ok := s.asyncPayments[paymentId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Payment %s not found", paymentId)
+	}
+	if caller := authenticatedUser(ctx); caller != p.SubmittedBy && caller != p.Request.SenderUsername && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot view payment %s", caller, paymentId)
+	}
+	return p, nil
+}
+
+// GetPaymentStatus returns the current status of an asynchronous payment.
+func (s *PaymentGatewayServer) GetPaymentStatus(ctx context.Context, req *paymentpb.PaymentStatusRequest) (*paymentpb.PaymentStatus, error) {
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+	p, err := s.asyncPaymentFor(ctx, req.PaymentId)
+	if err != nil {
+		return nil, err
+	}
+	return p.toProto(), nil
+}
+
+// WaitForPayment blocks until the payment reaches a final state, the timeout passes or
+// the caller's deadline expires, and returns the status at that point. Done tells
+// whether the payment finished.
+func (s *PaymentGatewayServer) WaitForPayment(ctx context.Context, req *paymentpb.WaitForPaymentRequest) (*paymentpb.PaymentStatus, error) {
+	timeout := defaultPaymentWait
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	if timeout > maxPaymentWait {
+		timeout = maxPaymentWait
+	}
+	// Answer a little before the caller's deadline, so it still learns the current status.
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline) - 500*time.Millisecond; d < timeout {
+			timeout = d
+		}
+	}
+
+	s.asyncMu.Lock()
+	p, err := s.asyncPaymentFor(ctx, req.PaymentId)
+	s.asyncMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	s.asyncMu.Lock()
+	defer s.asyncMu.Unlock()
+	return p.toProto(), nil
+}
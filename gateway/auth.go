@@ -9,39 +9,137 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
 )
 
+// operatorMethods lists the RPCs restricted to gateway operators.
+var operatorMethods = map[string]bool{
+	"/payment.PaymentGateway/GetSettlementReport": true,
+	"/payment.PaymentGateway/SettlePositions":     true,
+	"/payment.PaymentGateway/ReloadFXRates":       true,
+	"/payment.PaymentGateway/SetSpendingLimit":    true,
+	"/payment.PaymentGateway/SetUserRole":         true,
+	"/payment.PaymentGateway/ListReviews":         true,
+	"/payment.PaymentGateway/ApproveReview":       true,
+	"/payment.PaymentGateway/RejectReview":        true,
+	"/payment.PaymentGateway/InvestigateDispute":  true,
+	"/payment.PaymentGateway/AcceptDispute":       true,
+	"/payment.PaymentGateway/RejectDispute":       true,
+}
+
+// authenticatedUser returns the username the request was authenticated as.
+func authenticatedUser(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if usernames := md["username"]; len(usernames) > 0 {
+		return usernames[0]
+	}
+	return ""
+}
 
-// authInterceptor verifies metadata credentials by looking up the registered user's password.
+// authInterceptor verifies metadata credentials: a merchant API key, or a registered
+// user's username and password.
 func authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	if info.FullMethod == "/payment.PaymentGateway/Register" {
+	if info.FullMethod == "/payment.PaymentGateway/Register" || isPublicService(info.FullMethod) {
 		return handler(ctx, req)
 	}
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamAuthInterceptor applies the same credential check to streaming RPCs.
+func streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublicService(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	log.Printf("Stream: Method=%s", info.FullMethod)
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream hands the context produced by authenticate to a stream handler.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
+
+// authenticate checks the request credentials. A request carrying an "api-key" is
+// checked against the merchant keys and continues as that merchant: its username
+// metadata is replaced, so the rest of the gateway treats it like the merchant's own login.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		return nil, status.Errorf(16, "missing metadata")
 	}
+	if keys := md["api-key"]; len(keys) > 0 {
+		merchant, err := gatewayInstance.authenticateApiKey(keys[0], method)
+		if err != nil {
+			return nil, err
+		}
+		md = md.Copy()
+		md.Set("username", merchant)
+		md.Delete("password")
+		return metadata.NewIncomingContext(ctx, md), nil
+	}
+	return ctx, checkCredentials(ctx)
+}
+
+// checkCredentials verifies the username and password in the request metadata.
+func checkCredentials(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Errorf(16, "missing metadata")
+	}
 	usernames := md["username"]
 	passwords := md["password"]
 	if len(usernames) == 0 || len(passwords) == 0 {
-		return nil, status.Errorf(16, "missing credentials")
+		return status.Errorf(16, "missing credentials")
 	}
 	username := usernames[0]
 	providedPwd := passwords[0]
+	if op, ok := gatewayInstance.operators[username]; ok {
+		if !op.checkPassword(providedPwd) {
+			return status.Errorf(7, "invalid credentials")
+		}
+		return nil
+	}
 	val, exists := gatewayInstance.users.Load(username)
 	if !exists {
-		return nil, status.Errorf(16, "user not registered")
+		return status.Errorf(16, "user not registered")
 	}
 	regUser := val.(registeredUser)
 	if providedPwd != regUser.password {
-		return nil, status.Errorf(7, "invalid credentials")
+		return status.Errorf(7, "invalid credentials")
 	}
-	return handler(ctx, req)
+	return nil
 }
 
 // authorizationInterceptor ensures that for GetBalance and GetTransactionHistory requests,
-// the user can only view their own information.
+// the user can only view their own information (operators can view any user's history,
+// including its risk screening), that payments are only sent by their
+// sender, whether logged in or through an API key, and that operator RPCs are only
+// called by operators.
 func authorizationInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if operatorMethods[info.FullMethod] {
+		md, _ := metadata.FromIncomingContext(ctx)
+		usernames := md["username"]
+		if len(usernames) == 0 || !isOperator(usernames[0]) {
+			return nil, status.Errorf(7, "unauthorized: %s is restricted to operators", info.FullMethod)
+		}
+	}
+	if r, ok := req.(*paymentpb.TransactionRequest); ok && info.FullMethod == "/payment.PaymentGateway/ProcessPayment" {
+		if caller := authenticatedUser(ctx); caller != r.SenderUsername && !isOperator(caller) {
+			return nil, status.Errorf(7, "unauthorized: %s cannot pay on behalf of %s", caller, r.SenderUsername)
+		}
+	}
 	if info.FullMethod == "/payment.PaymentGateway/GetBalance" || info.FullMethod == "/payment.PaymentGateway/GetTransactionHistory" {
 		md, ok := metadata.FromIncomingContext(ctx)
 		if !ok {
@@ -58,7 +156,7 @@ func authorizationInterceptor(ctx context.Context, req interface{}, info *grpc.U
 				return nil, status.Errorf(7, "unauthorized: %s cannot view balance for %s", authenticatedUser, r.Username)
 			}
 		case *paymentpb.HistoryRequest:
-			if r.Username != authenticatedUser {
+			if r.Username != authenticatedUser && !isOperator(authenticatedUser) {
 				return nil, status.Errorf(7, "unauthorized: %s cannot view transaction history for %s", authenticatedUser, r.Username)
 			}
 		default:
@@ -68,8 +166,13 @@ func authorizationInterceptor(ctx context.Context, req interface{}, info *grpc.U
 	return handler(ctx, req)
 }
 
-// loggingInterceptor logs every request, response, and client certificate details.
+// loggingInterceptor logs every request, response, and client certificate details,
+// except calls to the health and reflection services.
 func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isPublicService(info.FullMethod) {
+		// Health probes would flood the log.
+		return handler(ctx, req)
+	}
 	if p, ok := peer.FromContext(ctx); ok {
 		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
 			if len(tlsInfo.State.PeerCertificates) > 0 {
@@ -80,6 +183,11 @@ func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySe
 	}
 	log.Printf("Request: Method=%s, Payload=%v", info.FullMethod, req)
 	resp, err := handler(ctx, req)
+	if keyResp, ok := resp.(*paymentpb.ApiKeyResponse); ok && keyResp.Secret != "" {
+		// Never log a newly issued API key.
+		log.Printf("Response: Method=%s, Response=<API key %s issued>, Error=%v", info.FullMethod, keyResp.Key.KeyId, err)
+		return resp, err
+	}
 	log.Printf("Response: Method=%s, Response=%v, Error=%v", info.FullMethod, resp, err)
 	return resp, err
 }
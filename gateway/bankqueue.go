@@ -0,0 +1,353 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Bank queue states.
+const (
+	bankQueued        = "queued"
+	bankDelivering    = "delivering"
+	bankCommitted     = "committed"
+	bankFailed        = "failed"
+	bankHeldForReview = "held_for_review"
+	bankExpired       = "expired"
+)
+
+const (
+	defaultBankQueueExpiry = 24 * time.Hour
+	// bankProbeTimeout bounds how long a bank may take to accept a connection.
+	bankProbeTimeout = 2 * time.Second
+)
+
+// queuedForBank is stored under the idempotency key of a payment waiting in the bank
+// queue, so retries are answered with the queue entry instead of queuing it again.
+type queuedForBank string
+
+// bankDeliveryKey marks the context of a queued payment being delivered and carries its
+// queue id. ProcessPayment then takes over the key parked for that entry, so the key is
+// never free while the payment is delivered.
+type bankDeliveryKey struct{}
+
+// QueuedPayment is a payment accepted while a participant bank was unreachable.
+type QueuedPayment struct {
+	QueueId          string                        `json:"queueId"`
+	Request          *paymentpb.TransactionRequest `json:"request"`
+	Status           string                        `json:"status"`
+	Banks            []string                      `json:"banks"`
+	UnavailableBanks []string                      `json:"unavailableBanks,omitempty"`
+	Attempts         int32                         `json:"attempts,omitempty"`
+	LastError        string                        `json:"lastError,omitempty"`
+	Message          string                        `json:"message,omitempty"`
+	CreatedAt        string                        `json:"createdAt"`
+	ExpiresAt        string                        `json:"expiresAt"`
+	CompletedAt      string                        `json:"completedAt,omitempty"`
+}
+
+func (q *QueuedPayment) toProto() *paymentpb.QueuedPayment {
+	return &paymentpb.QueuedPayment{
+		QueueId:          q.QueueId,
+		TransactionId:    q.Request.TransactionId,
+		SenderUsername:   q.Request.SenderUsername,
+		ReceiverUsername: q.Request.ReceiverUsername,
+		Amount:           q.Request.Amount,
+		Currency:         q.Request.Currency,
+		Status:           q.Status,
+		UnavailableBanks: q.UnavailableBanks,
+		Attempts:         q.Attempts,
+		LastError:        q.LastError,
+		Message:          q.Message,
+		CreatedAt:        q.CreatedAt,
+		ExpiresAt:        q.ExpiresAt,
+		CompletedAt:      q.CompletedAt,
+	}
+}
+
+// LoadBankQueue loads the queued payments. Waiting payments keep their idempotency key
+// parked; a delivery interrupted by a restart is marked failed, since its outcome is
+// unknown, and its key stays claimed as in doubt.
+func (s *PaymentGatewayServer) LoadBankQueue(filename string) error {
+	s.bankQueueMu.Lock()
+	defer s.bankQueueMu.Unlock()
+
+	s.bankQueueFile = filename
+	s.bankQueue = make(map[string]*QueuedPayment)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*QueuedPayment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, q := range list {
+		switch q.Status {
+		case bankQueued:
+			s.processedTxs.Store(q.Request.IdempotencyKey, queuedForBank(q.QueueId))
+		case bankDelivering:
+			s.processedTxs.Store(q.Request.IdempotencyKey, inDoubtStatus)
+			q.Status = bankFailed
+			q.Message = "Interrupted by a gateway restart; check the transaction history"
+			q.CompletedAt = time.Now().Format(time.RFC3339)
+		}
+		s.bankQueue[q.QueueId] = q
+	}
+	s.saveBankQueue()
+	return nil
+}
+
+// saveBankQueue writes the bank queue to disk. The caller must hold bankQueueMu.
+func (s *PaymentGatewayServer) saveBankQueue() {
+	list := make([]*QueuedPayment, 0, len(s.bankQueue))
+	for _, q := range s.bankQueue {
+		list = append(list, q)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling bank queue: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.bankQueueFile, data, 0644); err != nil {
+		log.Printf("Error writing bank queue: %v", err)
+	}
+}
+
+// bankReachable reports whether a bank accepts connections.
+func bankReachable(addr string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), bankProbeTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// paymentBanks returns the banks a payment needs: the shared bank or both banks, and the
+// fee bank when a fee is charged.
+func (s *PaymentGatewayServer) paymentBanks(req *paymentpb.TransactionRequest, sender, receiver registeredUser) []string {
+	banks := []string{sender.bank, receiver.bank}
+	if sender.bank == receiver.bank {
+		banks = []string{sender.bank}
+	}
+	if s.feeFor(req) > 0 {
+		banks = append(banks, s.fees.FeeBank)
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, b := range banks {
+		if b != "" && !seen[b] {
+			seen[b] = true
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
+
+// unavailableBanks returns the banks that do not accept connections.
+func unavailableBanks(banks []string) []string {
+	var down []string
+	for _, b := range banks {
+		if !bankReachable(b) {
+			down = append(down, b)
+		}
+	}
+	return down
+}
+
+// queueForBanks adds a payment to the bank queue and returns its queue id. banks are all
+// the banks the payment needs and down those currently unreachable.
+func (s *PaymentGatewayServer) queueForBanks(req *paymentpb.TransactionRequest, banks, down []string) (string, error) {
+	now := time.Now()
+	expires := now.Add(defaultBankQueueExpiry)
+	if req.QueueExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.QueueExpiresAt)
+		if err != nil {
+			return "", status.Errorf(codes.InvalidArgument, "Invalid queue expiry %q: %v", req.QueueExpiresAt, err)
+		}
+		if !t.After(now) {
+			return "", status.Errorf(codes.InvalidArgument, "Queue expiry %s is in the past", req.QueueExpiresAt)
+		}
+		expires = t
+	}
+	q := &QueuedPayment{
+		QueueId:          uuid.New().String(),
+		Request:          proto.Clone(req).(*paymentpb.TransactionRequest),
+		Status:           bankQueued,
+		Banks:            banks,
+		UnavailableBanks: down,
+		Message:          fmt.Sprintf("Waiting for %s", strings.Join(down, ", ")),
+		CreatedAt:        now.Format(time.RFC3339),
+		ExpiresAt:        expires.Format(time.RFC3339),
+	}
+
+	s.bankQueueMu.Lock()
+	defer s.bankQueueMu.Unlock()
+	if s.bankQueue == nil {
+		return "", status.Errorf(codes.Unavailable, "Bank %s is unavailable and payments cannot be queued", strings.Join(down, ", "))
+	}
+	s.bankQueue[q.QueueId] = q
+	s.saveBankQueue()
+	log.Printf("Payment %s queued as %s until %s is available", req.TransactionId, q.QueueId, strings.Join(down, ", "))
+	return q.QueueId, nil
+}
+
+// StartBankQueue retries queued payments at the given interval, delivering each once all
+// of its banks accept connections again.
+func (s *PaymentGatewayServer) StartBankQueue(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.retryBankQueue(time.Now())
+		}
+	}()
+}
+
+// retryBankQueue expires overdue payments and delivers the others whose banks are back,
+// oldest first.
+func (s *PaymentGatewayServer) retryBankQueue(now time.Time) {
+	s.bankQueueMu.Lock()
+	var waiting []*QueuedPayment
+	for _, q := range s.bankQueue {
+		if q.Status == bankQueued {
+			waiting = append(waiting, q)
+		}
+	}
+	s.bankQueueMu.Unlock()
+	sort.Slice(waiting, func(i, j int) bool { return waiting[i].CreatedAt < waiting[j].CreatedAt })
+
+	for _, q := range waiting {
+		if expires, err := time.Parse(time.RFC3339, q.ExpiresAt); err == nil && now.After(expires) {
+			s.expireQueuedPayment(q)
+			continue
+		}
+		if down := unavailableBanks(q.Banks); len(down) > 0 {
+			s.bankQueueMu.Lock()
+			q.UnavailableBanks = down
+			q.Message = fmt.Sprintf("Waiting for %s", strings.Join(down, ", "))
+			s.bankQueueMu.Unlock()
+			continue
+		}
+		s.deliverQueuedPayment(q)
+	}
+}
+
+// expireQueuedPayment gives up on a payment and releases its idempotency key, so the
+// sender may submit it again.
+func (s *PaymentGatewayServer) expireQueuedPayment(q *QueuedPayment) {
+	s.bankQueueMu.Lock()
+	q.Status = bankExpired
+	q.Message = fmt.Sprintf("Expired at %s while waiting for %s", q.ExpiresAt, strings.Join(q.UnavailableBanks, ", "))
+	q.CompletedAt = time.Now().Format(time.RFC3339)
+	s.saveBankQueue()
+	req := q.Request
+	s.bankQueueMu.Unlock()
+
+	s.processedTxs.Delete(req.IdempotencyKey)
+	s.publishPaymentStatus(req, bankExpired, q.Message)
+	s.enqueueWebhookEvent(eventPaymentAborted, TransactionRecord{
+		TransactionId: req.TransactionId,
+		Sender:        req.SenderUsername,
+		Receiver:      req.ReceiverUsername,
+		Amount:        req.Amount,
+		Timestamp:     q.CompletedAt,
+		Message:       q.Message,
+		Currency:      req.Currency,
+		SenderBank:    req.SenderBank,
+		ReceiverBank:  req.ReceiverBank,
+	})
+	log.Printf("Queued payment %s expired", q.QueueId)
+}
+
+// deliverQueuedPayment runs a queued payment through ProcessPayment. If it fails before
+// the prepare phase, which released its key, and a bank went away again, it stays
+// queued; any other outcome is final and is reported to the sender through the usual
+// payment events and webhooks.
+func (s *PaymentGatewayServer) deliverQueuedPayment(q *QueuedPayment) {
+	s.bankQueueMu.Lock()
+	q.Status = bankDelivering
+	q.Attempts++
+	s.saveBankQueue()
+	req := proto.Clone(q.Request).(*paymentpb.TransactionRequest)
+	s.bankQueueMu.Unlock()
+
+	req.QueueIfBankDown = false
+	resp, err := s.ProcessPayment(context.WithValue(context.Background(), bankDeliveryKey{}, q.QueueId), req)
+
+	s.bankQueueMu.Lock()
+	defer s.bankQueueMu.Unlock()
+	switch {
+	case err == nil && resp.ReviewId != "":
+		q.Status = bankHeldForReview
+		q.Message = fmt.Sprintf("%s %s", resp.Message, resp.ReviewId)
+	case err == nil:
+		q.Status = bankCommitted
+		q.Message = resp.Message
+	default:
+		q.LastError = status.Convert(err).Message()
+		if down := unavailableBanks(q.Banks); len(down) > 0 && s.requeueKey(req.IdempotencyKey, q.QueueId) {
+			q.Status = bankQueued
+			q.UnavailableBanks = down
+			s.saveBankQueue()
+			log.Printf("Queued payment %s still waiting for %s: %s", q.QueueId, strings.Join(down, ", "), q.LastError)
+			return
+		}
+		q.Status = bankFailed
+		q.Message = q.LastError
+	}
+	q.CompletedAt = time.Now().Format(time.RFC3339)
+	s.saveBankQueue()
+	log.Printf("Queued payment %s %s: %s", q.QueueId, q.Status, q.Message)
+}
+
+// requeueKey parks a key for the queue entry again. It succeeds only if the failed
+// delivery released the key, which ProcessPayment does only before the prepare phase;
+// a key kept as failed or in doubt, or claimed since, stays as it is.
+func (s *PaymentGatewayServer) requeueKey(idempotencyKey, queueId string) bool {
+	_, claimed := s.processedTxs.LoadOrStore(idempotencyKey, queuedForBank(queueId))
+	return !claimed
+}
+
+// ListQueuedPayments returns a user's payments in the bank queue, oldest first. Operators
+// may list any user's.
+func (s *PaymentGatewayServer) ListQueuedPayments(ctx context.Context, req *paymentpb.ListQueuedPaymentsRequest) (*paymentpb.ListQueuedPaymentsResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Username && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list queued payments of %s", caller, req.Username)
+	}
+	s.bankQueueMu.Lock()
+	defer s.bankQueueMu.Unlock()
+	var list []*paymentpb.QueuedPayment
+	for _, q := range s.bankQueue {
+		if q.Request.SenderUsername != req.Username {
+			continue
+		}
+		if !req.IncludeFinished && q.Status != bankQueued && q.Status != bankDelivering {
+			continue
+		}
+		list = append(list, q.toProto())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListQueuedPaymentsResponse{Payments: list}, nil
+}
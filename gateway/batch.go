@@ -0,0 +1,559 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Batch item states.
+const (
+	batchItemPending    = "pending"
+	batchItemProcessing = "processing"
+	batchItemSucceeded  = "succeeded"
+	batchItemFailed     = "failed"
+	// The payment was held by risk screening; Message names the review.
+	batchItemReview = "review"
+)
+
+const (
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 16
+	// maxBatchErrors bounds how many validation errors are reported for a rejected batch.
+	maxBatchErrors = 20
+	// batchRetention is how long a completed batch is kept once none of its items wait
+	// for a review.
+	batchRetention = 30 * 24 * time.Hour
+)
+
+// BatchItem is one payment of a batch and its outcome.
+type BatchItem struct {
+	Index            int32   `json:"index"`
+	TransactionId    string  `json:"transactionId"`
+	SenderUsername   string  `json:"senderUsername"`
+	ReceiverUsername string  `json:"receiverUsername"`
+	Amount           float64 `json:"amount"`
+	SenderBank       string  `json:"senderBank"`
+	ReceiverBank     string  `json:"receiverBank"`
+	Currency         string  `json:"currency,omitempty"`
+	IdempotencyKey   string  `json:"idempotencyKey"`
+	Status           string  `json:"status"`
+	Message          string  `json:"message,omitempty"`
+	Fee              float64 `json:"fee,omitempty"`
+	ReviewId         string  `json:"reviewId,omitempty"`
+}
+
+// batchItemUpdate is a line of the batch journal: the new state of one item.
+type batchItemUpdate struct {
+	BatchId  string  `json:"batchId"`
+	Index    int32   `json:"index"`
+	Status   string  `json:"status"`
+	Message  string  `json:"message,omitempty"`
+	Fee      float64 `json:"fee,omitempty"`
+	ReviewId string  `json:"reviewId,omitempty"`
+}
+
+// PaymentBatch is a set of payments submitted together and executed with bounded concurrency.
+type PaymentBatch struct {
+	BatchId     string       `json:"batchId"`
+	SubmittedBy string       `json:"submittedBy"`
+	CreatedAt   string       `json:"createdAt"`
+	CompletedAt string       `json:"completedAt,omitempty"`
+	Concurrency int          `json:"concurrency"`
+	Items       []*BatchItem `json:"items"`
+
+	// version is bumped on every change so watchers know when to send an update.
+	version int
+}
+
+// request builds the payment request for a batch item.
+func (item *BatchItem) request() *paymentpb.TransactionRequest {
+	return &paymentpb.TransactionRequest{
+		TransactionId:    item.TransactionId,
+		SenderUsername:   item.SenderUsername,
+		ReceiverUsername: item.ReceiverUsername,
+		Amount:           item.Amount,
+		SenderBank:       item.SenderBank,
+		ReceiverBank:     item.ReceiverBank,
+		IdempotencyKey:   item.IdempotencyKey,
+		Currency:         item.Currency,
+	}
+}
+
+// toProto converts the batch and its summary. The caller must hold batchMu.
+func (b *PaymentBatch) toProto() *paymentpb.PaymentBatchStatus {
+	summary := &paymentpb.PaymentBatchSummary{Total: int32(len(b.Items))}
+	var items []*paymentpb.PaymentBatchItem
+	for _, item := range b.Items {
+		switch item.Status {
+		case batchItemSucceeded:
+			summary.Succeeded++
+			summary.SucceededAmount += item.Amount
+			summary.Fees += item.Fee
+		case batchItemFailed:
+			summary.Failed++
+		case batchItemReview:
+			summary.HeldForReview++
+		default:
+			summary.Pending++
+		}
+		items = append(items, &paymentpb.PaymentBatchItem{
+			Index:            item.Index,
+			TransactionId:    item.TransactionId,
+			SenderUsername:   item.SenderUsername,
+			ReceiverUsername: item.ReceiverUsername,
+			Amount:           item.Amount,
+			IdempotencyKey:   item.IdempotencyKey,
+			Status:           item.Status,
+			Message:          item.Message,
+			Fee:              item.Fee,
+		})
+	}
+	return &paymentpb.PaymentBatchStatus{
+		BatchId:     b.BatchId,
+		SubmittedBy: b.SubmittedBy,
+		CreatedAt:   b.CreatedAt,
+		CompletedAt: b.CompletedAt,
+		Completed:   b.CompletedAt != "",
+		Items:       items,
+		Summary:     summary,
+	}
+}
+
+// LoadPaymentBatches loads persisted batches, applies the item updates journaled since
+// they were last saved, and resumes the batches that had not finished. Items that were
+// being processed when the gateway stopped are settled from the transaction history;
+// items that had not started are run.
+func (s *PaymentGatewayServer) LoadPaymentBatches(filename string) error {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	s.batchesFile = filename
+	s.batches = make(map[string]*PaymentBatch)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*PaymentBatch
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, b := range list {
+		s.batches[b.BatchId] = b
+	}
+	if err := s.replayBatchJournal(); err != nil {
+		return err
+	}
+
+	var records []TransactionRecord
+	loaded := false
+	for _, b := range s.batches {
+		if b.CompletedAt != "" {
+			continue
+		}
+		for _, item := range b.Items {
+			if item.Status != batchItemProcessing {
+				continue
+			}
+			if !loaded {
+				records = s.loadTransactionRecords()
+				loaded = true
+			}
+			recoverBatchItem(item, records)
+		}
+		log.Printf("Resuming payment batch %s", b.BatchId)
+		go s.runBatch(b)
+	}
+	s.pruneBatches(time.Now())
+	s.saveBatches()
+	return nil
+}
+
+// replayBatchJournal applies the journaled item updates to the loaded batches. The
+// caller must hold batchMu.
+func (s *PaymentGatewayServer) replayBatchJournal() error {
+	data, err := ioutil.ReadFile(s.batchJournalFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		var u batchItemUpdate
+		// A line cut short by a crash is the last one and is skipped.
+		if line == "" || json.Unmarshal([]byte(line), &u) != nil {
+			continue
+		}
+		b, ok := s.batches[u.BatchId]
+		if !ok || u.Index < 1 || int(u.Index) > len(b.Items) {
+			continue
+		}
+		item := b.Items[u.Index-1]
+		item.Status, item.Message, item.Fee, item.ReviewId = u.Status, u.Message, u.Fee, u.ReviewId
+	}
+	return nil
+}
+
+// recoverBatchItem settles an item that was in flight when the gateway stopped from the
+// last record of its payment. Without a record its outcome is unknown and it is marked
+// failed.
+func recoverBatchItem(item *BatchItem, records []TransactionRecord) {
+	item.Status = batchItemFailed
+	item.Message = "Interrupted by a gateway restart; check the transaction history before resubmitting"
+	for _, rec := range records {
+		if rec.TransactionId != item.TransactionId || rec.Sender != item.SenderUsername || rec.RefundOf != "" {
+			continue
+		}
+		switch rec.Status {
+		case "":
+			item.Status, item.Message, item.Fee = batchItemSucceeded, "Transaction committed successfully", rec.Fee
+			return
+		case riskReview:
+			item.Status, item.Message, item.ReviewId = batchItemReview, fmt.Sprintf("Payment held for manual review %s", rec.ReviewId), rec.ReviewId
+		default:
+			item.Status, item.Message = batchItemFailed, rec.Message
+		}
+	}
+}
+
+// pruneBatches drops completed batches past batchRetention, unless an item still waits
+// for a review. The caller must hold batchMu.
+func (s *PaymentGatewayServer) pruneBatches(now time.Time) {
+	for id, b := range s.batches {
+		completed, err := time.Parse(time.RFC3339, b.CompletedAt)
+		if err != nil || now.Sub(completed) < batchRetention {
+			continue
+		}
+		waiting := false
+		for _, item := range b.Items {
+			waiting = waiting || item.Status == batchItemReview
+		}
+		if !waiting {
+			delete(s.batches, id)
+		}
+	}
+}
+
+// batchJournalFile is the file item updates are appended to between full saves.
+func (s *PaymentGatewayServer) batchJournalFile() string {
+	return s.batchesFile + ".log"
+}
+
+// saveBatches writes all batches to disk and empties the journal, whose updates the file
+// now includes. The caller must hold batchMu.
+func (s *PaymentGatewayServer) saveBatches() {
+	list := make([]*PaymentBatch, 0, len(s.batches))
+	for _, b := range s.batches {
+		list = append(list, b)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling payment batches: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.batchesFile, data, 0644); err != nil {
+		log.Printf("Error writing payment batches: %v", err)
+		return
+	}
+	if err := os.Remove(s.batchJournalFile()); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing payment batch journal: %v", err)
+	}
+}
+
+// journalBatchItem appends the state of one item to the journal, so an item update costs
+// one short write instead of rewriting every batch. The caller must hold batchMu.
+func (s *PaymentGatewayServer) journalBatchItem(batch *PaymentBatch, item *BatchItem) {
+	line, err := json.Marshal(batchItemUpdate{
+		BatchId:  batch.BatchId,
+		Index:    item.Index,
+		Status:   item.Status,
+		Message:  item.Message,
+		Fee:      item.Fee,
+		ReviewId: item.ReviewId,
+	})
+	if err == nil {
+		var f *os.File
+		f, err = os.OpenFile(s.batchJournalFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(append(line, '\n'))
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Error journaling payment batch %s: %v; saving all batches instead", batch.BatchId, err)
+		s.saveBatches()
+	}
+}
+
+// validateBatchItem checks one payment of a batch before anything is executed, sets the
+// banks both users registered with, and fills in the transaction id and idempotency key
+// when they are missing.
+func (s *PaymentGatewayServer) validateBatchItem(caller, batchId string, i int, req *paymentpb.TransactionRequest) error {
+	if req.SenderUsername != caller && !isOperator(caller) {
+		return fmt.Errorf("%s cannot pay on behalf of %s", caller, req.SenderUsername)
+	}
+	if _, ok := s.users.Load(req.SenderUsername); !ok {
+		return fmt.Errorf("sender %s is not registered", req.SenderUsername)
+	}
+	if _, ok := s.users.Load(req.ReceiverUsername); !ok {
+		return fmt.Errorf("receiver %s is not registered", req.ReceiverUsername)
+	}
+	if req.SenderUsername == req.ReceiverUsername {
+		return fmt.Errorf("sender and receiver are the same user")
+	}
+	if req.Amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	req.SenderBank = s.bankOf("", req.SenderUsername)
+	req.ReceiverBank = s.bankOf("", req.ReceiverUsername)
+	if req.TransactionId == "" {
+		req.TransactionId = fmt.Sprintf("%s-%d", batchId, i+1)
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = fmt.Sprintf("%s-%d", batchId, i+1)
+	}
+	return nil
+}
+
+// SubmitPaymentBatch validates every payment of the batch up front and, if all are valid,
+// executes them in the background. Progress is available from GetPaymentBatch and
+// WatchPaymentBatch.
+func (s *PaymentGatewayServer) SubmitPaymentBatch(ctx context.Context, req *paymentpb.PaymentBatchRequest) (*paymentpb.PaymentBatchResponse, error) {
+	caller := authenticatedUser(ctx)
+	if len(req.Items) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Batch has no payments")
+	}
+	batchId := req.BatchId
+	if batchId == "" {
+		batchId = uuid.New().String()
+	}
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	if existing, ok := s.batches[batchId]; ok {
+		if existing.SubmittedBy != caller {
+			return nil, status.Errorf(codes.AlreadyExists, "Batch %s already exists", batchId)
+		}
+		return &paymentpb.PaymentBatchResponse{Success: true, Message: "Batch already submitted", BatchId: batchId, ItemCount: int32(len(existing.Items))}, nil
+	}
+
+	var problems []string
+	keys := make(map[string]int)
+	for i, item := range req.Items {
+		if err := s.validateBatchItem(caller, batchId, i, item); err != nil {
+			problems = append(problems, fmt.Sprintf("item %d: %v", i+1, err))
+			continue
+		}
+		if prev, dup := keys[item.IdempotencyKey]; dup {
+			problems = append(problems, fmt.Sprintf("item %d: idempotency key repeats item %d", i+1, prev))
+			continue
+		}
+		keys[item.IdempotencyKey] = i + 1
+	}
+	if len(problems) > 0 {
+		if len(problems) > maxBatchErrors {
+			problems = append(problems[:maxBatchErrors], fmt.Sprintf("and %d more", len(problems)-maxBatchErrors))
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "Batch rejected: %s", strings.Join(problems, "; "))
+	}
+
+	concurrency := int(req.Concurrency)
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency > maxBatchConcurrency {
+		concurrency = maxBatchConcurrency
+	}
+	batch := &PaymentBatch{
+		BatchId:     batchId,
+		SubmittedBy: caller,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		Concurrency: concurrency,
+	}
+	for i, item := range req.Items {
+		batch.Items = append(batch.Items, &BatchItem{
+			Index:            int32(i + 1),
+			TransactionId:    item.TransactionId,
+			SenderUsername:   item.SenderUsername,
+			ReceiverUsername: item.ReceiverUsername,
+			Amount:           item.Amount,
+			SenderBank:       item.SenderBank,
+			ReceiverBank:     item.ReceiverBank,
+			Currency:         item.Currency,
+			IdempotencyKey:   item.IdempotencyKey,
+			Status:           batchItemPending,
+		})
+	}
+	s.batches[batchId] = batch
+	s.saveBatches()
+	log.Printf("Payment batch %s submitted by %s with %d payments", batchId, caller, len(batch.Items))
+	go s.runBatch(batch)
+
+	return &paymentpb.PaymentBatchResponse{Success: true, Message: "Batch accepted", BatchId: batchId, ItemCount: int32(len(batch.Items))}, nil
+}
+
+// runBatch executes the pending items of a batch through ProcessPayment, at most
+// Concurrency at a time, and marks the batch completed once all have finished.
+func (s *PaymentGatewayServer) runBatch(batch *PaymentBatch) {
+	s.batchMu.Lock()
+	var pending []*BatchItem
+	for _, item := range batch.Items {
+		if item.Status == batchItemPending {
+			pending = append(pending, item)
+		}
+	}
+	concurrency := batch.Concurrency
+	s.batchMu.Unlock()
+
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, item := range pending {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(item *BatchItem) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			s.setBatchItem(batch, item, batchItemProcessing, "", 0)
+			resp, err := s.payNow(context.Background(), item.request())
+			if err != nil {
+				s.setBatchItem(batch, item, batchItemFailed, status.Convert(err).Message(), 0)
+				return
+			}
+			if resp.ReviewId != "" {
+				s.batchMu.Lock()
+				item.ReviewId = resp.ReviewId
+				s.batchMu.Unlock()
+				s.setBatchItem(batch, item, batchItemReview, fmt.Sprintf("%s %s", resp.Message, resp.ReviewId), 0)
+				return
+			}
+			s.setBatchItem(batch, item, batchItemSucceeded, resp.Message, resp.Fee)
+		}(item)
+	}
+	wg.Wait()
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	batch.CompletedAt = time.Now().Format(time.RFC3339)
+	batch.version++
+	s.pruneBatches(time.Now())
+	s.saveBatches()
+	summary := batch.toProto().Summary
+	log.Printf("Payment batch %s completed: %d succeeded, %d failed, %d held for review, %.2f paid, %.2f fees",
+		batch.BatchId, summary.Succeeded, summary.Failed, summary.HeldForReview, summary.SucceededAmount, summary.Fees)
+}
+
+// setBatchItem records the state of a batch item and journals it.
+func (s *PaymentGatewayServer) setBatchItem(batch *PaymentBatch, item *BatchItem, state, message string, fee float64) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	item.Status = state
+	item.Message = message
+	item.Fee = fee
+	batch.version++
+	s.journalBatchItem(batch, item)
+}
+
+// batchReviewResolved settles the batch item whose payment was held by the given review,
+// given the error and fee of that payment once the review was decided.
+func (s *PaymentGatewayServer) batchReviewResolved(reviewId string, fee float64, payErr error) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	for _, batch := range s.batches {
+		for _, item := range batch.Items {
+			if item.Status != batchItemReview || item.ReviewId != reviewId {
+				continue
+			}
+			if payErr != nil {
+				item.Status, item.Message, item.Fee = batchItemFailed, status.Convert(payErr).Message(), 0
+			} else {
+				item.Status, item.Message, item.Fee = batchItemSucceeded, "Transaction committed successfully after review", fee
+			}
+			batch.version++
+			s.journalBatchItem(batch, item)
+			return
+		}
+	}
+}
+
+// batchFor returns a batch the caller may view: their own, or any batch for operators.
+// The caller must hold batchMu.
+func (s *PaymentGatewayServer) batchFor(ctx context.Context, batchId string) (*PaymentBatch, error) {
+	batch, ok := s.batches[batchId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Batch %s not found", batchId)
+	}
+	if caller := authenticatedUser(ctx); caller != batch.SubmittedBy && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot view batch %s", caller, batchId)
+	}
+	return batch, nil
+}
+
+// GetPaymentBatch returns the per-item status and summary of a batch.
+func (s *PaymentGatewayServer) GetPaymentBatch(ctx context.Context, req *paymentpb.PaymentBatchStatusRequest) (*paymentpb.PaymentBatchStatus, error) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	batch, err := s.batchFor(ctx, req.BatchId)
+	if err != nil {
+		return nil, err
+	}
+	return batch.toProto(), nil
+}
+
+// WatchPaymentBatch streams the status of a batch each time it changes, ending with the
+// completed batch and its final summary.
+func (s *PaymentGatewayServer) WatchPaymentBatch(req *paymentpb.PaymentBatchStatusRequest, stream grpc.ServerStreamingServer[paymentpb.PaymentBatchStatus]) error {
+	sent := -1
+	for {
+		s.batchMu.Lock()
+		batch, err := s.batchFor(stream.Context(), req.BatchId)
+		if err != nil {
+			s.batchMu.Unlock()
+			return err
+		}
+		version := batch.version
+		var update *paymentpb.PaymentBatchStatus
+		if version != sent {
+			update = batch.toProto()
+		}
+		s.batchMu.Unlock()
+
+		if update != nil {
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+			sent = version
+			if update.Completed {
+				return nil
+			}
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
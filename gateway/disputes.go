@@ -0,0 +1,335 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Dispute states. A dispute is "reversing" while the chargeback of an accepted dispute runs.
+const (
+	disputeOpen          = "open"
+	disputeInvestigating = "investigating"
+	disputeReversing     = "reversing"
+	disputeAccepted      = "accepted"
+	disputeRejected      = "rejected"
+)
+
+// Dispute is a payer's claim against a committed payment.
+type Dispute struct {
+	DisputeId             string  `json:"disputeId"`
+	TransactionId         string  `json:"transactionId"`
+	Sender                string  `json:"sender"`
+	Receiver              string  `json:"receiver"`
+	Amount                float64 `json:"amount"`
+	Currency              string  `json:"currency,omitempty"`
+	Reason                string  `json:"reason"`
+	Evidence              string  `json:"evidence,omitempty"`
+	Status                string  `json:"status"`
+	CreatedAt             string  `json:"createdAt"`
+	UpdatedAt             string  `json:"updatedAt"`
+	ResolvedBy            string  `json:"resolvedBy,omitempty"`
+	Resolution            string  `json:"resolution,omitempty"`
+	ReversalTransactionId string  `json:"reversalTransactionId,omitempty"`
+	ReversedAmount        float64 `json:"reversedAmount,omitempty"`
+	// InDoubt marks a chargeback that partially committed. The dispute stays reversing
+	// until an operator reconciles the reversal with the banks.
+	InDoubt bool `json:"inDoubt,omitempty"`
+}
+
+func (d *Dispute) toProto() *paymentpb.Dispute {
+	return &paymentpb.Dispute{
+		DisputeId:             d.DisputeId,
+		TransactionId:         d.TransactionId,
+		Sender:                d.Sender,
+		Receiver:              d.Receiver,
+		Amount:                d.Amount,
+		Currency:              d.Currency,
+		Reason:                d.Reason,
+		Evidence:              d.Evidence,
+		Status:                d.Status,
+		CreatedAt:             d.CreatedAt,
+		UpdatedAt:             d.UpdatedAt,
+		ResolvedBy:            d.ResolvedBy,
+		Resolution:            d.Resolution,
+		ReversalTransactionId: d.ReversalTransactionId,
+		ReversedAmount:        d.ReversedAmount,
+	}
+}
+
+// active reports whether the dispute is still waiting for a decision.
+func (d *Dispute) active() bool {
+	return d.Status == disputeOpen || d.Status == disputeInvestigating || d.Status == disputeReversing
+}
+
+// chargebackMessage is the history message of a dispute's reversal; LoadDisputes looks
+// for it to tell whether an interrupted chargeback was committed.
+func chargebackMessage(d *Dispute) string {
+	return fmt.Sprintf("Chargeback of transaction %s for dispute %s", d.TransactionId, d.DisputeId)
+}
+
+// LoadDisputes loads persisted disputes. A missing file means there are none yet.
+func (s *PaymentGatewayServer) LoadDisputes(filename string) error {
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+
+	s.disputesFile = filename
+	s.disputes = make(map[string]*Dispute)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*Dispute
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, d := range list {
+		s.disputes[d.DisputeId] = d
+		if d.Status != disputeReversing || d.InDoubt {
+			continue
+		}
+		// A chargeback interrupted by a restart either made it into the history or
+		// was aborted at the banks.
+		d.Status = disputeInvestigating
+		for _, rec := range s.loadTransactionRecords() {
+			if rec.RefundOf == d.TransactionId && rec.Message == chargebackMessage(d) {
+				d.Status = disputeAccepted
+				d.ReversalTransactionId = rec.TransactionId
+				d.ReversedAmount = rec.Amount
+			}
+		}
+		log.Printf("Dispute %s was reversing when the gateway stopped; now %s", d.DisputeId, d.Status)
+	}
+	s.saveDisputes()
+	return nil
+}
+
+// saveDisputes writes all disputes to disk. The caller must hold disputeMu.
+func (s *PaymentGatewayServer) saveDisputes() {
+	list := make([]*Dispute, 0, len(s.disputes))
+	for _, d := range s.disputes {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling disputes: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.disputesFile, data, 0644); err != nil {
+		log.Printf("Error writing disputes: %v", err)
+	}
+}
+
+// latestDisputes maps each disputed transaction to its most recent dispute.
+func (s *PaymentGatewayServer) latestDisputes() map[string]*Dispute {
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	latest := make(map[string]*Dispute)
+	for _, d := range s.disputes {
+		if prev, ok := latest[d.TransactionId]; !ok || d.CreatedAt > prev.CreatedAt {
+			copied := *d
+			latest[d.TransactionId] = &copied
+		}
+	}
+	return latest
+}
+
+// OpenDispute lets the payer of a committed payment contest it. A payment can have only
+// one dispute awaiting a decision at a time, and only what has not been refunded yet can
+// be disputed.
+func (s *PaymentGatewayServer) OpenDispute(ctx context.Context, req *paymentpb.OpenDisputeRequest) (*paymentpb.DisputeResponse, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "A reason must be provided")
+	}
+	original, refunded, err := s.findTransaction(req.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+	caller := authenticatedUser(ctx)
+	if caller != original.Sender && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: only %s or an operator can dispute transaction %s", original.Sender, original.TransactionId)
+	}
+	remaining := math.Round((receivedAmountOf(original)-refunded)*100) / 100
+	if remaining <= 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Transaction %s has already been fully refunded", original.TransactionId)
+	}
+	currency := original.ReceivedCurrency
+	if currency == "" {
+		currency = original.Currency
+	}
+
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	for _, d := range s.disputes {
+		if d.TransactionId == original.TransactionId && d.active() {
+			return nil, status.Errorf(codes.AlreadyExists, "Transaction %s already has dispute %s (%s)", original.TransactionId, d.DisputeId, d.Status)
+		}
+	}
+	now := time.Now().Format(time.RFC3339)
+	d := &Dispute{
+		DisputeId:     uuid.New().String(),
+		TransactionId: original.TransactionId,
+		Sender:        original.Sender,
+		Receiver:      original.Receiver,
+		Amount:        remaining,
+		Currency:      currency,
+		Reason:        req.Reason,
+		Evidence:      req.Evidence,
+		Status:        disputeOpen,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	s.disputes[d.DisputeId] = d
+	s.saveDisputes()
+	log.Printf("Dispute %s opened by %s against transaction %s for %.2f %s", d.DisputeId, caller, d.TransactionId, d.Amount, d.Currency)
+	return &paymentpb.DisputeResponse{Success: true, Message: "Dispute opened", Dispute: d.toProto()}, nil
+}
+
+// moveDispute changes a dispute to the given state on behalf of an operator, provided
+// it is currently in one of the allowed states.
+func (s *PaymentGatewayServer) moveDispute(ctx context.Context, req *paymentpb.DisputeAction, state string, from ...string) (*Dispute, error) {
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	d, ok := s.disputes[req.DisputeId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Dispute %s not found", req.DisputeId)
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || d.Status == f
+	}
+	if !allowed {
+		return nil, status.Errorf(codes.FailedPrecondition, "Dispute %s is %s", d.DisputeId, d.Status)
+	}
+	d.Status = state
+	d.UpdatedAt = time.Now().Format(time.RFC3339)
+	d.ResolvedBy = authenticatedUser(ctx)
+	if req.Note != "" {
+		d.Resolution = req.Note
+	}
+	s.saveDisputes()
+	return d, nil
+}
+
+// InvestigateDispute marks an open dispute as under investigation.
+func (s *PaymentGatewayServer) InvestigateDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	d, err := s.moveDispute(ctx, req, disputeInvestigating, disputeOpen)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Dispute %s under investigation by %s", d.DisputeId, d.ResolvedBy)
+	return &paymentpb.DisputeResponse{Success: true, Message: "Dispute under investigation", Dispute: s.disputeProto(d)}, nil
+}
+
+// RejectDispute closes a dispute without moving any money.
+func (s *PaymentGatewayServer) RejectDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	d, err := s.moveDispute(ctx, req, disputeRejected, disputeOpen, disputeInvestigating)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Dispute %s rejected by %s", d.DisputeId, d.ResolvedBy)
+	return &paymentpb.DisputeResponse{Success: true, Message: "Dispute rejected", Dispute: s.disputeProto(d)}, nil
+}
+
+// AcceptDispute upholds a dispute and charges the payment back: what has not been refunded
+// of the disputed amount is returned to the payer through both banks, recorded as a refund
+// of the original payment. If the chargeback fails the dispute stays under investigation.
+func (s *PaymentGatewayServer) AcceptDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	d, err := s.moveDispute(ctx, req, disputeReversing, disputeOpen, disputeInvestigating)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serialized with refunds so the two together never exceed the original amount.
+	s.refundMu.Lock()
+	original, refunded, err := s.findTransaction(d.TransactionId)
+	if status.Code(err) == codes.NotFound {
+		err = nil
+	}
+	var reversal *TransactionRecord
+	amount := 0.0
+	if original != nil {
+		remaining := math.Round((receivedAmountOf(original)-refunded)*100) / 100
+		amount = math.Min(d.Amount, remaining)
+		if amount > 0 {
+			reversal, err = s.reverseTransaction(ctx, original, amount, chargebackMessage(d))
+		}
+	}
+	s.refundMu.Unlock()
+
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	d.UpdatedAt = time.Now().Format(time.RFC3339)
+	if partiallyCommitted(err) {
+		// Part of the chargeback may have moved money, so the dispute must not go back to
+		// investigating, from where it could be charged back again.
+		d.InDoubt = true
+		d.ReversalTransactionId = reversal.TransactionId
+		s.saveDisputes()
+		log.Printf("Dispute %s: chargeback %s partially committed; left reversing for reconciliation", d.DisputeId, reversal.TransactionId)
+		return nil, status.Errorf(codes.DataLoss, "Chargeback of dispute %s partially committed: %s", d.DisputeId, status.Convert(err).Message())
+	}
+	if err != nil {
+		d.Status = disputeInvestigating
+		s.saveDisputes()
+		return nil, status.Errorf(status.Code(err), "Chargeback of dispute %s failed: %s", d.DisputeId, status.Convert(err).Message())
+	}
+	d.Status = disputeAccepted
+	message := "Dispute accepted; nothing left to charge back"
+	if reversal != nil {
+		d.ReversalTransactionId = reversal.TransactionId
+		d.ReversedAmount = amount
+		message = "Dispute accepted and charged back"
+	}
+	s.saveDisputes()
+	log.Printf("Dispute %s accepted by %s: %.2f %s charged back", d.DisputeId, d.ResolvedBy, d.ReversedAmount, d.Currency)
+	return &paymentpb.DisputeResponse{Success: true, Message: message, Dispute: d.toProto()}, nil
+}
+
+// disputeProto converts a dispute while holding disputeMu.
+func (s *PaymentGatewayServer) disputeProto(d *Dispute) *paymentpb.Dispute {
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	return d.toProto()
+}
+
+// ListDisputes returns the disputes a user is party to, or every dispute when an
+// operator leaves the username empty.
+func (s *PaymentGatewayServer) ListDisputes(ctx context.Context, req *paymentpb.ListDisputesRequest) (*paymentpb.ListDisputesResponse, error) {
+	caller := authenticatedUser(ctx)
+	if caller != req.Username && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list disputes of %s", caller, req.Username)
+	}
+
+	s.disputeMu.Lock()
+	defer s.disputeMu.Unlock()
+	var list []*paymentpb.Dispute
+	for _, d := range s.disputes {
+		if req.Username != "" && d.Sender != req.Username && d.Receiver != req.Username {
+			continue
+		}
+		if req.OpenOnly && !d.active() {
+			continue
+		}
+		list = append(list, d.toProto())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListDisputesResponse{Disputes: list}, nil
+}
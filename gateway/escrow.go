@@ -0,0 +1,552 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jahnu05/Assignment-2/P-3/config"
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Escrow states. "funding" marks an escrow whose funding is running, and "releasing" and
+// "refunding" one whose settlement is running.
+const (
+	escrowFunding   = "funding"
+	escrowHeld      = "held"
+	escrowReleasing = "releasing"
+	escrowRefunding = "refunding"
+	escrowReleased  = "released"
+	escrowRefunded  = "refunded"
+)
+
+// Escrow is a payment whose funds are held in the escrow account at the sender's bank
+// until it is released to the receiver or refunded to the sender. The receive amount is
+// fixed when the escrow is funded, so a later release does not depend on FX changes.
+type Escrow struct {
+	EscrowId         string  `json:"escrowId"`
+	Sender           string  `json:"sender"`
+	Receiver         string  `json:"receiver"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	ReceivedAmount   float64 `json:"receivedAmount"`
+	ReceivedCurrency string  `json:"receivedCurrency"`
+	FxRate           float64 `json:"fxRate,omitempty"`
+	FxSpread         float64 `json:"fxSpread,omitempty"`
+	SenderBank       string  `json:"senderBank"`
+	ReceiverBank     string  `json:"receiverBank"`
+	CreatedAt        string  `json:"createdAt"`
+	Deadline         string  `json:"deadline"`
+	Description      string  `json:"description,omitempty"`
+	Status           string  `json:"status"`
+	ResolvedBy       string  `json:"resolvedBy,omitempty"`
+	ResolvedAt       string  `json:"resolvedAt,omitempty"`
+	TransactionId    string  `json:"transactionId,omitempty"`
+	IdempotencyKey   string  `json:"idempotencyKey,omitempty"`
+	// Interrupted marks an escrow whose funding or settlement partially committed or was
+	// cut short by a restart without reaching the history. It keeps the status it had, as a
+	// bank may have committed a leg, and only an operator can finish its settlement.
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+func (e *Escrow) toProto() *paymentpb.Escrow {
+	return &paymentpb.Escrow{
+		EscrowId:         e.EscrowId,
+		Sender:           e.Sender,
+		Receiver:         e.Receiver,
+		Amount:           e.Amount,
+		Currency:         e.Currency,
+		ReceivedAmount:   e.ReceivedAmount,
+		ReceivedCurrency: e.ReceivedCurrency,
+		SenderBank:       e.SenderBank,
+		ReceiverBank:     e.ReceiverBank,
+		CreatedAt:        e.CreatedAt,
+		Deadline:         e.Deadline,
+		Description:      e.Description,
+		Status:           e.Status,
+		ResolvedBy:       e.ResolvedBy,
+		ResolvedAt:       e.ResolvedAt,
+		TransactionId:    e.TransactionId,
+	}
+}
+
+// LoadEscrows loads persisted escrows. A missing file means there are none yet.
+func (s *PaymentGatewayServer) LoadEscrows(filename string) error {
+	s.escrowMu.Lock()
+	defer s.escrowMu.Unlock()
+
+	s.escrowsFile = filename
+	s.escrows = make(map[string]*Escrow)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*Escrow
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	var records []TransactionRecord
+	loaded := false
+	for _, e := range list {
+		s.escrows[e.EscrowId] = e
+		if e.Interrupted && e.IdempotencyKey != "" && e.Status == escrowFunding {
+			s.processedTxs.Store(e.IdempotencyKey, inDoubtStatus)
+		}
+		if e.Interrupted || (e.Status != escrowFunding && e.Status != escrowReleasing && e.Status != escrowRefunding) {
+			continue
+		}
+		if !loaded {
+			records = s.loadTransactionRecords()
+			loaded = true
+		}
+		s.recoverEscrow(e, records)
+	}
+	if loaded {
+		s.saveEscrows()
+	}
+	return nil
+}
+
+// recoverEscrow finishes a funding or settlement interrupted by a restart. One whose record
+// made it into the history committed; otherwise the escrow is marked interrupted.
+func (s *PaymentGatewayServer) recoverEscrow(e *Escrow, records []TransactionRecord) {
+	was := e.Status
+	for _, rec := range records {
+		if rec.EscrowId != e.EscrowId {
+			continue
+		}
+		switch rec.TransactionId {
+		case e.EscrowId:
+			if was != escrowFunding {
+				continue
+			}
+			e.Status = escrowHeld
+			log.Printf("Escrow %s was funding when the gateway stopped; its record shows it held", e.EscrowId)
+			return
+		case e.EscrowId + "-release":
+			e.Status = escrowReleased
+		case e.EscrowId + "-refund":
+			e.Status = escrowRefunded
+		default:
+			continue
+		}
+		e.TransactionId = rec.TransactionId
+		e.ResolvedAt = rec.Timestamp
+		log.Printf("Escrow %s was %s when the gateway stopped; its record shows it %s", e.EscrowId, was, e.Status)
+		return
+	}
+	e.Interrupted = true
+	if was == escrowFunding && e.IdempotencyKey != "" {
+		s.processedTxs.Store(e.IdempotencyKey, inDoubtStatus)
+	}
+	log.Printf("Escrow %s was %s when the gateway stopped and has no record; an operator must reconcile it", e.EscrowId, was)
+}
+
+// saveEscrows writes all escrows to disk. The caller must hold escrowMu.
+func (s *PaymentGatewayServer) saveEscrows() {
+	list := make([]*Escrow, 0, len(s.escrows))
+	for _, e := range s.escrows {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling escrows: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.escrowsFile, data, 0644); err != nil {
+		log.Printf("Error writing escrows: %v", err)
+	}
+}
+
+// CreateEscrow debits the sender into the escrow account at the sender's bank with a
+// two-phase commit and records the escrow with its deadline.
+func (s *PaymentGatewayServer) CreateEscrow(ctx context.Context, req *paymentpb.CreateEscrowRequest) (*paymentpb.EscrowResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.SenderUsername {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot create escrows for %s", caller, req.SenderUsername)
+	}
+	if _, ok := s.users.Load(req.ReceiverUsername); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "Receiver %s is not registered", req.ReceiverUsername)
+	}
+	if req.Amount <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Amount must be positive")
+	}
+	deadline, err := time.Parse(time.RFC3339, req.Deadline)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid deadline: %v", err)
+	}
+	if !deadline.After(time.Now()) {
+		return nil, status.Errorf(codes.InvalidArgument, "Deadline must be in the future")
+	}
+	if req.IdempotencyKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
+	}
+	// The escrow uses the banks both users registered with, whatever the request names.
+	req.SenderBank = s.bankOf("", req.SenderUsername)
+	req.ReceiverBank = s.bankOf("", req.ReceiverUsername)
+	if result, exists := s.processedTxs.LoadOrStore(req.IdempotencyKey, false); exists {
+		if result == inDoubtStatus {
+			return nil, status.Errorf(codes.DataLoss, "Escrow with IdempotencyKey %s was partially funded and awaits reconciliation", req.IdempotencyKey)
+		}
+		msg := fmt.Sprintf("Escrow already processed: %v", result)
+		return &paymentpb.EscrowResponse{Success: true, Message: msg}, nil
+	}
+
+	quote, err := s.quoteEscrow(ctx, req)
+	if err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	spend, err := s.reserveSpend(req.SenderUsername, quote.sendAmount, quote.sendCurrency)
+	if err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	defer spend.release()
+
+	escrowId := uuid.New().String()
+	if _, err := s.screenDebit(ctx, &paymentpb.TransactionRequest{
+		TransactionId:    escrowId,
+		SenderUsername:   req.SenderUsername,
+		ReceiverUsername: req.ReceiverUsername,
+		Amount:           quote.sendAmount,
+		SenderBank:       req.SenderBank,
+		ReceiverBank:     req.ReceiverBank,
+		Currency:         quote.sendCurrency,
+	}); err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+
+	now := time.Now()
+	e := &Escrow{
+		EscrowId:         escrowId,
+		Sender:           req.SenderUsername,
+		Receiver:         req.ReceiverUsername,
+		Amount:           quote.sendAmount,
+		Currency:         quote.sendCurrency,
+		ReceivedAmount:   quote.receiveAmount,
+		ReceivedCurrency: quote.receiveCurrency,
+		FxRate:           quote.rate,
+		FxSpread:         quote.spread,
+		SenderBank:       req.SenderBank,
+		ReceiverBank:     req.ReceiverBank,
+		CreatedAt:        now.Format(time.RFC3339),
+		Deadline:         deadline.Format(time.RFC3339),
+		Description:      req.Description,
+		Status:           escrowFunding,
+		IdempotencyKey:   req.IdempotencyKey,
+	}
+	// The escrow is saved before funding starts, so a restart during funding leaves a
+	// record to reconcile against the history.
+	s.escrowMu.Lock()
+	s.escrows[e.EscrowId] = e
+	s.saveEscrows()
+	s.escrowMu.Unlock()
+
+	log.Printf("Funding escrow %s: %.2f %s from %s", e.EscrowId, e.Amount, e.Currency, e.Sender)
+	err = executeTwoPhase(ctx, e.EscrowId, []paymentLeg{
+		{bank: e.SenderBank, account: e.Sender, amount: e.Amount, currency: e.Currency, debit: true},
+		{bank: e.SenderBank, account: config.EscrowAccount, amount: e.Amount, currency: e.Currency},
+	})
+	if partiallyCommitted(err) {
+		spend.commit()
+		s.processedTxs.Store(req.IdempotencyKey, inDoubtStatus)
+		s.escrowMu.Lock()
+		e.Interrupted = true
+		s.saveEscrows()
+		s.escrowMu.Unlock()
+		return nil, err
+	}
+	if err != nil {
+		s.escrowMu.Lock()
+		delete(s.escrows, e.EscrowId)
+		s.saveEscrows()
+		s.escrowMu.Unlock()
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	s.processedTxs.Store(req.IdempotencyKey, true)
+	spend.commit()
+
+	s.escrowMu.Lock()
+	e.Status = escrowHeld
+	s.saveEscrows()
+	s.escrowMu.Unlock()
+	s.recordEscrowEvent(e, e.EscrowId, fmt.Sprintf("Escrow funded: %s", e.Description))
+
+	return &paymentpb.EscrowResponse{Success: true, Message: "Escrow funded", Escrow: e.toProto()}, nil
+}
+
+// quoteEscrow resolves the account currencies of both parties and converts the amount
+// the way ProcessPayment would.
+func (s *PaymentGatewayServer) quoteEscrow(ctx context.Context, req *paymentpb.CreateEscrowRequest) (*paymentQuote, error) {
+	senderConn, err := grpc.Dial(req.SenderBank, grpc.WithInsecure())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Error connecting to sender bank: %v", err)
+	}
+	defer senderConn.Close()
+	receiverConn, err := grpc.Dial(req.ReceiverBank, grpc.WithInsecure())
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Error connecting to receiver bank: %v", err)
+	}
+	defer receiverConn.Close()
+
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return s.quotePayment(ctx2, &paymentpb.TransactionRequest{
+		SenderUsername:   req.SenderUsername,
+		ReceiverUsername: req.ReceiverUsername,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+	}, paymentpb.NewBankServiceClient(senderConn), paymentpb.NewBankServiceClient(receiverConn))
+}
+
+// recordEscrowEvent adds an escrow state change that is not itself a payment to both
+// users' history. Funding debits the sender and a refund credits them back, so both
+// report the sender's balance change.
+func (s *PaymentGatewayServer) recordEscrowEvent(e *Escrow, transactionId, message string) {
+	rec := TransactionRecord{
+		TransactionId: transactionId,
+		Sender:        e.Sender,
+		Receiver:      e.Receiver,
+		Amount:        e.Amount,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Message:       message,
+		Currency:      e.Currency,
+		SenderBank:    e.SenderBank,
+		ReceiverBank:  e.ReceiverBank,
+		Status:        e.Status,
+		EscrowId:      e.EscrowId,
+	}
+	s.storeTransactionRecord(rec)
+	switch e.Status {
+	case escrowHeld:
+		s.publishBalanceChange(rec, e.Sender, -e.Amount, e.Currency)
+	case escrowRefunded:
+		s.publishBalanceChange(rec, e.Sender, e.Amount, e.Currency)
+	}
+}
+
+// settleEscrow releases a held escrow to the receiver or refunds it to the sender. The
+// escrow is claimed under escrowMu first so only one settlement of it can run. An
+// interrupted settlement can be run again with the same transaction id; banks skip the
+// legs they already committed.
+func (s *PaymentGatewayServer) settleEscrow(ctx context.Context, escrowId, resolvedBy, reason string, release bool) (*Escrow, error) {
+	settling := escrowRefunding
+	if release {
+		settling = escrowReleasing
+	}
+	s.escrowMu.Lock()
+	e, ok := s.escrows[escrowId]
+	if !ok {
+		s.escrowMu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "Escrow %s not found", escrowId)
+	}
+	retry := e.Interrupted && e.Status == settling
+	if e.Status != escrowHeld && !retry {
+		s.escrowMu.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "Escrow %s is %s", escrowId, e.Status)
+	}
+	e.Status = settling
+	e.Interrupted = false
+	s.saveEscrows()
+	s.escrowMu.Unlock()
+
+	var transactionId string
+	var legs []paymentLeg
+	if release {
+		transactionId = e.EscrowId + "-release"
+		legs = []paymentLeg{
+			{bank: e.SenderBank, account: config.EscrowAccount, amount: e.Amount, currency: e.Currency, debit: true},
+			{bank: e.ReceiverBank, account: e.Receiver, amount: e.ReceivedAmount, currency: e.ReceivedCurrency},
+		}
+	} else {
+		transactionId = e.EscrowId + "-refund"
+		legs = []paymentLeg{
+			{bank: e.SenderBank, account: config.EscrowAccount, amount: e.Amount, currency: e.Currency, debit: true},
+			{bank: e.SenderBank, account: e.Sender, amount: e.Amount, currency: e.Currency},
+		}
+	}
+	err := executeTwoPhase(ctx, transactionId, legs)
+
+	s.escrowMu.Lock()
+	defer s.escrowMu.Unlock()
+	if err != nil {
+		// Once a leg may have committed the escrow must not go back to held, where it
+		// could be settled the other way.
+		if retry || partiallyCommitted(err) {
+			e.Interrupted = true
+		} else {
+			e.Status = escrowHeld
+		}
+		s.saveEscrows()
+		return nil, err
+	}
+	now := time.Now().Format(time.RFC3339)
+	e.Interrupted = false
+	e.ResolvedBy = resolvedBy
+	e.ResolvedAt = now
+	e.TransactionId = transactionId
+	if !release {
+		e.Status = escrowRefunded
+		s.saveEscrows()
+		msg := fmt.Sprintf("Escrow refunded to %s by %s", e.Sender, resolvedBy)
+		if reason != "" {
+			msg += ": " + reason
+		}
+		s.recordEscrowEvent(e, transactionId, msg)
+		log.Printf("Escrow %s refunded by %s", e.EscrowId, resolvedBy)
+		return e, nil
+	}
+
+	e.Status = escrowReleased
+	s.saveEscrows()
+	// The release is the payment itself, so it is recorded like one and can be refunded.
+	msg := fmt.Sprintf("Escrow %s released by %s", e.EscrowId, resolvedBy)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	s.storeTransactionRecord(TransactionRecord{
+		TransactionId:    transactionId,
+		Sender:           e.Sender,
+		Receiver:         e.Receiver,
+		Amount:           e.Amount,
+		Timestamp:        now,
+		Message:          msg,
+		Currency:         e.Currency,
+		ReceivedAmount:   e.ReceivedAmount,
+		ReceivedCurrency: e.ReceivedCurrency,
+		FxRate:           e.FxRate,
+		FxSpread:         e.FxSpread,
+		SenderBank:       e.SenderBank,
+		ReceiverBank:     e.ReceiverBank,
+		EscrowId:         e.EscrowId,
+	})
+	if e.SenderBank != e.ReceiverBank {
+		s.recordInterbankPosition(InterbankPosition{
+			TransactionId: transactionId,
+			DebtorBank:    e.SenderBank,
+			CreditorBank:  e.ReceiverBank,
+			Amount:        e.ReceivedAmount,
+			Currency:      e.ReceivedCurrency,
+			Timestamp:     now,
+		})
+	}
+	log.Printf("Escrow %s released by %s", e.EscrowId, resolvedBy)
+	return e, nil
+}
+
+// escrowParty checks that the caller is the given party of the escrow or an operator. Only
+// an operator can settle an interrupted escrow.
+func (s *PaymentGatewayServer) escrowParty(ctx context.Context, escrowId string, party func(*Escrow) string) (string, error) {
+	caller := authenticatedUser(ctx)
+	if isOperator(caller) {
+		return caller, nil
+	}
+	s.escrowMu.Lock()
+	defer s.escrowMu.Unlock()
+	e, ok := s.escrows[escrowId]
+	if !ok {
+		return "", status.Errorf(codes.NotFound, "Escrow %s not found", escrowId)
+	}
+	if caller != party(e) {
+		return "", status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot settle escrow %s", caller, escrowId)
+	}
+	if e.Interrupted {
+		return "", status.Errorf(codes.PermissionDenied, "unauthorized: escrow %s was interrupted and can only be settled by an operator", escrowId)
+	}
+	return caller, nil
+}
+
+// ReleaseEscrow pays a held escrow to the receiver. Only the sender or an operator may
+// release it.
+func (s *PaymentGatewayServer) ReleaseEscrow(ctx context.Context, req *paymentpb.EscrowAction) (*paymentpb.EscrowResponse, error) {
+	caller, err := s.escrowParty(ctx, req.EscrowId, func(e *Escrow) string { return e.Sender })
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.settleEscrow(ctx, req.EscrowId, caller, req.Reason, true)
+	if err != nil {
+		return nil, err
+	}
+	return &paymentpb.EscrowResponse{Success: true, Message: "Escrow released", Escrow: e.toProto()}, nil
+}
+
+// RefundEscrow returns a held escrow to the sender. Only the receiver or an operator may
+// refund it before the deadline.
+func (s *PaymentGatewayServer) RefundEscrow(ctx context.Context, req *paymentpb.EscrowAction) (*paymentpb.EscrowResponse, error) {
+	caller, err := s.escrowParty(ctx, req.EscrowId, func(e *Escrow) string { return e.Receiver })
+	if err != nil {
+		return nil, err
+	}
+	e, err := s.settleEscrow(ctx, req.EscrowId, caller, req.Reason, false)
+	if err != nil {
+		return nil, err
+	}
+	return &paymentpb.EscrowResponse{Success: true, Message: "Escrow refunded", Escrow: e.toProto()}, nil
+}
+
+// ListEscrows returns the escrows the user sent or is to receive.
+func (s *PaymentGatewayServer) ListEscrows(ctx context.Context, req *paymentpb.ListEscrowsRequest) (*paymentpb.ListEscrowsResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Username {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list escrows for %s", caller, req.Username)
+	}
+	s.escrowMu.Lock()
+	defer s.escrowMu.Unlock()
+	var list []*paymentpb.Escrow
+	for _, e := range s.escrows {
+		if e.Sender == req.Username || e.Receiver == req.Username {
+			list = append(list, e.toProto())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListEscrowsResponse{Escrows: list}, nil
+}
+
+// StartEscrowJob starts a background job that refunds held escrows whose deadline has passed.
+func (s *PaymentGatewayServer) StartEscrowJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.refundExpiredEscrows(time.Now())
+		}
+	}()
+}
+
+// refundExpiredEscrows refunds every held escrow past its deadline to its sender.
+func (s *PaymentGatewayServer) refundExpiredEscrows(now time.Time) {
+	s.escrowMu.Lock()
+	var due []string
+	for id, e := range s.escrows {
+		deadline, _ := time.Parse(time.RFC3339, e.Deadline)
+		if e.Status == escrowHeld && now.After(deadline) {
+			if e.Interrupted {
+				log.Printf("Escrow %s is past its deadline but its last settlement was interrupted; not refunding it automatically", id)
+				continue
+			}
+			due = append(due, id)
+		}
+	}
+	s.escrowMu.Unlock()
+
+	for _, id := range due {
+		if _, err := s.settleEscrow(context.Background(), id, "deadline", "deadline passed", false); err != nil {
+			log.Printf("Error refunding expired escrow %s: %v", id, err)
+		}
+	}
+}
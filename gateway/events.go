@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Event types sent by SubscribeEvents.
+const (
+	eventPaymentStatus  = "payment.status"
+	eventBalanceChanged = "balance.changed"
+)
+
+// maxBufferedEvents is how many recent events are kept for subscribers that reconnect.
+const maxBufferedEvents = 1000
+
+// streamEvent is a published event and the users allowed to see it.
+type streamEvent struct {
+	users []string
+	event *paymentpb.GatewayEvent
+}
+
+// eventLog holds the recent events in sequence order. Sequences start over at 1 with a
+// new epoch each time the gateway starts.
+type eventLog struct {
+	epoch  string
+	seq    uint64
+	events []streamEvent
+	// changed is closed and replaced on every publish to wake up subscribers.
+	changed chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{epoch: uuid.New().String(), changed: make(chan struct{})}
+}
+
+// publishEvent assigns the next sequence number to an event and wakes up subscribers.
+func (s *PaymentGatewayServer) publishEvent(event *paymentpb.GatewayEvent, users ...string) {
+	s.eventMu.Lock()
+	defer s.eventMu.Unlock()
+	l := s.events
+	l.seq++
+	event.Sequence = l.seq
+	event.Epoch = l.epoch
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	l.events = append(l.events, streamEvent{users: users, event: event})
+	if len(l.events) > maxBufferedEvents {
+		l.events = l.events[len(l.events)-maxBufferedEvents:]
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// publishPaymentStatus reports a payment's status to its sender and receiver.
+func (s *PaymentGatewayServer) publishPaymentStatus(req *paymentpb.TransactionRequest, state, message string) {
+	s.publishEvent(&paymentpb.GatewayEvent{
+		Type:          eventPaymentStatus,
+		TransactionId: req.TransactionId,
+		Status:        state,
+		Sender:        req.SenderUsername,
+		Receiver:      req.ReceiverUsername,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		Message:       message,
+	}, req.SenderUsername, req.ReceiverUsername)
+}
+
+// publishRecordEvents reports a stored payment or refund record: its final status and
+// the balance change of both users. The sender of an escrow release was debited when the
+// escrow was funded, so only the receiver's balance changes.
+func (s *PaymentGatewayServer) publishRecordEvents(rec TransactionRecord) {
+	state := "committed"
+	if rec.RefundOf != "" {
+		state = "refunded"
+	}
+	s.publishEvent(&paymentpb.GatewayEvent{
+		Type:          eventPaymentStatus,
+		Timestamp:     rec.Timestamp,
+		TransactionId: rec.TransactionId,
+		Status:        state,
+		Sender:        rec.Sender,
+		Receiver:      rec.Receiver,
+		Amount:        rec.Amount,
+		Currency:      rec.Currency,
+		Message:       rec.Message,
+	}, rec.Sender, rec.Receiver)
+
+	if rec.EscrowId == "" {
+		s.publishBalanceChange(rec, rec.Sender, -(rec.Amount + rec.Fee), rec.Currency)
+	}
+	received, currency := rec.ReceivedAmount, rec.ReceivedCurrency
+	if received == 0 {
+		received, currency = rec.Amount, rec.Currency
+	}
+	s.publishBalanceChange(rec, rec.Receiver, received, currency)
+}
+
+// publishBalanceChange reports the change of one user's balance made by a record. Balance
+// events carry the change, not the new balance.
+func (s *PaymentGatewayServer) publishBalanceChange(rec TransactionRecord, username string, amount float64, currency string) {
+	s.publishEvent(&paymentpb.GatewayEvent{
+		Type:          eventBalanceChanged,
+		Timestamp:     rec.Timestamp,
+		TransactionId: rec.TransactionId,
+		Sender:        rec.Sender,
+		Receiver:      rec.Receiver,
+		Username:      username,
+		Amount:        amount,
+		Currency:      currency,
+	}, username)
+}
+
+// visibleTo reports whether a user may see the event. Operators see every event.
+func (e streamEvent) visibleTo(user string) bool {
+	if isOperator(user) {
+		return true
+	}
+	for _, u := range e.users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeEvents streams payment status and balance events of the authenticated user,
+// or of all users for operators. A subscriber that reconnects passes the last sequence
+// and epoch it received to get the events it missed; when those are no longer buffered,
+// or the gateway restarted, the stream fails with OutOfRange and the subscriber should
+// reload its state and subscribe again from 0.
+func (s *PaymentGatewayServer) SubscribeEvents(req *paymentpb.SubscribeEventsRequest, stream grpc.ServerStreamingServer[paymentpb.GatewayEvent]) error {
+	caller := authenticatedUser(stream.Context())
+
+	s.eventMu.Lock()
+	l := s.events
+	last := req.FromSequence
+	if last == 0 {
+		last = l.seq
+	} else if req.Epoch != l.epoch {
+		s.eventMu.Unlock()
+		return status.Errorf(codes.OutOfRange, "Gateway restarted since sequence %d; subscribe again from 0", last)
+	} else if last > l.seq {
+		s.eventMu.Unlock()
+		return status.Errorf(codes.OutOfRange, "Sequence %d has not been reached yet", last)
+	}
+	s.eventMu.Unlock()
+
+	for {
+		s.eventMu.Lock()
+		if len(l.events) > 0 && l.events[0].event.Sequence > last+1 {
+			s.eventMu.Unlock()
+			return status.Errorf(codes.OutOfRange, "Events after sequence %d are no longer available; subscribe again from 0", last)
+		}
+		var pending []*paymentpb.GatewayEvent
+		for _, e := range l.events {
+			if e.event.Sequence > last && e.visibleTo(caller) {
+				pending = append(pending, e.event)
+			}
+		}
+		last = l.seq
+		changed := l.changed
+		s.eventMu.Unlock()
+
+		for _, e := range pending {
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-changed:
+		}
+	}
+}
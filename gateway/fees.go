@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// FeeBand is one bracket of a tiered fee. A payment falls into the first band whose
+// UpTo is at least the amount (an UpTo of 0 has no upper bound) and pays its Flat
+// charge plus Percent of the amount.
+type FeeBand struct {
+	UpTo    float64 `json:"upTo"`
+	Flat    float64 `json:"flat"`
+	Percent float64 `json:"percent"`
+}
+
+// FeeRule prices payments between a pair of banks for a user tier. Empty or "*"
+// fields match anything; the most specific matching rule wins. Percentages are
+// fractions of the amount (0.01 is 1%) and a Max of 0 means no cap.
+type FeeRule struct {
+	SenderBank   string    `json:"senderBank"`
+	ReceiverBank string    `json:"receiverBank"`
+	Tier         string    `json:"tier"`
+	Flat         float64   `json:"flat"`
+	Percent      float64   `json:"percent"`
+	Bands        []FeeBand `json:"bands,omitempty"`
+	Min          float64   `json:"min"`
+	Max          float64   `json:"max"`
+}
+
+// FeeSchedule is the fee configuration loaded from the fee schedule JSON file.
+// Fees are charged in the send currency and credited to FeeAccount at FeeBank, which
+// must hold that currency: a payment whose fee would need converting is rejected.
+type FeeSchedule struct {
+	FeeBank     string            `json:"feeBank"`
+	FeeAccount  string            `json:"feeAccount"`
+	DefaultTier string            `json:"defaultTier"`
+	UserTiers   map[string]string `json:"userTiers"`
+	Rules       []FeeRule         `json:"rules"`
+}
+
+// LoadFeeSchedule loads the fee schedule from a JSON file.
+func (s *PaymentGatewayServer) LoadFeeSchedule(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var schedule FeeSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return err
+	}
+	s.fees = &schedule
+	return nil
+}
+
+// matchField scores one rule field: 1 for an exact match, 0 for a wildcard, -1 for a mismatch.
+func matchField(pattern, value string) int {
+	switch pattern {
+	case "", "*":
+		return 0
+	case value:
+		return 1
+	}
+	return -1
+}
+
+// tierOf returns the fee tier of a user.
+func (f *FeeSchedule) tierOf(username string) string {
+	if tier, ok := f.UserTiers[username]; ok {
+		return tier
+	}
+	if f.DefaultTier != "" {
+		return f.DefaultTier
+	}
+	return "standard"
+}
+
+// amount applies the rule to a payment amount, rounded to cents.
+func (r FeeRule) amount(amount float64) float64 {
+	fee := r.Flat + r.Percent*amount
+	for _, band := range r.Bands {
+		if band.UpTo == 0 || amount <= band.UpTo {
+			fee += band.Flat + band.Percent*amount
+			break
+		}
+	}
+	if fee < r.Min {
+		fee = r.Min
+	}
+	if r.Max > 0 && fee > r.Max {
+		fee = r.Max
+	}
+	return math.Round(fee*100) / 100
+}
+
+// feeFor returns the fee charged to the sender of a payment, or 0 when no schedule
+// is loaded or no rule matches.
+func (s *PaymentGatewayServer) feeFor(req *paymentpb.TransactionRequest) float64 {
+	if s.fees == nil {
+		return 0
+	}
+	tier := s.fees.tierOf(req.SenderUsername)
+	best, bestScore := -1, -1
+	for i, rule := range s.fees.Rules {
+		score := 0
+		for _, m := range []int{
+			matchField(rule.SenderBank, req.SenderBank),
+			matchField(rule.ReceiverBank, req.ReceiverBank),
+			matchField(rule.Tier, tier),
+		} {
+			if m < 0 {
+				score = -1
+				break
+			}
+			score += m
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return s.fees.Rules[best].amount(req.Amount)
+}
+
+// checkFeeAccount checks that the fee account holds the currency the fee is charged in.
+func (s *PaymentGatewayServer) checkFeeAccount(ctx context.Context, feeClient paymentpb.BankServiceClient, currency string) error {
+	acc, err := feeClient.GetBalance(ctx, &paymentpb.GetBalanceRequest{Username: s.fees.FeeAccount})
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "Error looking up fee account: %v", err)
+	}
+	if acc.Currency != currency {
+		return status.Errorf(codes.FailedPrecondition, "Fee account %s holds %s, so a fee in %s cannot be charged", s.fees.FeeAccount, acc.Currency, currency)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// FXRate is one entry of the locally configured rate table. A rate applies from its
+// EffectiveFrom timestamp (RFC 3339) until a later entry for the same pair takes over.
+// Spread is the fraction withheld from the converted amount, e.g. 0.005 for 0.5%.
+type FXRate struct {
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Rate          float64 `json:"rate"`
+	Spread        float64 `json:"spread"`
+	EffectiveFrom string  `json:"effectiveFrom"`
+}
+
+// fxTable holds the rate table loaded from the FX rates JSON file.
+type fxTable struct {
+	mu    sync.RWMutex
+	file  string
+	rates []FXRate
+}
+
+// load (re)reads the rate table from its file.
+func (t *fxTable) load() error {
+	data, err := ioutil.ReadFile(t.file)
+	if err != nil {
+		return err
+	}
+	var rates []FXRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return err
+	}
+	for _, r := range rates {
+		if _, err := time.Parse(time.RFC3339, r.EffectiveFrom); err != nil {
+			return fmt.Errorf("rate %s->%s: invalid effectiveFrom: %v", r.From, r.To, err)
+		}
+		if r.Rate <= 0 {
+			return fmt.Errorf("rate %s->%s: rate must be positive", r.From, r.To)
+		}
+	}
+	t.mu.Lock()
+	t.rates = rates
+	t.mu.Unlock()
+	return nil
+}
+
+// lookup returns the rate for converting from one currency to another that is in effect
+// at the given time, i.e. the matching entry with the latest EffectiveFrom not after it.
+func (t *fxTable) lookup(from, to string, at time.Time) (FXRate, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var best FXRate
+	var bestFrom time.Time
+	found := false
+	for _, r := range t.rates {
+		if r.From != from || r.To != to {
+			continue
+		}
+		effective, _ := time.Parse(time.RFC3339, r.EffectiveFrom)
+		if effective.After(at) {
+			continue
+		}
+		if !found || effective.After(bestFrom) {
+			best, bestFrom, found = r, effective, true
+		}
+	}
+	return best, found
+}
+
+// LoadFXRates loads the FX rate table from a JSON file. The file is re-read by ReloadFXRates.
+func (s *PaymentGatewayServer) LoadFXRates(filename string) error {
+	s.fx = &fxTable{file: filename}
+	return s.fx.load()
+}
+
+// ReloadFXRates re-reads the FX rate table from its file without restarting the gateway.
+func (s *PaymentGatewayServer) ReloadFXRates(ctx context.Context, req *paymentpb.ReloadFXRatesRequest) (*paymentpb.ReloadFXRatesResponse, error) {
+	if s.fx == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "No FX rate table configured")
+	}
+	if err := s.fx.load(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Error loading FX rates: %v", err)
+	}
+	s.fx.mu.RLock()
+	count := len(s.fx.rates)
+	s.fx.mu.RUnlock()
+	log.Printf("Reloaded %d FX rates from %s", count, s.fx.file)
+	return &paymentpb.ReloadFXRatesResponse{Success: true, Message: "FX rates reloaded", RateCount: int32(count)}, nil
+}
+
+// paymentQuote describes what a payment moves once the account currencies are known.
+type paymentQuote struct {
+	sendCurrency    string
+	sendAmount      float64
+	receiveCurrency string
+	receiveAmount   float64
+	rate            float64 // zero when no conversion is needed
+	spread          float64
+	fee             float64 // charged to the sender on top of sendAmount
+}
+
+// quotePayment resolves the sender and receiver account currencies through their banks
+// and converts the amount with the rate table when they differ.
+func (s *PaymentGatewayServer) quotePayment(ctx context.Context, req *paymentpb.TransactionRequest, senderClient, receiverClient paymentpb.BankServiceClient) (*paymentQuote, error) {
+	senderAcc, err := senderClient.GetBalance(ctx, &paymentpb.GetBalanceRequest{Username: req.SenderUsername})
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Error looking up sender account: %v", err)
+	}
+	receiverAcc, err := receiverClient.GetBalance(ctx, &paymentpb.GetBalanceRequest{Username: req.ReceiverUsername})
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Error looking up receiver account: %v", err)
+	}
+
+	quote := &paymentQuote{
+		sendCurrency:    senderAcc.Currency,
+		sendAmount:      req.Amount,
+		receiveCurrency: receiverAcc.Currency,
+		receiveAmount:   req.Amount,
+	}
+	if req.Currency != "" && req.Currency != senderAcc.Currency {
+		return nil, status.Errorf(codes.InvalidArgument, "Send currency %s does not match sender account currency %s", req.Currency, senderAcc.Currency)
+	}
+	if quote.sendCurrency == quote.receiveCurrency {
+		return quote, nil
+	}
+
+	if s.fx == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "No FX rate table configured")
+	}
+	rate, ok := s.fx.lookup(quote.sendCurrency, quote.receiveCurrency, time.Now())
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "No FX rate in effect for %s to %s", quote.sendCurrency, quote.receiveCurrency)
+	}
+	quote.rate = rate.Rate
+	quote.spread = rate.Spread
+	quote.receiveAmount = math.Round(req.Amount*rate.Rate*(1-rate.Spread)*100) / 100
+	return quote, nil
+}
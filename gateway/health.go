@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthService is the health service name of the PaymentGateway service. Each bank the
+// gateway knows of is reported as HealthService + "/bank/" + its address.
+const HealthService = "payment.PaymentGateway"
+
+// publicServicePrefixes are the standard services served without credentials, so load
+// balancers and grpcurl can reach them. Transport security still applies.
+var publicServicePrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// isPublicService reports whether a method belongs to the health or reflection service.
+func isPublicService(method string) bool {
+	for _, prefix := range publicServicePrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartHealthChecks sets the serving status of the gateway on hs now and then at the
+// given interval. The gateway is serving while its transaction history is readable and,
+// once it knows of any bank, at least one bank accepts connections; each bank also has a
+// status of its own.
+func (s *PaymentGatewayServer) StartHealthChecks(hs *health.Server, interval time.Duration) {
+	state := &healthState{}
+	s.checkHealth(hs, state)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.checkHealth(hs, state)
+		}
+	}()
+}
+
+// healthState is what the previous health check reported.
+type healthState struct {
+	banks    map[string]bool
+	problems string
+}
+
+// checkHealth updates the statuses on hs. A bank no longer in use is reported as unknown,
+// and changes of the gateway's status are logged.
+func (s *PaymentGatewayServer) checkHealth(hs *health.Server, state *healthState) {
+	var problems []string
+	if err := s.checkHistoryFile(); err != nil {
+		problems = append(problems, fmt.Sprintf("transaction history unreadable: %v", err))
+	}
+
+	known := s.knownBanks()
+	reachable := make([]bool, len(known))
+	var wg sync.WaitGroup
+	for i, bank := range known {
+		wg.Add(1)
+		go func(i int, bank string) {
+			defer wg.Done()
+			reachable[i] = bankReachable(bank)
+		}(i, bank)
+	}
+	wg.Wait()
+
+	up := 0
+	current := make(map[string]bool)
+	for i, bank := range known {
+		current[bank] = true
+		if reachable[i] {
+			up++
+			hs.SetServingStatus(bankHealthService(bank), healthpb.HealthCheckResponse_SERVING)
+		} else {
+			hs.SetServingStatus(bankHealthService(bank), healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+	for bank := range state.banks {
+		if !current[bank] {
+			hs.SetServingStatus(bankHealthService(bank), healthpb.HealthCheckResponse_SERVICE_UNKNOWN)
+		}
+	}
+	state.banks = current
+	if len(known) > 0 && up == 0 {
+		problems = append(problems, fmt.Sprintf("no bank reachable (%s)", strings.Join(known, ", ")))
+	}
+
+	st := healthpb.HealthCheckResponse_SERVING
+	if len(problems) > 0 {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if summary := strings.Join(problems, "; "); summary != state.problems {
+		if summary == "" {
+			log.Printf("Gateway is serving")
+		} else {
+			log.Printf("Gateway not serving: %s", summary)
+		}
+		state.problems = summary
+	}
+	hs.SetServingStatus("", st)
+	hs.SetServingStatus(HealthService, st)
+}
+
+func bankHealthService(bank string) string {
+	return HealthService + "/bank/" + bank
+}
+
+// checkHistoryFile checks that the transaction history can be read. A missing file is
+// fine: it is created by the first payment.
+func (s *PaymentGatewayServer) checkHistoryFile() error {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	data, err := ioutil.ReadFile(s.historyFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	var records []TransactionRecord
+	return json.Unmarshal(data, &records)
+}
+
+// knownBanks returns the banks of the registered users and the fee bank, sorted.
+func (s *PaymentGatewayServer) knownBanks() []string {
+	seen := make(map[string]bool)
+	s.users.Range(func(_, v interface{}) bool {
+		if bank := v.(registeredUser).bank; bank != "" {
+			seen[bank] = true
+		}
+		return true
+	})
+	if s.fees != nil && s.fees.FeeBank != "" {
+		seen[s.fees.FeeBank] = true
+	}
+	banks := make([]string, 0, len(seen))
+	for bank := range seen {
+		banks = append(banks, bank)
+	}
+	sort.Strings(banks)
+	return banks
+}
@@ -12,7 +12,20 @@ import (
 	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
 )
 
-// GetTransactionHistory returns all transaction records involving the given user.
+// loadTransactionRecords reads all records from the transaction history file.
+func (s *PaymentGatewayServer) loadTransactionRecords() []TransactionRecord {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	var records []TransactionRecord
+	data, err := ioutil.ReadFile(s.historyFile)
+	if err == nil {
+		json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+// GetTransactionHistory returns all transaction records involving the given user. The
+// outcome of risk screening is only included for operators.
 func (s *PaymentGatewayServer) GetTransactionHistory(ctx context.Context, req *paymentpb.HistoryRequest) (*paymentpb.HistoryResponse, error) {
 	log.Printf("GetTransactionHistory called for user: %s", req.Username)
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -20,37 +33,69 @@ func (s *PaymentGatewayServer) GetTransactionHistory(ctx context.Context, req *p
 		return nil, status.Errorf(16, "missing metadata")
 	}
 	usernames := md["username"]
-	if len(usernames) == 0 || usernames[0] != req.Username {
+	if len(usernames) == 0 || (usernames[0] != req.Username && !isOperator(usernames[0])) {
 		return nil, status.Errorf(7, "unauthorized access")
 	}
+	operator := isOperator(usernames[0])
 
-	s.historyMu.Lock()
-	defer s.historyMu.Unlock()
-	var records []TransactionRecord
-	data, err := ioutil.ReadFile(s.historyFile)
-	if err == nil {
-		json.Unmarshal(data, &records)
-	}
+	records := s.loadTransactionRecords()
 	var filtered []TransactionRecord
 	for _, rec := range records {
-		if rec.Sender == req.Username || rec.Receiver == req.Username {
+		if rec.Sender == req.Username || rec.Receiver == req.Username || isParticipant(rec, req.Username) {
 			filtered = append(filtered, rec)
 		}
 	}
 
+	disputes := s.latestDisputes()
 	var recordsProto []*paymentpb.TransactionRecord
 	for _, rec := range filtered {
 		r := rec
+		if !operator {
+			r = r.withoutRisk()
+		}
 		recordProto := &paymentpb.TransactionRecord{
-			TransactionId: r.TransactionId,
-			Sender:        r.Sender,
-			Receiver:      r.Receiver,
-			Amount:        r.Amount,
-			Timestamp:     r.Timestamp,
-			Message:       r.Message,
+			TransactionId:       r.TransactionId,
+			Sender:              r.Sender,
+			Receiver:            r.Receiver,
+			Amount:              r.Amount,
+			Timestamp:           r.Timestamp,
+			Message:             r.Message,
+			Currency:            r.Currency,
+			ReceivedAmount:      r.ReceivedAmount,
+			ReceivedCurrency:    r.ReceivedCurrency,
+			FxRate:              r.FxRate,
+			FxSpread:            r.FxSpread,
+			Fee:                 r.Fee,
+			RefundOf:            r.RefundOf,
+			SenderBank:          r.SenderBank,
+			ReceiverBank:        r.ReceiverBank,
+			PaymentRequestId:    r.PaymentRequestId,
+			Status:              r.Status,
+			EscrowId:            r.EscrowId,
+			ParentTransactionId: r.ParentTransactionId,
+			Participants:        r.Participants,
+			RiskDecision:        r.RiskDecision,
+			RiskScore:           r.RiskScore,
+			RiskRules:           r.RiskRules,
+			ReviewId:            r.ReviewId,
+			InvoiceReference:    r.InvoiceReference,
+		}
+		if d, ok := disputes[r.TransactionId]; ok && r.Status == "" && r.RefundOf == "" {
+			recordProto.DisputeId = d.DisputeId
+			recordProto.DisputeStatus = d.Status
 		}
 		recordsProto = append(recordsProto, recordProto)
 	}
 
 	return &paymentpb.HistoryResponse{Records: recordsProto}, nil
 }
+
+// isParticipant reports whether the user takes part in a multi-party payment record.
+func isParticipant(rec TransactionRecord, username string) bool {
+	for _, p := range rec.Participants {
+		if p == username {
+			return true
+		}
+	}
+	return false
+}
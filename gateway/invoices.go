@@ -0,0 +1,372 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Invoice states. Only payments are stored; the state is derived from them and the due date.
+const (
+	invoiceOpen          = "open"
+	invoicePartiallyPaid = "partially_paid"
+	invoicePaid          = "paid"
+	invoiceOverdue       = "overdue"
+)
+
+// InvoiceLineItem is one line of an invoice.
+type InvoiceLineItem struct {
+	Description string  `json:"description"`
+	Quantity    int32   `json:"quantity"`
+	UnitPrice   float64 `json:"unitPrice"`
+}
+
+// InvoicePayment is a committed payment towards an invoice.
+type InvoicePayment struct {
+	TransactionId string  `json:"transactionId"`
+	Payer         string  `json:"payer"`
+	Amount        float64 `json:"amount"`
+	PaidAt        string  `json:"paidAt"`
+}
+
+// Invoice is a merchant's bill, paid by reference through ProcessPayment.
+type Invoice struct {
+	InvoiceId   string            `json:"invoiceId"`
+	Reference   string            `json:"reference"`
+	Merchant    string            `json:"merchant"`
+	Customer    string            `json:"customer,omitempty"`
+	LineItems   []InvoiceLineItem `json:"lineItems"`
+	Total       float64           `json:"total"`
+	Currency    string            `json:"currency"`
+	DueDate     string            `json:"dueDate"`
+	Description string            `json:"description,omitempty"`
+	CreatedAt   string            `json:"createdAt"`
+	Payments    []InvoicePayment  `json:"payments,omitempty"`
+
+	// pending is the amount reserved by payments still in flight; it is not persisted.
+	pending float64
+}
+
+// paid returns the total of the committed payments.
+func (inv *Invoice) paid() float64 {
+	total := 0.0
+	for _, p := range inv.Payments {
+		total += p.Amount
+	}
+	return math.Round(total*100) / 100
+}
+
+// due returns what is left to pay.
+func (inv *Invoice) due() float64 {
+	return math.Round((inv.Total-inv.paid())*100) / 100
+}
+
+// statusAt returns the invoice state at the given time.
+func (inv *Invoice) statusAt(now time.Time) string {
+	if inv.due() <= 0 {
+		return invoicePaid
+	}
+	if dueDate, _ := time.Parse(time.RFC3339, inv.DueDate); now.After(dueDate) {
+		return invoiceOverdue
+	}
+	if len(inv.Payments) > 0 {
+		return invoicePartiallyPaid
+	}
+	return invoiceOpen
+}
+
+func (inv *Invoice) toProto() *paymentpb.Invoice {
+	out := &paymentpb.Invoice{
+		InvoiceId:   inv.InvoiceId,
+		Reference:   inv.Reference,
+		Merchant:    inv.Merchant,
+		Customer:    inv.Customer,
+		Total:       inv.Total,
+		Currency:    inv.Currency,
+		DueDate:     inv.DueDate,
+		Description: inv.Description,
+		CreatedAt:   inv.CreatedAt,
+		Status:      inv.statusAt(time.Now()),
+		AmountPaid:  inv.paid(),
+		AmountDue:   inv.due(),
+	}
+	for _, item := range inv.LineItems {
+		out.LineItems = append(out.LineItems, &paymentpb.InvoiceLineItem{Description: item.Description, Quantity: item.Quantity, UnitPrice: item.UnitPrice})
+	}
+	for _, p := range inv.Payments {
+		out.Payments = append(out.Payments, &paymentpb.InvoicePayment{TransactionId: p.TransactionId, Payer: p.Payer, Amount: p.Amount, PaidAt: p.PaidAt})
+	}
+	return out
+}
+
+// invoiceReservation holds part of an invoice's amount due while a payment runs.
+// release drops it unless commit was called first.
+type invoiceReservation struct {
+	s         *PaymentGatewayServer
+	invoice   *Invoice
+	payer     string
+	amount    float64
+	committed bool
+}
+
+// LoadInvoices loads persisted invoices. Payments found in the transaction history but
+// missing from an invoice, because the gateway stopped between the two writes, are added.
+func (s *PaymentGatewayServer) LoadInvoices(filename string) error {
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+
+	s.invoicesFile = filename
+	s.invoices = make(map[string]*Invoice)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*Invoice
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, inv := range list {
+		s.invoices[inv.Reference] = inv
+	}
+
+	recovered := false
+	for _, rec := range s.loadTransactionRecords() {
+		inv, ok := s.invoices[rec.InvoiceReference]
+		if !ok || rec.Status != "" || rec.RefundOf != "" {
+			continue
+		}
+		known := false
+		for _, p := range inv.Payments {
+			known = known || p.TransactionId == rec.TransactionId
+		}
+		if !known {
+			log.Printf("Invoice %s: recovered payment %s from the transaction history", inv.Reference, rec.TransactionId)
+			inv.Payments = append(inv.Payments, InvoicePayment{TransactionId: rec.TransactionId, Payer: rec.Sender, Amount: rec.Amount, PaidAt: rec.Timestamp})
+			recovered = true
+		}
+	}
+	if recovered {
+		s.saveInvoices()
+	}
+	return nil
+}
+
+// saveInvoices writes all invoices to disk. The caller must hold invoiceMu.
+func (s *PaymentGatewayServer) saveInvoices() {
+	list := make([]*Invoice, 0, len(s.invoices))
+	for _, inv := range s.invoices {
+		list = append(list, inv)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling invoices: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.invoicesFile, data, 0644); err != nil {
+		log.Printf("Error writing invoices: %v", err)
+	}
+}
+
+// fillInvoicePayment completes a payment by invoice reference: the receiver and currency
+// come from the invoice.
+func (s *PaymentGatewayServer) fillInvoicePayment(req *paymentpb.TransactionRequest) error {
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+	inv, ok := s.invoices[req.InvoiceReference]
+	if !ok {
+		return status.Errorf(codes.NotFound, "Invoice %s not found", req.InvoiceReference)
+	}
+	if inv.Customer != "" && req.SenderUsername != inv.Customer {
+		return status.Errorf(codes.PermissionDenied, "unauthorized: invoice %s can only be paid by %s", inv.Reference, inv.Customer)
+	}
+	if req.SenderUsername == inv.Merchant {
+		return status.Errorf(codes.InvalidArgument, "Merchant %s cannot pay its own invoice", inv.Merchant)
+	}
+	if req.ReceiverUsername != "" && req.ReceiverUsername != inv.Merchant {
+		return status.Errorf(codes.InvalidArgument, "Invoice %s is payable to %s, not %s", inv.Reference, inv.Merchant, req.ReceiverUsername)
+	}
+	if req.Currency != "" && req.Currency != inv.Currency {
+		return status.Errorf(codes.InvalidArgument, "Invoice %s is payable in %s, not %s", inv.Reference, inv.Currency, req.Currency)
+	}
+	req.ReceiverUsername = inv.Merchant
+	req.Currency = inv.Currency
+	req.SenderBank = s.bankOf("", req.SenderUsername)
+	req.ReceiverBank = s.bankOf("", inv.Merchant)
+	// A zero amount pays what is still due; when nothing is, reserveInvoice refuses it
+	// after a retry of an earlier payment has had the chance to be recognised.
+	if req.Amount == 0 {
+		req.Amount = math.Max(math.Round((inv.due()-inv.pending)*100)/100, 0)
+	}
+	return nil
+}
+
+// reserveInvoice holds the payment's amount against the invoice's amount due, so
+// concurrent partial payments cannot overpay it. Payments without an invoice get an
+// empty reservation.
+func (s *PaymentGatewayServer) reserveInvoice(req *paymentpb.TransactionRequest) (*invoiceReservation, error) {
+	if req.InvoiceReference == "" {
+		return &invoiceReservation{}, nil
+	}
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+	inv, ok := s.invoices[req.InvoiceReference]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Invoice %s not found", req.InvoiceReference)
+	}
+	available := math.Round((inv.due()-inv.pending)*100) / 100
+	if available <= 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "Invoice %s has nothing left to pay", inv.Reference)
+	}
+	if req.Amount <= 0 || req.Amount > available {
+		return nil, status.Errorf(codes.FailedPrecondition, "Payment of %.2f does not fit the %.2f still due on invoice %s", req.Amount, available, inv.Reference)
+	}
+	inv.pending += req.Amount
+	return &invoiceReservation{s: s, invoice: inv, payer: req.SenderUsername, amount: req.Amount}, nil
+}
+
+// commit records the committed payment on the invoice. It runs right after the payment's
+// history record is written, which LoadInvoices falls back on.
+func (r *invoiceReservation) commit(transactionId string) {
+	if r.invoice == nil {
+		return
+	}
+	r.s.invoiceMu.Lock()
+	defer r.s.invoiceMu.Unlock()
+	inv := r.invoice
+	inv.pending -= r.amount
+	inv.Payments = append(inv.Payments, InvoicePayment{TransactionId: transactionId, Payer: r.payer, Amount: r.amount, PaidAt: time.Now().Format(time.RFC3339)})
+	r.committed = true
+	r.s.saveInvoices()
+	log.Printf("Invoice %s: %.2f %s paid by %s in transaction %s, %.2f still due", inv.Reference, r.amount, inv.Currency, r.payer, transactionId, inv.due())
+}
+
+func (r *invoiceReservation) release() {
+	if r.invoice == nil {
+		return
+	}
+	r.s.invoiceMu.Lock()
+	defer r.s.invoiceMu.Unlock()
+	if !r.committed {
+		r.invoice.pending -= r.amount
+		r.committed = true
+	}
+}
+
+// CreateInvoice creates an invoice for a merchant and returns its payment reference.
+func (s *PaymentGatewayServer) CreateInvoice(ctx context.Context, req *paymentpb.CreateInvoiceRequest) (*paymentpb.InvoiceResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot create invoices for %s", caller, req.Merchant)
+	}
+	if _, ok := s.users.Load(req.Merchant); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "Merchant %s is not registered", req.Merchant)
+	}
+	if req.Customer != "" {
+		if _, ok := s.users.Load(req.Customer); !ok {
+			return nil, status.Errorf(codes.FailedPrecondition, "Customer %s is not registered", req.Customer)
+		}
+		if req.Customer == req.Merchant {
+			return nil, status.Errorf(codes.InvalidArgument, "A merchant cannot invoice itself")
+		}
+	}
+	if len(req.LineItems) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "At least one line item is required")
+	}
+	total := 0.0
+	var items []InvoiceLineItem
+	for i, item := range req.LineItems {
+		if strings.TrimSpace(item.Description) == "" || item.Quantity <= 0 || item.UnitPrice <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Line item %d needs a description, a positive quantity and a positive unit price", i+1)
+		}
+		total += float64(item.Quantity) * item.UnitPrice
+		items = append(items, InvoiceLineItem{Description: item.Description, Quantity: item.Quantity, UnitPrice: item.UnitPrice})
+	}
+	dueDate, err := time.Parse(time.RFC3339, req.DueDate)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid due date %q: %v", req.DueDate, err)
+	}
+	if !dueDate.After(time.Now()) {
+		return nil, status.Errorf(codes.InvalidArgument, "The due date must be in the future")
+	}
+	currency := req.Currency
+	if currency == "" {
+		if currency, err = accountCurrency(ctx, s.bankOf("", req.Merchant), req.Merchant); err != nil {
+			return nil, err
+		}
+	}
+
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+	var reference string
+	for reference == "" || s.invoices[reference] != nil {
+		suffix, err := randomHex(5)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Error generating invoice reference: %v", err)
+		}
+		reference = "inv_" + suffix
+	}
+	inv := &Invoice{
+		InvoiceId:   uuid.New().String(),
+		Reference:   reference,
+		Merchant:    req.Merchant,
+		Customer:    req.Customer,
+		LineItems:   items,
+		Total:       math.Round(total*100) / 100,
+		Currency:    currency,
+		DueDate:     dueDate.Format(time.RFC3339),
+		Description: req.Description,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+	}
+	s.invoices[reference] = inv
+	s.saveInvoices()
+	log.Printf("Invoice %s created by %s for %.2f %s, due %s", reference, inv.Merchant, inv.Total, inv.Currency, inv.DueDate)
+	return &paymentpb.InvoiceResponse{Success: true, Message: "Invoice created", Invoice: inv.toProto()}, nil
+}
+
+// GetInvoice looks an invoice up by reference. An invoice without a customer is a
+// payment link any user may view; otherwise only its merchant, customer and operators can.
+func (s *PaymentGatewayServer) GetInvoice(ctx context.Context, req *paymentpb.GetInvoiceRequest) (*paymentpb.InvoiceResponse, error) {
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+	inv, ok := s.invoices[req.Reference]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Invoice %s not found", req.Reference)
+	}
+	caller := authenticatedUser(ctx)
+	if inv.Customer != "" && caller != inv.Customer && caller != inv.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot view invoice %s", caller, inv.Reference)
+	}
+	return &paymentpb.InvoiceResponse{Success: true, Message: "Invoice found", Invoice: inv.toProto()}, nil
+}
+
+// ListInvoices returns a merchant's invoices.
+func (s *PaymentGatewayServer) ListInvoices(ctx context.Context, req *paymentpb.ListInvoicesRequest) (*paymentpb.ListInvoicesResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Merchant && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list invoices of %s", caller, req.Merchant)
+	}
+	s.invoiceMu.Lock()
+	defer s.invoiceMu.Unlock()
+	var list []*paymentpb.Invoice
+	for _, inv := range s.invoices {
+		if inv.Merchant == req.Merchant {
+			list = append(list, inv.toProto())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListInvoicesResponse{Invoices: list}, nil
+}
@@ -0,0 +1,418 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// SpendingLimit caps what a user may send, fees included. Amounts are in Currency, or in
+// the currency of the user's account when it is empty; payments in other currencies are
+// converted at the FX table's rate. Zero fields are not enforced. Daily and monthly totals
+// follow UTC calendar days and months; MaxCount applies to a rolling CountWindow.
+type SpendingLimit struct {
+	PerTransaction float64 `json:"perTransaction,omitempty"`
+	Daily          float64 `json:"daily,omitempty"`
+	Monthly        float64 `json:"monthly,omitempty"`
+	MaxCount       int     `json:"maxCount,omitempty"`
+	CountWindow    string  `json:"countWindow,omitempty"`
+	Currency       string  `json:"currency,omitempty"`
+}
+
+// LimitPolicy is the spending limit configuration stored in the limits JSON file. A user
+// takes the limit set for them in Users, otherwise the limit of their role.
+type LimitPolicy struct {
+	DefaultRole string                   `json:"defaultRole"`
+	UserRoles   map[string]string        `json:"userRoles"`
+	Roles       map[string]SpendingLimit `json:"roles"`
+	Users       map[string]SpendingLimit `json:"users"`
+}
+
+// spendEntry is one payment counted against a user's limits, with its fee, in the
+// currency it was sent in. Entries are added when a payment is about to be prepared and
+// dropped again if it does not commit.
+type spendEntry struct {
+	at        time.Time
+	amount    float64
+	currency  string
+	committed bool
+}
+
+// spendingLimits holds the limit policy and the recent payments of each user.
+type spendingLimits struct {
+	mu     sync.Mutex
+	file   string
+	policy LimitPolicy
+	spends map[string][]*spendEntry
+}
+
+// spendReservation is a payment counted against a sender's limits while it runs.
+// release drops it unless commit was called first.
+type spendReservation struct {
+	limits *spendingLimits
+	user   string
+	entry  *spendEntry
+}
+
+func (r *spendReservation) commit() {
+	if r.limits == nil {
+		return
+	}
+	r.limits.mu.Lock()
+	r.entry.committed = true
+	r.limits.mu.Unlock()
+}
+
+func (r *spendReservation) release() {
+	if r.limits == nil {
+		return
+	}
+	r.limits.mu.Lock()
+	defer r.limits.mu.Unlock()
+	if r.entry.committed {
+		return
+	}
+	entries := r.limits.spends[r.user]
+	for i, e := range entries {
+		if e == r.entry {
+			r.limits.spends[r.user] = append(entries[:i], entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// LoadSpendingLimits loads the limit policy and counts the payments already in the
+// transaction history. A missing file starts with no limits, which operators can then
+// set at runtime.
+func (s *PaymentGatewayServer) LoadSpendingLimits(filename string) error {
+	limits := &spendingLimits{file: filename, spends: make(map[string][]*spendEntry)}
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		if err := json.Unmarshal(data, &limits.policy); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, limit := range limits.policy.Roles {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+	}
+	for _, limit := range limits.policy.Users {
+		if err := limit.validate(); err != nil {
+			return err
+		}
+	}
+
+	since := time.Now().UTC().AddDate(0, -1, -1)
+	for _, rec := range s.loadTransactionRecords() {
+		if rec.Status != "" || rec.RefundOf != "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, rec.Timestamp)
+		if err != nil || at.Before(since) {
+			continue
+		}
+		entry := &spendEntry{at: at, amount: rec.Amount + rec.Fee, currency: rec.Currency, committed: true}
+		limits.spends[rec.Sender] = append(limits.spends[rec.Sender], entry)
+	}
+	s.limits = limits
+	return nil
+}
+
+// validate checks that the count window of a limit parses.
+func (l SpendingLimit) validate() error {
+	if l.MaxCount > 0 {
+		if _, err := time.ParseDuration(l.CountWindow); err != nil {
+			return fmt.Errorf("invalid countWindow %q: %v", l.CountWindow, err)
+		}
+	}
+	return nil
+}
+
+// save writes the limit policy to its file. The caller must hold mu.
+func (l *spendingLimits) save() error {
+	data, err := json.MarshalIndent(l.policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.file, data, 0644)
+}
+
+// limitFor returns the role and the limit that apply to a user. The caller must hold mu.
+func (l *spendingLimits) limitFor(username string) (string, SpendingLimit) {
+	role := l.policy.UserRoles[username]
+	if role == "" {
+		role = l.policy.DefaultRole
+	}
+	if limit, ok := l.policy.Users[username]; ok {
+		return role, limit
+	}
+	return role, l.policy.Roles[role]
+}
+
+// usage is what a user has spent in the periods their limits cover.
+type usage struct {
+	day, month       float64
+	count            int
+	dayEnd, monthEnd time.Time
+	windowEnd        time.Time // when the oldest payment in the count window leaves it
+}
+
+// spendIn converts an amount spent at the given time into another currency at the FX
+// table's rate, without the spread. Amounts recorded without a currency predate FX
+// support and are taken as they are.
+func (s *PaymentGatewayServer) spendIn(amount float64, from, to string, at time.Time) (float64, error) {
+	if from == "" || to == "" || from == to {
+		return amount, nil
+	}
+	if s.fx == nil {
+		return 0, status.Errorf(codes.FailedPrecondition, "No FX rate table configured to convert %s spending into %s", from, to)
+	}
+	rate, ok := s.fx.lookup(from, to, at)
+	if !ok {
+		return 0, status.Errorf(codes.FailedPrecondition, "No FX rate in effect for %s to %s", from, to)
+	}
+	return amount * rate.Rate, nil
+}
+
+// usageOf sums a user's payments for the current day and month in the given currency and
+// counts those within the count window, dropping entries too old to matter. The caller
+// must hold mu.
+func (s *PaymentGatewayServer) usageOf(username string, limit SpendingLimit, currency string, now time.Time) (usage, error) {
+	l := s.limits
+	now = now.UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	window, _ := time.ParseDuration(limit.CountWindow)
+	windowStart := now.Add(-window)
+
+	u := usage{dayEnd: dayStart.AddDate(0, 0, 1), monthEnd: monthStart.AddDate(0, 1, 0)}
+	var kept []*spendEntry
+	for _, e := range l.spends[username] {
+		if e.at.Before(monthStart) && e.at.Before(windowStart) {
+			continue
+		}
+		kept = append(kept, e)
+		amount, err := s.spendIn(e.amount, e.currency, currency, e.at)
+		if err != nil {
+			return usage{}, err
+		}
+		if !e.at.Before(dayStart) {
+			u.day += amount
+		}
+		if !e.at.Before(monthStart) {
+			u.month += amount
+		}
+		if window > 0 && e.at.After(windowStart) {
+			if u.count == 0 || e.at.Add(window).Before(u.windowEnd) {
+				u.windowEnd = e.at.Add(window)
+			}
+			u.count++
+		}
+	}
+	l.spends[username] = kept
+	u.day = math.Round(u.day*100) / 100
+	u.month = math.Round(u.month*100) / 100
+	return u, nil
+}
+
+// limitExceeded builds the RESOURCE_EXHAUSTED error for a broken limit, with the details
+// attached so clients can tell which limit was hit and how much headroom remains.
+func limitExceeded(detail *paymentpb.LimitExceeded) error {
+	st := status.Newf(codes.ResourceExhausted, "Spending limit exceeded: %s limit of %v for %s (used %v, remaining %v)",
+		detail.Limit, detail.LimitValue, detail.Username, detail.Used, detail.Remaining)
+	if withDetails, err := st.WithDetails(detail); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// reserveSpend counts a payment against the sender's limits, or fails if it would break
+// one. The amount includes the fee and is in the given currency, the sender's account
+// currency. It is called before any bank is asked to prepare the payment.
+func (s *PaymentGatewayServer) reserveSpend(username string, amount float64, currency string) (*spendReservation, error) {
+	l := s.limits
+	if l == nil {
+		return &spendReservation{}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	role, limit := l.limitFor(username)
+	limitCurrency := limit.Currency
+	if limitCurrency == "" {
+		limitCurrency = currency
+	}
+	u, err := s.usageOf(username, limit, limitCurrency, now)
+	if err != nil {
+		return nil, err
+	}
+	requested, err := s.spendIn(amount, currency, limitCurrency, now)
+	if err != nil {
+		return nil, err
+	}
+	requested = math.Round(requested*100) / 100
+	detail := &paymentpb.LimitExceeded{Username: username, Role: role, Requested: requested, Currency: limitCurrency}
+	switch {
+	case limit.PerTransaction > 0 && requested > limit.PerTransaction:
+		detail.Limit, detail.LimitValue, detail.Remaining = "per_transaction", limit.PerTransaction, limit.PerTransaction
+		return nil, limitExceeded(detail)
+	case limit.Daily > 0 && u.day+requested > limit.Daily:
+		detail.Limit, detail.LimitValue, detail.Used = "daily", limit.Daily, u.day
+		detail.Remaining = remaining(limit.Daily, u.day)
+		detail.ResetsAt = u.dayEnd.Format(time.RFC3339)
+		return nil, limitExceeded(detail)
+	case limit.Monthly > 0 && u.month+requested > limit.Monthly:
+		detail.Limit, detail.LimitValue, detail.Used = "monthly", limit.Monthly, u.month
+		detail.Remaining = remaining(limit.Monthly, u.month)
+		detail.ResetsAt = u.monthEnd.Format(time.RFC3339)
+		return nil, limitExceeded(detail)
+	case limit.MaxCount > 0 && u.count >= limit.MaxCount:
+		detail.Limit, detail.LimitValue, detail.Used = "count", float64(limit.MaxCount), float64(u.count)
+		detail.ResetsAt = u.windowEnd.Format(time.RFC3339)
+		return nil, limitExceeded(detail)
+	}
+
+	entry := &spendEntry{at: now, amount: amount, currency: currency}
+	l.spends[username] = append(l.spends[username], entry)
+	return &spendReservation{limits: l, user: username, entry: entry}, nil
+}
+
+// SetSpendingLimit sets or removes the limit of a user or a role and saves the policy.
+// It takes effect for the next payment.
+func (s *PaymentGatewayServer) SetSpendingLimit(ctx context.Context, req *paymentpb.SetSpendingLimitRequest) (*paymentpb.SpendingLimitResponse, error) {
+	if s.limits == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Spending limits are not enabled")
+	}
+	if (req.Username == "") == (req.Role == "") {
+		return nil, status.Errorf(codes.InvalidArgument, "Exactly one of username and role must be set")
+	}
+	var limit SpendingLimit
+	if req.Limit != nil {
+		limit = SpendingLimit{
+			PerTransaction: req.Limit.PerTransaction,
+			Daily:          req.Limit.Daily,
+			Monthly:        req.Limit.Monthly,
+			MaxCount:       int(req.Limit.MaxCount),
+			CountWindow:    req.Limit.CountWindow,
+			Currency:       req.Limit.Currency,
+		}
+	}
+	if err := limit.validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	l := s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	target, name := &l.policy.Roles, req.Role
+	if req.Username != "" {
+		target, name = &l.policy.Users, req.Username
+	}
+	if req.Remove {
+		delete(*target, name)
+	} else {
+		if *target == nil {
+			*target = make(map[string]SpendingLimit)
+		}
+		(*target)[name] = limit
+	}
+	if err := l.save(); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error saving spending limits: %v", err)
+	}
+	log.Printf("Spending limit for %s updated by %s: %+v (removed=%t)", name, authenticatedUser(ctx), limit, req.Remove)
+	return &paymentpb.SpendingLimitResponse{Success: true, Message: fmt.Sprintf("Spending limit for %s updated", name)}, nil
+}
+
+// SetUserRole assigns the role whose limits apply to a user.
+func (s *PaymentGatewayServer) SetUserRole(ctx context.Context, req *paymentpb.SetUserRoleRequest) (*paymentpb.SpendingLimitResponse, error) {
+	if s.limits == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "Spending limits are not enabled")
+	}
+	l := s.limits
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if req.Role == "" {
+		delete(l.policy.UserRoles, req.Username)
+	} else {
+		if l.policy.UserRoles == nil {
+			l.policy.UserRoles = make(map[string]string)
+		}
+		l.policy.UserRoles[req.Username] = req.Role
+	}
+	if err := l.save(); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error saving spending limits: %v", err)
+	}
+	log.Printf("Role of %s set to %q by %s", req.Username, req.Role, authenticatedUser(ctx))
+	return &paymentpb.SpendingLimitResponse{Success: true, Message: fmt.Sprintf("Role of %s updated", req.Username)}, nil
+}
+
+// remaining returns what is left of a limit, or -1 when the limit is not set.
+func remaining(limit, used float64) float64 {
+	if limit <= 0 {
+		return -1
+	}
+	return math.Max(0, math.Round((limit-used)*100)/100)
+}
+
+// GetSpendingHeadroom reports a user's limits and how much of them is left.
+func (s *PaymentGatewayServer) GetSpendingHeadroom(ctx context.Context, req *paymentpb.HeadroomRequest) (*paymentpb.HeadroomResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Username && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot view limits for %s", caller, req.Username)
+	}
+	resp := &paymentpb.HeadroomResponse{Username: req.Username, DailyRemaining: -1, MonthlyRemaining: -1, CountRemaining: -1}
+	l := s.limits
+	if l == nil {
+		return resp, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Without a currency of its own the limit is in the user's account currency, which
+	// their recorded payments were sent in.
+	role, limit := l.limitFor(req.Username)
+	currency := limit.Currency
+	if entries := l.spends[req.Username]; currency == "" && len(entries) > 0 {
+		currency = entries[len(entries)-1].currency
+	}
+	u, err := s.usageOf(req.Username, limit, currency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	resp.Role = role
+	resp.Limit = &paymentpb.SpendingLimit{
+		PerTransaction: limit.PerTransaction,
+		Daily:          limit.Daily,
+		Monthly:        limit.Monthly,
+		MaxCount:       int32(limit.MaxCount),
+		CountWindow:    limit.CountWindow,
+		Currency:       limit.Currency,
+	}
+	resp.DailyUsed = u.day
+	resp.DailyRemaining = remaining(limit.Daily, u.day)
+	resp.MonthlyUsed = u.month
+	resp.MonthlyRemaining = remaining(limit.Monthly, u.month)
+	resp.CountUsed = int32(u.count)
+	resp.DailyResetsAt = u.dayEnd.Format(time.RFC3339)
+	resp.MonthlyResetsAt = u.monthEnd.Format(time.RFC3339)
+	if limit.MaxCount > 0 {
+		resp.CountRemaining = int32(remaining(float64(limit.MaxCount), float64(u.count)))
+		if u.count > 0 {
+			resp.CountResetsAt = u.windowEnd.Format(time.RFC3339)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,274 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// multiPaymentStatus marks the parent record of a multi-party payment; the money moves
+// in its leg records.
+const multiPaymentStatus = "multi"
+
+// multiLeg is one sender-to-receiver pair of a multi-party payment.
+type multiLeg struct {
+	sender, receiver *paymentpb.PaymentParty
+	amount           float64
+}
+
+// splitMultiPayment checks the shape of a multi-party payment and pairs its parties into
+// legs. One side must have exactly one party; that party's amount may be left at zero and
+// then takes the total of the other side.
+func splitMultiPayment(req *paymentpb.MultiPaymentRequest) ([]multiLeg, float64, error) {
+	if len(req.Senders) == 0 || len(req.Receivers) == 0 {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "At least one sender and one receiver are required")
+	}
+	if len(req.Senders) > 1 && len(req.Receivers) > 1 {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "Either the senders or the receivers must be a single party")
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range append(append([]*paymentpb.PaymentParty{}, req.Senders...), req.Receivers...) {
+		if seen[p.Username] {
+			return nil, 0, status.Errorf(codes.InvalidArgument, "User %s appears more than once", p.Username)
+		}
+		seen[p.Username] = true
+	}
+
+	toMany := len(req.Senders) == 1
+	many, single := req.Receivers, req.Senders[0]
+	if !toMany {
+		many, single = req.Senders, req.Receivers[0]
+	}
+	total := 0.0
+	var legs []multiLeg
+	for _, p := range many {
+		if p.Amount <= 0 {
+			return nil, 0, status.Errorf(codes.InvalidArgument, "Amount for %s must be positive", p.Username)
+		}
+		total += p.Amount
+		if toMany {
+			legs = append(legs, multiLeg{sender: single, receiver: p, amount: p.Amount})
+		} else {
+			legs = append(legs, multiLeg{sender: p, receiver: single, amount: p.Amount})
+		}
+	}
+	total = math.Round(total*100) / 100
+	if single.Amount != 0 && math.Round(single.Amount*100)/100 != total {
+		return nil, 0, status.Errorf(codes.InvalidArgument, "Amount for %s is %.2f but the other side totals %.2f", single.Username, single.Amount, total)
+	}
+	return legs, total, nil
+}
+
+// accountCurrency asks a bank for the currency of a user's account.
+func accountCurrency(ctx context.Context, bank, username string) (string, error) {
+	conn, err := grpc.Dial(bank, grpc.WithInsecure())
+	if err != nil {
+		return "", status.Errorf(codes.FailedPrecondition, "Error connecting to bank %s: %v", bank, err)
+	}
+	defer conn.Close()
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	acc, err := paymentpb.NewBankServiceClient(conn).GetBalance(ctx2, &paymentpb.GetBalanceRequest{Username: username})
+	if err != nil {
+		return "", status.Errorf(codes.FailedPrecondition, "Error looking up account %s at %s: %v", username, bank, err)
+	}
+	return acc.Currency, nil
+}
+
+// ProcessMultiPayment pays several receivers from one sender, or one receiver from several
+// senders, in a single two-phase commit across all participating banks. It is recorded as
+// a parent record plus one payment record per sender-receiver leg.
+func (s *PaymentGatewayServer) ProcessMultiPayment(ctx context.Context, req *paymentpb.MultiPaymentRequest) (*paymentpb.MultiPaymentResponse, error) {
+	legs, total, err := splitMultiPayment(req)
+	if err != nil {
+		return nil, err
+	}
+	caller := authenticatedUser(ctx)
+	if len(req.Senders) > 1 && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: only an operator can debit several senders")
+	}
+	if len(req.Senders) == 1 && caller != req.Senders[0].Username && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot pay on behalf of %s", caller, req.Senders[0].Username)
+	}
+	if req.IdempotencyKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
+	}
+	if result, exists := s.processedTxs.LoadOrStore(req.IdempotencyKey, false); exists {
+		if result == inDoubtStatus {
+			return nil, status.Errorf(codes.DataLoss, "Multi-party payment with IdempotencyKey %s was partially committed and awaits reconciliation", req.IdempotencyKey)
+		}
+		msg := fmt.Sprintf("Transaction already processed: %v", result)
+		return &paymentpb.MultiPaymentResponse{Success: true, Message: msg}, nil
+	}
+
+	parties := append(append([]*paymentpb.PaymentParty{}, req.Senders...), req.Receivers...)
+	var usernames []string
+	currency := req.Currency
+	for _, p := range parties {
+		if _, ok := s.users.Load(p.Username); !ok {
+			s.processedTxs.Delete(req.IdempotencyKey)
+			return nil, status.Errorf(codes.FailedPrecondition, "User %s is not registered", p.Username)
+		}
+		p.Bank = s.bankOf("", p.Username)
+		accCurrency, err := accountCurrency(ctx, p.Bank, p.Username)
+		if err != nil {
+			s.processedTxs.Delete(req.IdempotencyKey)
+			return nil, err
+		}
+		if currency == "" {
+			currency = accCurrency
+		}
+		if accCurrency != currency {
+			s.processedTxs.Delete(req.IdempotencyKey)
+			return nil, status.Errorf(codes.FailedPrecondition, "Account %s is in %s, but multi-party payments must use a single currency (%s)", p.Username, accCurrency, currency)
+		}
+		usernames = append(usernames, p.Username)
+	}
+
+	// Every sender's leg counts against their spending limits.
+	var spends []*spendReservation
+	defer func() {
+		for _, spend := range spends {
+			spend.release()
+		}
+	}()
+	for _, l := range legs {
+		spend, err := s.reserveSpend(l.sender.Username, l.amount, currency)
+		if err != nil {
+			s.processedTxs.Delete(req.IdempotencyKey)
+			return nil, err
+		}
+		spends = append(spends, spend)
+	}
+
+	transactionId := req.TransactionId
+	if transactionId == "" {
+		transactionId = uuid.New().String()
+	}
+	// Every leg is screened like a payment of its own.
+	risks := make([]*riskAssessment, len(legs))
+	for i, l := range legs {
+		risk, err := s.screenDebit(ctx, &paymentpb.TransactionRequest{
+			TransactionId:    fmt.Sprintf("%s-%d", transactionId, i+1),
+			SenderUsername:   l.sender.Username,
+			ReceiverUsername: l.receiver.Username,
+			Amount:           l.amount,
+			SenderBank:       l.sender.Bank,
+			ReceiverBank:     l.receiver.Bank,
+			Currency:         currency,
+		})
+		if err != nil {
+			s.processedTxs.Delete(req.IdempotencyKey)
+			return nil, err
+		}
+		risks[i] = risk
+	}
+	var twoPhaseLegs []paymentLeg
+	for _, l := range legs {
+		twoPhaseLegs = append(twoPhaseLegs,
+			paymentLeg{bank: l.sender.Bank, account: l.sender.Username, amount: l.amount, currency: currency, debit: true},
+			paymentLeg{bank: l.receiver.Bank, account: l.receiver.Username, amount: l.amount, currency: currency})
+	}
+	log.Printf("Processing multi-party payment %s: %d legs, %.2f %s", transactionId, len(legs), total, currency)
+	err = executeTwoPhase(ctx, transactionId, twoPhaseLegs)
+	if partiallyCommitted(err) {
+		// Some legs may have committed, so the key stays claimed and the payment is not
+		// retried; the parent record shows every participant that it is in doubt.
+		s.processedTxs.Store(req.IdempotencyKey, inDoubtStatus)
+		for _, spend := range spends {
+			spend.commit()
+		}
+		parent := multiPaymentRecord(req, transactionId, total, currency, usernames)
+		parent.Status = inDoubtStatus
+		parent.Message = fmt.Sprintf("Multi-party payment partially committed: %d legs between %s; awaiting reconciliation", len(legs), strings.Join(usernames, ", "))
+		s.storeTransactionRecord(parent)
+		return nil, err
+	}
+	if err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	s.processedTxs.Store(req.IdempotencyKey, true)
+	for _, spend := range spends {
+		spend.commit()
+	}
+
+	parent := multiPaymentRecord(req, transactionId, total, currency, usernames)
+	timestamp := parent.Timestamp
+	parent.Message = fmt.Sprintf("Multi-party payment committed successfully: %d legs between %s", len(legs), strings.Join(usernames, ", "))
+	if req.Description != "" {
+		parent.Message += ": " + req.Description
+	}
+	s.storeTransactionRecord(parent)
+
+	var legIds []string
+	for i, l := range legs {
+		legId := fmt.Sprintf("%s-%d", transactionId, i+1)
+		legIds = append(legIds, legId)
+		record := TransactionRecord{
+			TransactionId:       legId,
+			Sender:              l.sender.Username,
+			Receiver:            l.receiver.Username,
+			Amount:              l.amount,
+			Timestamp:           timestamp,
+			Message:             fmt.Sprintf("Leg %d of multi-party payment %s", i+1, transactionId),
+			Currency:            currency,
+			SenderBank:          l.sender.Bank,
+			ReceiverBank:        l.receiver.Bank,
+			ParentTransactionId: transactionId,
+		}
+		risks[i].applyTo(&record)
+		s.storeTransactionRecord(record)
+		if l.sender.Bank != l.receiver.Bank {
+			s.recordInterbankPosition(InterbankPosition{
+				TransactionId: legId,
+				DebtorBank:    l.sender.Bank,
+				CreditorBank:  l.receiver.Bank,
+				Amount:        l.amount,
+				Currency:      currency,
+				Timestamp:     timestamp,
+			})
+		}
+	}
+
+	return &paymentpb.MultiPaymentResponse{
+		Success:           true,
+		Message:           "Multi-party payment committed successfully",
+		TransactionId:     transactionId,
+		LegTransactionIds: legIds,
+		TotalAmount:       total,
+	}, nil
+}
+
+// multiPaymentRecord builds the parent record of a multi-party payment. A single sender
+// or receiver is named on it; several are listed as participants only.
+func multiPaymentRecord(req *paymentpb.MultiPaymentRequest, transactionId string, total float64, currency string, usernames []string) TransactionRecord {
+	parent := TransactionRecord{
+		TransactionId: transactionId,
+		Amount:        total,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Currency:      currency,
+		Status:        multiPaymentStatus,
+		Participants:  usernames,
+	}
+	if len(req.Senders) == 1 {
+		parent.Sender = req.Senders[0].Username
+		parent.SenderBank = req.Senders[0].Bank
+	}
+	if len(req.Receivers) == 1 {
+		parent.Receiver = req.Receivers[0].Username
+		parent.ReceiverBank = req.Receivers[0].Bank
+	}
+	return parent
+}
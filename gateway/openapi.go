@@ -0,0 +1,204 @@
+package gateway
+
+import (
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The OpenAPI document is generated from restRoutes and the descriptors of the request
+// and response messages, so it always matches what RESTHandler serves.
+
+type jsonObject = map[string]interface{}
+
+// openAPISchemas collects the schemas of the messages used by the routes.
+type openAPISchemas map[string]interface{}
+
+func schemaRef(md protoreflect.MessageDescriptor) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + string(md.Name())}
+}
+
+// add adds the schema of a message and of every message it refers to.
+func (s openAPISchemas) add(md protoreflect.MessageDescriptor) {
+	name := string(md.Name())
+	if _, ok := s[name]; ok {
+		return
+	}
+	props := jsonObject{}
+	s[name] = jsonObject{"type": "object", "properties": props}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		props[fd.JSONName()] = s.field(fd)
+	}
+}
+
+// field returns the schema of a field as protojson writes it.
+func (s openAPISchemas) field(fd protoreflect.FieldDescriptor) jsonObject {
+	if fd.IsMap() {
+		return jsonObject{"type": "object", "additionalProperties": s.value(fd.MapValue())}
+	}
+	if fd.IsList() {
+		return jsonObject{"type": "array", "items": s.value(fd)}
+	}
+	return s.value(fd)
+}
+
+// value returns the schema of a single value of a field.
+func (s openAPISchemas) value(fd protoreflect.FieldDescriptor) jsonObject {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		s.add(fd.Message())
+		return schemaRef(fd.Message())
+	case protoreflect.EnumKind:
+		var names []string
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return jsonObject{"type": "string", "enum": names}
+	}
+	return scalarSchema(fd.Kind())
+}
+
+// scalarSchema returns the schema of a scalar kind. protojson writes 64-bit integers as
+// strings.
+func scalarSchema(kind protoreflect.Kind) jsonObject {
+	switch kind {
+	case protoreflect.BoolKind:
+		return jsonObject{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return jsonObject{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return jsonObject{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return jsonObject{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return jsonObject{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return jsonObject{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return jsonObject{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return jsonObject{"type": "string", "format": "byte"}
+	}
+	return jsonObject{"type": "string"}
+}
+
+// openAPIOperation describes one route.
+func openAPIOperation(route restRoute, md protoreflect.MethodDescriptor, schemas openAPISchemas) jsonObject {
+	input, output := md.Input(), md.Output()
+	schemas.add(output)
+
+	var params []interface{}
+	inPath := make(map[protoreflect.FieldDescriptor]bool)
+	for _, name := range route.pathParams() {
+		fd := restField(input.Fields(), name)
+		inPath[fd] = true
+		params = append(params, jsonObject{"name": name, "in": "path", "required": true, "schema": scalarSchema(fd.Kind())})
+	}
+	if route.hasBody() {
+		schemas.add(input)
+	} else {
+		fields := input.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if inPath[fd] || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+				continue
+			}
+			params = append(params, jsonObject{"name": fd.JSONName(), "in": "query", "schema": schemas.field(fd)})
+		}
+	}
+	if idempotencyField(input.Fields()) != nil {
+		params = append(params, jsonObject{
+			"name":        "Idempotency-Key",
+			"in":          "header",
+			"description": "Used as the idempotency key when the request does not set one",
+			"schema":      jsonObject{"type": "string"},
+		})
+	}
+
+	tag := strings.Split(strings.TrimPrefix(route.Path, "/v1/"), "/")[0]
+	op := jsonObject{
+		"operationId": route.RPC,
+		"summary":     route.Summary,
+		"tags":        []string{tag},
+		"responses": jsonObject{
+			"200": jsonObject{
+				"description": "OK",
+				"content":     jsonObject{"application/json": jsonObject{"schema": schemaRef(output)}},
+			},
+			"default": jsonObject{
+				"description": "The gRPC status of a failed call; code is the gRPC code",
+				"content":     jsonObject{"application/json": jsonObject{"schema": jsonObject{"$ref": "#/components/schemas/Status"}}},
+			},
+		},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if route.hasBody() {
+		op["requestBody"] = jsonObject{
+			"content": jsonObject{"application/json": jsonObject{"schema": schemaRef(input)}},
+		}
+	}
+	if route.Public {
+		op["security"] = []interface{}{}
+	}
+	return op
+}
+
+// OpenAPIDocument returns the OpenAPI 3 description of the REST API.
+func OpenAPIDocument() ([]byte, error) {
+	schemas := openAPISchemas{
+		"Status": jsonObject{
+			"type": "object",
+			"properties": jsonObject{
+				"code":    jsonObject{"type": "integer", "format": "int32"},
+				"message": jsonObject{"type": "string"},
+				"details": jsonObject{"type": "array", "items": jsonObject{
+					"type":                 "object",
+					"properties":           jsonObject{"@type": jsonObject{"type": "string"}},
+					"additionalProperties": true,
+				}},
+			},
+		},
+	}
+	paths := jsonObject{}
+	for _, route := range restRoutes {
+		_, md, err := rpcMethod(route.RPC)
+		if err != nil {
+			return nil, err
+		}
+		item, ok := paths[route.Path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = openAPIOperation(route, md, schemas)
+	}
+	doc := jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":       "Payment Gateway REST API",
+			"version":     "v1",
+			"description": "HTTP/JSON mapping of the PaymentGateway gRPC service. Messages use the protobuf JSON mapping.",
+		},
+		"paths": paths,
+		"security": []interface{}{
+			jsonObject{"basicAuth": []string{}},
+			jsonObject{"apiKey": []string{}},
+			jsonObject{"bearerApiKey": []string{}},
+		},
+		"components": jsonObject{
+			"schemas": schemas,
+			"securitySchemes": jsonObject{
+				"basicAuth":    jsonObject{"type": "http", "scheme": "basic", "description": "Username and password"},
+				"apiKey":       jsonObject{"type": "apiKey", "in": "header", "name": "X-API-Key", "description": "Merchant API key"},
+				"bearerApiKey": jsonObject{"type": "http", "scheme": "bearer", "description": "Merchant API key as a bearer token"},
+			},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Operator is a gateway operator account from the operators file. Operators are not
+// registered through Register: their names are reserved and they log in with the
+// configured password, so nobody can make themselves an operator.
+type Operator struct {
+	Username string `json:"username"`
+	// Hex SHA-256 of the operator's password, e.g. from `printf %s "$PASSWORD" | sha256sum`.
+	PasswordSha256 string `json:"passwordSha256"`
+}
+
+// LoadOperators loads the operator accounts. A missing file means there are none, so
+// operator RPCs cannot be called.
+func (s *PaymentGatewayServer) LoadOperators(filename string) error {
+	s.operators = make(map[string]Operator)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []Operator
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, op := range list {
+		if op.Username == "" || len(op.PasswordSha256) != 64 {
+			return fmt.Errorf("operator %q needs a username and a hex SHA-256 password hash", op.Username)
+		}
+		op.PasswordSha256 = strings.ToLower(op.PasswordSha256)
+		s.operators[op.Username] = op
+	}
+	return nil
+}
+
+// isOperator reports whether the user is an operator from the operators file. Since
+// operator names cannot be registered and operators authenticate against the file, a
+// caller authenticated under such a name is the operator.
+func isOperator(username string) bool {
+	if gatewayInstance == nil {
+		return false
+	}
+	_, ok := gatewayInstance.operators[username]
+	return ok
+}
+
+// checkPassword reports whether password is the operator's configured password.
+func (op Operator) checkPassword(password string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(password)), []byte(op.PasswordSha256)) == 1
+}
@@ -0,0 +1,386 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Payment request states. A request is "processing" while its approval payment runs or
+// when that payment partially committed, and "in_review" while it is held by risk
+// screening.
+const (
+	requestPending    = "pending"
+	requestProcessing = "processing"
+	requestInReview   = "in_review"
+	requestPaid       = "paid"
+	requestDeclined   = "declined"
+	requestCancelled  = "cancelled"
+	requestExpired    = "expired"
+)
+
+// defaultRequestExpiry is how long a payment request stays open when no expiry is given.
+const defaultRequestExpiry = 7 * 24 * time.Hour
+
+// PaymentRequest is a receiver's request for money from a payer.
+type PaymentRequest struct {
+	RequestId     string  `json:"requestId"`
+	Requester     string  `json:"requester"`
+	Payer         string  `json:"payer"`
+	Amount        float64 `json:"amount"`
+	Memo          string  `json:"memo,omitempty"`
+	CreatedAt     string  `json:"createdAt"`
+	ExpiresAt     string  `json:"expiresAt"`
+	Status        string  `json:"status"`
+	TransactionId string  `json:"transactionId,omitempty"`
+}
+
+func (pr *PaymentRequest) toProto() *paymentpb.PaymentRequest {
+	return &paymentpb.PaymentRequest{
+		RequestId:     pr.RequestId,
+		Requester:     pr.Requester,
+		Payer:         pr.Payer,
+		Amount:        pr.Amount,
+		Memo:          pr.Memo,
+		CreatedAt:     pr.CreatedAt,
+		ExpiresAt:     pr.ExpiresAt,
+		Status:        pr.Status,
+		TransactionId: pr.TransactionId,
+	}
+}
+
+// LoadPaymentRequests loads persisted payment requests. A missing file means there are none
+// yet. Requests whose approval payment was running when the gateway stopped are settled
+// from the transaction history.
+func (s *PaymentGatewayServer) LoadPaymentRequests(filename string) error {
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+
+	s.requestsFile = filename
+	s.paymentRequests = make(map[string]*PaymentRequest)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*PaymentRequest
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	var processing []*PaymentRequest
+	for _, pr := range list {
+		s.paymentRequests[pr.RequestId] = pr
+		if pr.Status == requestProcessing {
+			processing = append(processing, pr)
+		}
+	}
+	if len(processing) == 0 {
+		return nil
+	}
+	records := s.loadTransactionRecords()
+	for _, pr := range processing {
+		s.recoverPaymentRequest(pr, records)
+	}
+	s.savePaymentRequests()
+	return nil
+}
+
+// recoverPaymentRequest settles a request left processing by a restart from the last
+// record of its payment: paid once it committed, in review while it is held, processing
+// while it is in doubt, and pending again when it never got that far or was refused.
+func (s *PaymentGatewayServer) recoverPaymentRequest(pr *PaymentRequest, records []TransactionRecord) {
+	state := requestPending
+	for _, rec := range records {
+		if rec.TransactionId != pr.TransactionId || rec.Sender != pr.Payer || rec.RefundOf != "" {
+			continue
+		}
+		switch rec.Status {
+		case "":
+			state = requestPaid
+		case inDoubtStatus:
+			state = requestProcessing
+		case riskReview:
+			state = requestInReview
+		default:
+			state = requestPending
+		}
+		if state == requestPaid {
+			break
+		}
+	}
+	log.Printf("Payment request %s was processing when the gateway stopped; its payment's history leaves it %s", pr.RequestId, state)
+	pr.Status = state
+	switch state {
+	case requestPending:
+		pr.TransactionId = ""
+	case requestPaid:
+		s.recordRequestEvent(pr, fmt.Sprintf("Payment request paid by transaction %s", pr.TransactionId))
+	}
+}
+
+// savePaymentRequests writes all payment requests to disk. The caller must hold requestMu.
+func (s *PaymentGatewayServer) savePaymentRequests() {
+	list := make([]*PaymentRequest, 0, len(s.paymentRequests))
+	for _, pr := range s.paymentRequests {
+		list = append(list, pr)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling payment requests: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.requestsFile, data, 0644); err != nil {
+		log.Printf("Error writing payment requests: %v", err)
+	}
+}
+
+// recordRequestEvent adds a payment request state change to both users' history.
+func (s *PaymentGatewayServer) recordRequestEvent(pr *PaymentRequest, message string) {
+	s.storeTransactionRecord(TransactionRecord{
+		TransactionId:    pr.RequestId,
+		Sender:           pr.Payer,
+		Receiver:         pr.Requester,
+		Amount:           pr.Amount,
+		Timestamp:        time.Now().Format(time.RFC3339),
+		Message:          message,
+		PaymentRequestId: pr.RequestId,
+		Status:           pr.Status,
+	})
+}
+
+// expireIfDue marks a pending request as expired once its expiry has passed.
+// The caller must hold requestMu.
+func (s *PaymentGatewayServer) expireIfDue(pr *PaymentRequest, now time.Time) {
+	expiresAt, _ := time.Parse(time.RFC3339, pr.ExpiresAt)
+	if pr.Status == requestPending && now.After(expiresAt) {
+		pr.Status = requestExpired
+		s.savePaymentRequests()
+		s.recordRequestEvent(pr, "Payment request expired")
+	}
+}
+
+// RequestPayment lets the authenticated user ask another user for money.
+func (s *PaymentGatewayServer) RequestPayment(ctx context.Context, req *paymentpb.RequestPaymentRequest) (*paymentpb.RequestPaymentResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Requester {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot request payments for %s", caller, req.Requester)
+	}
+	if _, ok := s.users.Load(req.Payer); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "Payer %s is not registered", req.Payer)
+	}
+	if req.Payer == req.Requester {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot request a payment from yourself")
+	}
+	if req.Amount <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Amount must be positive")
+	}
+	now := time.Now()
+	expiresAt := now.Add(defaultRequestExpiry)
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid expiresAt: %v", err)
+		}
+		if !t.After(now) {
+			return nil, status.Errorf(codes.InvalidArgument, "expiresAt must be in the future")
+		}
+		expiresAt = t
+	}
+
+	pr := &PaymentRequest{
+		RequestId: uuid.New().String(),
+		Requester: req.Requester,
+		Payer:     req.Payer,
+		Amount:    req.Amount,
+		Memo:      req.Memo,
+		CreatedAt: now.Format(time.RFC3339),
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+		Status:    requestPending,
+	}
+
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	s.paymentRequests[pr.RequestId] = pr
+	s.savePaymentRequests()
+	s.recordRequestEvent(pr, fmt.Sprintf("Payment requested: %s", pr.Memo))
+	log.Printf("Payment request %s: %s requests %.2f from %s", pr.RequestId, pr.Requester, pr.Amount, pr.Payer)
+
+	return &paymentpb.RequestPaymentResponse{Success: true, Message: "Payment request created", RequestId: pr.RequestId}, nil
+}
+
+// ListPaymentRequests returns the requests the user has made or been asked to pay.
+func (s *PaymentGatewayServer) ListPaymentRequests(ctx context.Context, req *paymentpb.ListPaymentRequestsRequest) (*paymentpb.ListPaymentRequestsResponse, error) {
+	if caller := authenticatedUser(ctx); caller != req.Username {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list payment requests for %s", caller, req.Username)
+	}
+
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	now := time.Now()
+	var list []*paymentpb.PaymentRequest
+	for _, pr := range s.paymentRequests {
+		if pr.Payer != req.Username && pr.Requester != req.Username {
+			continue
+		}
+		s.expireIfDue(pr, now)
+		if req.PendingOnly && pr.Status != requestPending {
+			continue
+		}
+		list = append(list, pr.toProto())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListPaymentRequestsResponse{Requests: list}, nil
+}
+
+// pendingRequestFor returns a pending request after checking that the caller is the
+// given party of it. The caller must hold requestMu.
+func (s *PaymentGatewayServer) pendingRequestFor(ctx context.Context, requestId string, party func(*PaymentRequest) string) (*PaymentRequest, error) {
+	pr, ok := s.paymentRequests[requestId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Payment request %s not found", requestId)
+	}
+	if caller := authenticatedUser(ctx); caller != party(pr) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot act on payment request %s", caller, requestId)
+	}
+	s.expireIfDue(pr, time.Now())
+	if pr.Status != requestPending {
+		return nil, status.Errorf(codes.FailedPrecondition, "Payment request %s is %s", requestId, pr.Status)
+	}
+	return pr, nil
+}
+
+func payerOf(pr *PaymentRequest) string     { return pr.Payer }
+func requesterOf(pr *PaymentRequest) string { return pr.Requester }
+
+// ApprovePaymentRequest pays a pending request through ProcessPayment, using the banks
+// both users registered with.
+func (s *PaymentGatewayServer) ApprovePaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	if req.IdempotencyKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
+	}
+	s.requestMu.Lock()
+	pr, err := s.pendingRequestFor(ctx, req.RequestId, payerOf)
+	if err != nil {
+		s.requestMu.Unlock()
+		return nil, err
+	}
+	// Claim the request so a concurrent approval cannot pay it twice. The claim and the
+	// payment's transaction id are saved first, so a restart during the payment can tell
+	// from the history whether it went through.
+	pr.Status = requestProcessing
+	pr.TransactionId = uuid.New().String()
+	s.savePaymentRequests()
+	s.requestMu.Unlock()
+
+	txReq := &paymentpb.TransactionRequest{
+		TransactionId:    pr.TransactionId,
+		SenderUsername:   pr.Payer,
+		ReceiverUsername: pr.Requester,
+		Amount:           pr.Amount,
+		SenderBank:       s.bankOf("", pr.Payer),
+		ReceiverBank:     s.bankOf("", pr.Requester),
+		IdempotencyKey:   req.IdempotencyKey,
+	}
+	resp, payErr := s.payNow(ctx, txReq)
+
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	if partiallyCommitted(payErr) {
+		// The payment may have moved money, so the request stays claimed until an
+		// operator reconciles it.
+		return nil, payErr
+	}
+	if payErr != nil {
+		pr.Status = requestPending
+		pr.TransactionId = ""
+		s.savePaymentRequests()
+		return nil, payErr
+	}
+	if resp.ReviewId != "" {
+		pr.Status = requestInReview
+		s.savePaymentRequests()
+		return &paymentpb.PaymentRequestActionResponse{Success: false, Message: fmt.Sprintf("Payment held for manual review %s", resp.ReviewId), TransactionId: txReq.TransactionId}, nil
+	}
+	if resp.QueueId != "" {
+		// Only a committed payment pays the request; this one may still fail.
+		pr.Status = requestPending
+		pr.TransactionId = ""
+		s.savePaymentRequests()
+		return &paymentpb.PaymentRequestActionResponse{Success: false, Message: fmt.Sprintf("%s %s", resp.Message, resp.QueueId)}, nil
+	}
+	pr.Status = requestPaid
+	s.savePaymentRequests()
+	s.recordRequestEvent(pr, fmt.Sprintf("Payment request paid by transaction %s", txReq.TransactionId))
+	log.Printf("Payment request %s paid by transaction %s", pr.RequestId, txReq.TransactionId)
+
+	return &paymentpb.PaymentRequestActionResponse{Success: true, Message: "Payment request paid", TransactionId: txReq.TransactionId}, nil
+}
+
+// DeclinePaymentRequest lets the payer refuse a pending request.
+func (s *PaymentGatewayServer) DeclinePaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	pr, err := s.pendingRequestFor(ctx, req.RequestId, payerOf)
+	if err != nil {
+		return nil, err
+	}
+	pr.Status = requestDeclined
+	s.savePaymentRequests()
+	s.recordRequestEvent(pr, "Payment request declined")
+	return &paymentpb.PaymentRequestActionResponse{Success: true, Message: "Payment request declined"}, nil
+}
+
+// CancelPaymentRequest lets the requester withdraw a pending request.
+func (s *PaymentGatewayServer) CancelPaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	pr, err := s.pendingRequestFor(ctx, req.RequestId, requesterOf)
+	if err != nil {
+		return nil, err
+	}
+	pr.Status = requestCancelled
+	s.savePaymentRequests()
+	s.recordRequestEvent(pr, "Payment request cancelled")
+	return &paymentpb.PaymentRequestActionResponse{Success: true, Message: "Payment request cancelled"}, nil
+}
+
+// reviewResolved settles a payment request whose payment was held for review, given the
+// error of that payment: it is paid once the payment commits, stays processing when the
+// payment is in doubt, and is otherwise open again.
+func (s *PaymentGatewayServer) reviewResolved(transactionId string, payErr error) {
+	s.requestMu.Lock()
+	defer s.requestMu.Unlock()
+	for _, pr := range s.paymentRequests {
+		if pr.Status != requestInReview || pr.TransactionId != transactionId {
+			continue
+		}
+		switch {
+		case payErr == nil:
+			pr.Status = requestPaid
+			s.savePaymentRequests()
+			s.recordRequestEvent(pr, fmt.Sprintf("Payment request paid by transaction %s", transactionId))
+			log.Printf("Payment request %s paid by transaction %s", pr.RequestId, transactionId)
+		case partiallyCommitted(payErr):
+			pr.Status = requestProcessing
+			s.savePaymentRequests()
+		default:
+			pr.Status = requestPending
+			pr.TransactionId = ""
+			s.savePaymentRequests()
+		}
+		return
+	}
+}
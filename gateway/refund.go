@@ -0,0 +1,198 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// findTransaction returns the committed payment with the given id and the total already
+// refunded against it, in the original receiver's currency. Refunds left in doubt by a
+// partial commit count as refunded until they are reconciled. Event records such as
+// payment request state changes are skipped. Transaction ids of payments are chosen by
+// their senders, so an id shared by several payments is refused rather than guessed.
+func (s *PaymentGatewayServer) findTransaction(transactionId string) (*TransactionRecord, float64, error) {
+	var original *TransactionRecord
+	matches := 0
+	refunded := 0.0
+	for _, rec := range s.loadTransactionRecords() {
+		r := rec
+		if r.Status == inDoubtStatus && r.RefundOf == transactionId {
+			refunded += r.Amount
+			continue
+		}
+		if r.Status != "" {
+			continue
+		}
+		switch {
+		case r.TransactionId == transactionId && r.RefundOf == "":
+			original = &r
+			matches++
+		case r.RefundOf == transactionId:
+			refunded += r.Amount
+		}
+	}
+	switch {
+	case matches == 0:
+		return nil, 0, status.Errorf(codes.NotFound, "Transaction %s not found", transactionId)
+	case matches > 1:
+		return nil, 0, status.Errorf(codes.FailedPrecondition, "Transaction id %s is used by %d payments", transactionId, matches)
+	}
+	return original, refunded, nil
+}
+
+// bankOf returns the bank recorded for a party of a transaction, falling back to the
+// user's registered bank for records written before banks were stored.
+func (s *PaymentGatewayServer) bankOf(recorded, username string) string {
+	if recorded != "" {
+		return recorded
+	}
+	if val, ok := s.users.Load(username); ok {
+		return val.(registeredUser).bank
+	}
+	return ""
+}
+
+// receivedAmountOf returns what the receiver was credited for a payment.
+func receivedAmountOf(rec *TransactionRecord) float64 {
+	if rec.ReceivedAmount != 0 {
+		return rec.ReceivedAmount
+	}
+	return rec.Amount
+}
+
+// RefundPayment returns all or part of a committed payment to its sender by running the
+// payment in reverse through both banks. Only the original receiver or an operator may
+// refund, and the refunds of a payment never exceed what the receiver was credited.
+func (s *PaymentGatewayServer) RefundPayment(ctx context.Context, req *paymentpb.RefundRequest) (*paymentpb.RefundResponse, error) {
+	if req.IdempotencyKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
+	}
+
+	// Refunds of the same payment must not run concurrently, or together they could
+	// exceed the original amount. The key is claimed under the same lock, so two requests
+	// with one key cannot both pass the check.
+	s.refundMu.Lock()
+	defer s.refundMu.Unlock()
+	if result, exists := s.processedTxs.LoadOrStore(req.IdempotencyKey, false); exists {
+		if result == inDoubtStatus {
+			return nil, status.Errorf(codes.DataLoss, "Refund with IdempotencyKey %s was partially committed and awaits reconciliation", req.IdempotencyKey)
+		}
+		msg := fmt.Sprintf("Refund already processed: %v", result)
+		return &paymentpb.RefundResponse{Success: true, Message: msg}, nil
+	}
+
+	original, refunded, err := s.findTransaction(req.OriginalTransactionId)
+	if err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	caller := authenticatedUser(ctx)
+	if caller != original.Receiver && !isOperator(caller) {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: only %s or an operator can refund transaction %s", original.Receiver, original.TransactionId)
+	}
+
+	received := receivedAmountOf(original)
+	remaining := math.Round((received-refunded)*100) / 100
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, status.Errorf(codes.FailedPrecondition, "Refund amount %.2f exceeds the refundable %.2f", amount, remaining)
+	}
+
+	message := fmt.Sprintf("Refund of transaction %s committed successfully", original.TransactionId)
+	if req.Reason != "" {
+		message += ": " + req.Reason
+	}
+	record, err := s.reverseTransaction(ctx, original, amount, message)
+	if partiallyCommitted(err) {
+		// Some money may have moved, so the key stays claimed and the refund is not retried.
+		s.processedTxs.Store(req.IdempotencyKey, inDoubtStatus)
+		return nil, err
+	}
+	if err != nil {
+		s.processedTxs.Delete(req.IdempotencyKey)
+		return nil, err
+	}
+	s.processedTxs.Store(req.IdempotencyKey, true)
+
+	return &paymentpb.RefundResponse{
+		Success:             true,
+		Message:             "Refund committed successfully",
+		RefundTransactionId: record.TransactionId,
+		RefundedAmount:      amount,
+		TotalRefunded:       refunded + amount,
+	}, nil
+}
+
+// reverseTransaction moves amount, in the receiver's currency, of a committed payment back
+// to its sender by running the payment in reverse through both banks, and records it as a
+// refund of the original. A partially committed reversal is recorded as in doubt. The
+// caller must hold refundMu and has checked the amount.
+func (s *PaymentGatewayServer) reverseTransaction(ctx context.Context, original *TransactionRecord, amount float64, message string) (*TransactionRecord, error) {
+	// The sender is credited at the rate of the original payment.
+	senderAmount := math.Round(original.Amount*(amount/receivedAmountOf(original))*100) / 100
+
+	senderBank := s.bankOf(original.SenderBank, original.Sender)
+	receiverBank := s.bankOf(original.ReceiverBank, original.Receiver)
+	receiverCurrency := original.ReceivedCurrency
+	if receiverCurrency == "" {
+		receiverCurrency = original.Currency
+	}
+
+	refundId := uuid.New().String()
+	log.Printf("Refunding %.2f of transaction %s as %s", amount, original.TransactionId, refundId)
+
+	err := executeTwoPhase(ctx, refundId, []paymentLeg{
+		{bank: receiverBank, account: original.Receiver, amount: amount, currency: receiverCurrency, debit: true},
+		{bank: senderBank, account: original.Sender, amount: senderAmount, currency: original.Currency},
+	})
+	if err != nil && !partiallyCommitted(err) {
+		return nil, err
+	}
+
+	record := TransactionRecord{
+		TransactionId:    refundId,
+		Sender:           original.Receiver,
+		Receiver:         original.Sender,
+		Amount:           amount,
+		Timestamp:        time.Now().Format(time.RFC3339),
+		Message:          message,
+		Currency:         receiverCurrency,
+		ReceivedAmount:   senderAmount,
+		ReceivedCurrency: original.Currency,
+		RefundOf:         original.TransactionId,
+		SenderBank:       receiverBank,
+		ReceiverBank:     senderBank,
+	}
+	if err != nil {
+		record.Status = inDoubtStatus
+		record.Message = fmt.Sprintf("Refund of transaction %s partially committed; awaiting reconciliation", original.TransactionId)
+		s.storeTransactionRecord(record)
+		return &record, err
+	}
+	s.storeTransactionRecord(record)
+	if senderBank != receiverBank {
+		s.recordInterbankPosition(InterbankPosition{
+			TransactionId: refundId,
+			DebtorBank:    receiverBank,
+			CreditorBank:  senderBank,
+			Amount:        senderAmount,
+			Currency:      original.Currency,
+			Timestamp:     record.Timestamp,
+		})
+	}
+	return &record, nil
+}
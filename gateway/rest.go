@@ -0,0 +1,387 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// restRoute maps an HTTP route onto a PaymentGateway RPC. Path parameters are named after
+// the request field they fill; the request body, if any, is the request message in its
+// JSON form, and on routes without a body the remaining fields come from the query string.
+type restRoute struct {
+	Method  string
+	Path    string
+	RPC     string
+	Summary string
+	Public  bool // no credentials needed
+}
+
+func (r restRoute) hasBody() bool {
+	return r.Method == http.MethodPost || r.Method == http.MethodPut
+}
+
+// pathParams returns the names of the {parameters} in the route's path.
+func (r restRoute) pathParams() []string {
+	var params []string
+	for _, seg := range strings.Split(r.Path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params = append(params, seg[1:len(seg)-1])
+		}
+	}
+	return params
+}
+
+// restRoutes are the routes of the REST API. The streaming RPCs (WatchPaymentBatch and
+// SubscribeEvents) are only available over gRPC.
+var restRoutes = []restRoute{
+	{http.MethodPost, "/v1/users", "Register", "Register a user with a bank", true},
+	{http.MethodDelete, "/v1/users/{username}", "Unregister", "Unregister a user", false},
+	{http.MethodGet, "/v1/users/{username}/balance", "GetBalance", "Get a user's balance", false},
+	{http.MethodGet, "/v1/users/{username}/history", "GetTransactionHistory", "Get a user's transaction history", false},
+	{http.MethodPost, "/v1/payments", "ProcessPayment", "Make a payment", false},
+	{http.MethodPost, "/v1/payments/async", "SubmitPayment", "Submit a payment for asynchronous execution", false},
+	{http.MethodGet, "/v1/payments/{paymentId}", "GetPaymentStatus", "Get the status of an asynchronous payment", false},
+	{http.MethodGet, "/v1/payments/{paymentId}/wait", "WaitForPayment", "Wait for an asynchronous payment to finish", false},
+	{http.MethodPost, "/v1/payments/{originalTransactionId}/refunds", "RefundPayment", "Refund all or part of a payment", false},
+	{http.MethodPost, "/v1/payments/{transactionId}/disputes", "OpenDispute", "Dispute a payment", false},
+	{http.MethodPost, "/v1/multi-payments", "ProcessMultiPayment", "Make a split or pooled payment", false},
+	{http.MethodGet, "/v1/users/{username}/queued-payments", "ListQueuedPayments", "List payments waiting for an unavailable bank", false},
+	{http.MethodPost, "/v1/schedules", "SchedulePayment", "Schedule a payment", false},
+	{http.MethodGet, "/v1/users/{username}/schedules", "ListSchedules", "List a user's scheduled payments", false},
+	{http.MethodPost, "/v1/schedules/{scheduleId}/pause", "PauseSchedule", "Pause a scheduled payment", false},
+	{http.MethodPost, "/v1/schedules/{scheduleId}/resume", "ResumeSchedule", "Resume a scheduled payment", false},
+	{http.MethodPost, "/v1/schedules/{scheduleId}/cancel", "CancelSchedule", "Cancel a scheduled payment", false},
+	{http.MethodPost, "/v1/payment-requests", "RequestPayment", "Request a payment from another user", false},
+	{http.MethodGet, "/v1/users/{username}/payment-requests", "ListPaymentRequests", "List a user's payment requests", false},
+	{http.MethodPost, "/v1/payment-requests/{requestId}/approve", "ApprovePaymentRequest", "Approve and pay a payment request", false},
+	{http.MethodPost, "/v1/payment-requests/{requestId}/decline", "DeclinePaymentRequest", "Decline a payment request", false},
+	{http.MethodPost, "/v1/payment-requests/{requestId}/cancel", "CancelPaymentRequest", "Cancel a payment request", false},
+	{http.MethodPost, "/v1/escrows", "CreateEscrow", "Hold a payment in escrow", false},
+	{http.MethodGet, "/v1/users/{username}/escrows", "ListEscrows", "List a user's escrows", false},
+	{http.MethodPost, "/v1/escrows/{escrowId}/release", "ReleaseEscrow", "Release an escrow to its receiver", false},
+	{http.MethodPost, "/v1/escrows/{escrowId}/refund", "RefundEscrow", "Refund an escrow to its sender", false},
+	{http.MethodPost, "/v1/batches", "SubmitPaymentBatch", "Submit a payment batch", false},
+	{http.MethodGet, "/v1/batches/{batchId}", "GetPaymentBatch", "Get the status of a payment batch", false},
+	{http.MethodPut, "/v1/spending-limits", "SetSpendingLimit", "Set or remove a user's or role's spending limit", false},
+	{http.MethodPut, "/v1/users/{username}/role", "SetUserRole", "Assign a user to a spending limit role", false},
+	{http.MethodGet, "/v1/users/{username}/headroom", "GetSpendingHeadroom", "Get what is left of a user's spending limits", false},
+	{http.MethodGet, "/v1/reviews", "ListReviews", "List payments held for review", false},
+	{http.MethodPost, "/v1/reviews/{reviewId}/approve", "ApproveReview", "Approve a held payment", false},
+	{http.MethodPost, "/v1/reviews/{reviewId}/reject", "RejectReview", "Reject a held payment", false},
+	{http.MethodGet, "/v1/disputes", "ListDisputes", "List disputes", false},
+	{http.MethodPost, "/v1/disputes/{disputeId}/investigate", "InvestigateDispute", "Start investigating a dispute", false},
+	{http.MethodPost, "/v1/disputes/{disputeId}/accept", "AcceptDispute", "Accept a dispute and charge the payment back", false},
+	{http.MethodPost, "/v1/disputes/{disputeId}/reject", "RejectDispute", "Reject a dispute", false},
+	{http.MethodPost, "/v1/merchants/{merchant}/api-keys", "CreateApiKey", "Create an API key", false},
+	{http.MethodGet, "/v1/merchants/{merchant}/api-keys", "ListApiKeys", "List a merchant's API keys", false},
+	{http.MethodPost, "/v1/api-keys/{keyId}/rotate", "RotateApiKey", "Replace the secret of an API key", false},
+	{http.MethodDelete, "/v1/api-keys/{keyId}", "RevokeApiKey", "Revoke an API key", false},
+	{http.MethodPost, "/v1/invoices", "CreateInvoice", "Create an invoice", false},
+	{http.MethodGet, "/v1/invoices/{reference}", "GetInvoice", "Get an invoice", false},
+	{http.MethodGet, "/v1/merchants/{merchant}/invoices", "ListInvoices", "List a merchant's invoices", false},
+	{http.MethodPost, "/v1/webhooks", "RegisterWebhook", "Register a webhook endpoint", false},
+	{http.MethodGet, "/v1/users/{owner}/webhooks", "ListWebhooks", "List a user's webhook endpoints", false},
+	{http.MethodDelete, "/v1/webhooks/{endpointId}", "DeleteWebhook", "Delete a webhook endpoint", false},
+	{http.MethodGet, "/v1/users/{owner}/webhook-deliveries", "ListWebhookDeliveries", "List undelivered webhook events", false},
+	{http.MethodPost, "/v1/webhook-deliveries/{deliveryId}/redeliver", "RedeliverWebhook", "Queue a dead webhook delivery again", false},
+	{http.MethodGet, "/v1/settlement/report", "GetSettlementReport", "Get the net inter-bank positions", false},
+	{http.MethodPost, "/v1/settlement/settle", "SettlePositions", "Post the net inter-bank positions to the banks", false},
+	{http.MethodPost, "/v1/fx-rates/reload", "ReloadFXRates", "Reload the FX rate table", false},
+}
+
+// maxRESTBody limits the size of a request body.
+const maxRESTBody = 1 << 20
+
+// restJSON is how responses are written. Unset fields are included so every response of
+// an RPC has the same shape.
+var restJSON = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// rpcMethod returns the generated handler and the descriptors of an RPC.
+func rpcMethod(name string) (grpc.MethodDesc, protoreflect.MethodDescriptor, error) {
+	service := paymentpb.File_protofiles_payment_proto.Services().ByName("PaymentGateway")
+	md := service.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return grpc.MethodDesc{}, nil, fmt.Errorf("no RPC %s", name)
+	}
+	for _, m := range paymentpb.PaymentGateway_ServiceDesc.Methods {
+		if m.MethodName == name {
+			return m, md, nil
+		}
+	}
+	return grpc.MethodDesc{}, nil, fmt.Errorf("%s is a streaming RPC", name)
+}
+
+// restField finds a field of a message by its proto or JSON name.
+func restField(fields protoreflect.FieldDescriptors, name string) protoreflect.FieldDescriptor {
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// idempotencyField returns the idempotency key field of a request, or nil. Older
+// messages spell it IdempotencyKey.
+func idempotencyField(fields protoreflect.FieldDescriptors) protoreflect.FieldDescriptor {
+	if fd := fields.ByName("IdempotencyKey"); fd != nil {
+		return fd
+	}
+	return fields.ByName("idempotencyKey")
+}
+
+// RESTHandler returns the HTTP/JSON API of the gateway. Every route calls the RPC's
+// generated handler with the gateway's unary interceptors, so requests are authenticated,
+// authorized and logged exactly as over gRPC. Credentials are HTTP basic auth, or an API
+// key as a bearer token or X-API-Key header; an Idempotency-Key header fills the request's idempotency key.
+func (s *PaymentGatewayServer) RESTHandler() (http.Handler, error) {
+	mux := http.NewServeMux()
+	for _, route := range restRoutes {
+		method, md, err := rpcMethod(route.RPC)
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range route.pathParams() {
+			if restField(md.Input().Fields(), param) == nil {
+				return nil, fmt.Errorf("%s %s: %s has no field %s", route.Method, route.Path, md.Input().Name(), param)
+			}
+		}
+		mux.Handle(route.Method+" "+route.Path, s.restMethod(route, method))
+	}
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		doc, err := OpenAPIDocument()
+		if err != nil {
+			writeRESTError(w, status.Errorf(codes.Internal, "Cannot generate OpenAPI document: %v", err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeRESTError(w, status.Errorf(codes.NotFound, "No route for %s %s", r.Method, r.URL.Path))
+	})
+	return mux, nil
+}
+
+// restMethod serves one route.
+func (s *PaymentGatewayServer) restMethod(route restRoute, method grpc.MethodDesc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := restContext(r, route.Public)
+		if err != nil {
+			writeRESTError(w, err)
+			return
+		}
+		dec := func(in interface{}) error {
+			return decodeRESTRequest(w, r, route, in.(proto.Message))
+		}
+		resp, err := method.Handler(s, ctx, dec, unaryInterceptor)
+		if err != nil {
+			writeRESTError(w, err)
+			return
+		}
+		data, err := restJSON.Marshal(resp.(proto.Message))
+		if err != nil {
+			writeRESTError(w, status.Errorf(codes.Internal, "Cannot encode response: %v", err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+// restContext turns the HTTP credentials into the incoming gRPC metadata the
+// interceptors check.
+func restContext(r *http.Request, public bool) (context.Context, error) {
+	md := metadata.MD{}
+	auth := r.Header.Get("Authorization")
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		md.Set("api-key", key)
+	} else if strings.HasPrefix(auth, "Bearer ") {
+		md.Set("api-key", strings.TrimPrefix(auth, "Bearer "))
+	} else if user, pass, ok := r.BasicAuth(); ok {
+		md.Set("username", user)
+		md.Set("password", pass)
+	} else if auth != "" && !public {
+		return nil, status.Errorf(codes.Unauthenticated, "Unsupported Authorization header; use Basic, Bearer or X-API-Key")
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	// Expose the HTTP client's certificate as a gRPC peer, so it is logged the same way.
+	p := &peer.Peer{LocalAddr: localAddr(r)}
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(ctx, p), nil
+}
+
+func localAddr(r *http.Request) net.Addr {
+	addr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return addr
+}
+
+// decodeRESTRequest fills a request message from the body, or on routes without a body
+// from the query string, and then from the path parameters, which take precedence.
+func decodeRESTRequest(w http.ResponseWriter, r *http.Request, route restRoute, msg proto.Message) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	pathParams := route.pathParams()
+	if route.hasBody() {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRESTBody))
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Cannot read request body: %v", err)
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if err := protojson.Unmarshal(body, msg); err != nil {
+				return status.Errorf(codes.InvalidArgument, "Invalid request body: %v", err)
+			}
+		}
+	} else {
+		inPath := make(map[protoreflect.FieldDescriptor]bool)
+		for _, param := range pathParams {
+			inPath[restField(fields, param)] = true
+		}
+		for name, values := range r.URL.Query() {
+			fd := restField(fields, name)
+			if fd == nil {
+				return status.Errorf(codes.InvalidArgument, "Unknown query parameter %s", name)
+			}
+			if inPath[fd] {
+				continue
+			}
+			if err := setRESTField(m, fd, values); err != nil {
+				return err
+			}
+		}
+	}
+	for _, param := range pathParams {
+		if err := setRESTField(m, restField(fields, param), []string{r.PathValue(param)}); err != nil {
+			return err
+		}
+	}
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if fd := idempotencyField(fields); fd != nil && m.Get(fd).String() == "" {
+			m.Set(fd, protoreflect.ValueOfString(key))
+		}
+	}
+	return nil
+}
+
+// setRESTField sets a scalar or repeated scalar field from its text form.
+func setRESTField(m protoreflect.Message, fd protoreflect.FieldDescriptor, values []string) error {
+	if fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return status.Errorf(codes.InvalidArgument, "%s cannot be given as a parameter", fd.JSONName())
+	}
+	if !fd.IsList() && len(values) > 1 {
+		return status.Errorf(codes.InvalidArgument, "%s given more than once", fd.JSONName())
+	}
+	var list protoreflect.List
+	if fd.IsList() {
+		list = m.Mutable(fd).List()
+	}
+	for _, text := range values {
+		v, err := parseRESTValue(fd, text)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Invalid %s %q: %v", fd.JSONName(), text, err)
+		}
+		if list != nil {
+			list.Append(v)
+		} else {
+			m.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+func parseRESTValue(fd protoreflect.FieldDescriptor, text string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(text), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(text)
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(text)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(text, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(text, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(text, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(text, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(text, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(text, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(text)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(text, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported type %s", fd.Kind())
+}
+
+// restStatus maps a gRPC code to its HTTP status.
+var restStatus = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           499,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted:            http.StatusConflict,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+}
+
+// writeRESTError writes an error as its google.rpc.Status JSON, with details such as
+// the exceeded spending limit.
+func writeRESTError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code, ok := restStatus[st.Code()]
+	if !ok {
+		code = http.StatusInternalServerError
+	}
+	data, merr := protojson.Marshal(st.Proto())
+	if merr != nil {
+		data = []byte(fmt.Sprintf(`{"code":%d,"message":%q}`, st.Code(), st.Message()))
+	}
+	if st.Code() == codes.Unauthenticated {
+		w.Header().Set("WWW-Authenticate", `Basic realm="payment-gateway"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,473 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Risk decisions, as stored on transaction records.
+const (
+	riskAllow    = "allow"
+	riskReview   = "review"
+	riskDeny     = "deny"
+	riskApproved = "approved" // held for review, then approved by an operator
+	riskRejected = "rejected" // held for review, then rejected by an operator
+)
+
+// Review queue states. "processing" marks an approved review whose payment is running.
+const (
+	reviewPending    = "pending"
+	reviewProcessing = "processing"
+	reviewApproved   = "approved"
+	reviewRejected   = "rejected"
+	reviewFailed     = "failed"
+)
+
+// NewCounterpartyRule matches a payment to someone the sender has never paid before.
+type NewCounterpartyRule struct {
+	Score float64 `json:"score"`
+}
+
+// AmountSpikeRule matches a payment larger than Multiplier times the sender's average
+// payment, once the sender has at least MinHistory payments in the history.
+type AmountSpikeRule struct {
+	Score      float64 `json:"score"`
+	Multiplier float64 `json:"multiplier"`
+	MinHistory int     `json:"minHistory"`
+}
+
+// RapidRetriesRule matches the MaxAttempts-th attempt of the same payment (sender,
+// receiver and amount) within Window, a Go duration such as "1m".
+type RapidRetriesRule struct {
+	Score       float64 `json:"score"`
+	Window      string  `json:"window"`
+	MaxAttempts int     `json:"maxAttempts"`
+}
+
+// UnusualHourRule matches payments made between the Start and End hours (UTC). The range
+// wraps past midnight when Start is after End.
+type UnusualHourRule struct {
+	Score float64 `json:"score"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+}
+
+// RiskRules is the risk screening configuration loaded from the risk rules JSON file.
+// Each matching rule adds its Score; a payment whose total reaches DenyScore is refused
+// and one reaching ReviewScore is held for an operator. A rule with no score is off.
+type RiskRules struct {
+	ReviewScore     float64             `json:"reviewScore"`
+	DenyScore       float64             `json:"denyScore"`
+	NewCounterparty NewCounterpartyRule `json:"newCounterparty"`
+	AmountSpike     AmountSpikeRule     `json:"amountSpike"`
+	RapidRetries    RapidRetriesRule    `json:"rapidRetries"`
+	UnusualHour     UnusualHourRule     `json:"unusualHour"`
+}
+
+// riskEngine scores payments against the rules and remembers recent attempts for the
+// rapid retries rule.
+type riskEngine struct {
+	rules    RiskRules
+	window   time.Duration
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+}
+
+// riskAssessment is the outcome of screening one payment.
+type riskAssessment struct {
+	decision string
+	score    float64
+	rules    []string
+	reviewId string
+}
+
+// applyTo stores the assessment on a transaction record.
+func (r *riskAssessment) applyTo(rec *TransactionRecord) {
+	if r == nil {
+		return
+	}
+	rec.RiskDecision = r.decision
+	rec.RiskScore = r.score
+	rec.RiskRules = r.rules
+	rec.ReviewId = r.reviewId
+}
+
+// ReviewItem is a payment held for manual review, with everything needed to execute it
+// once an operator approves it.
+type ReviewItem struct {
+	ReviewId       string  `json:"reviewId"`
+	TransactionId  string  `json:"transactionId"`
+	Sender         string  `json:"sender"`
+	Receiver       string  `json:"receiver"`
+	Amount         float64 `json:"amount"`
+	SenderBank     string  `json:"senderBank"`
+	ReceiverBank   string  `json:"receiverBank"`
+	Currency       string  `json:"currency,omitempty"`
+	IdempotencyKey string  `json:"idempotencyKey"`
+	// Invoice the held payment pays, if any.
+	InvoiceReference string   `json:"invoiceReference,omitempty"`
+	Score            float64  `json:"score"`
+	Rules            []string `json:"rules"`
+	CreatedAt        string   `json:"createdAt"`
+	Status           string   `json:"status"`
+	DecidedBy        string   `json:"decidedBy,omitempty"`
+	DecidedAt        string   `json:"decidedAt,omitempty"`
+	Note             string   `json:"note,omitempty"`
+	Result           string   `json:"result,omitempty"`
+}
+
+func (r *ReviewItem) request() *paymentpb.TransactionRequest {
+	return &paymentpb.TransactionRequest{
+		TransactionId:    r.TransactionId,
+		SenderUsername:   r.Sender,
+		ReceiverUsername: r.Receiver,
+		Amount:           r.Amount,
+		SenderBank:       r.SenderBank,
+		ReceiverBank:     r.ReceiverBank,
+		IdempotencyKey:   r.IdempotencyKey,
+		Currency:         r.Currency,
+		InvoiceReference: r.InvoiceReference,
+	}
+}
+
+func (r *ReviewItem) toProto() *paymentpb.ReviewItem {
+	return &paymentpb.ReviewItem{
+		ReviewId:      r.ReviewId,
+		TransactionId: r.TransactionId,
+		Sender:        r.Sender,
+		Receiver:      r.Receiver,
+		Amount:        r.Amount,
+		Currency:      r.Currency,
+		Score:         r.Score,
+		Rules:         r.Rules,
+		CreatedAt:     r.CreatedAt,
+		Status:        r.Status,
+		DecidedBy:     r.DecidedBy,
+		DecidedAt:     r.DecidedAt,
+		Note:          r.Note,
+		Result:        r.Result,
+	}
+}
+
+// heldForReview is stored under the idempotency key of a payment waiting for review, so
+// retries report the review instead of queueing the payment again.
+type heldForReview string
+
+// approvedReviewKey marks the context of a payment re-run after an operator approved it,
+// so it is not screened again.
+type approvedReviewKey struct{}
+
+// LoadRiskRules loads the risk screening rules from a JSON file.
+func (s *PaymentGatewayServer) LoadRiskRules(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var rules RiskRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	engine := &riskEngine{rules: rules, attempts: make(map[string][]time.Time)}
+	if rules.RapidRetries.Score > 0 {
+		engine.window, err = time.ParseDuration(rules.RapidRetries.Window)
+		if err != nil {
+			return fmt.Errorf("rapidRetries: invalid window: %v", err)
+		}
+	}
+	s.risk = engine
+	return nil
+}
+
+// LoadReviews loads the manual review queue. A missing file means the queue is empty.
+func (s *PaymentGatewayServer) LoadReviews(filename string) error {
+	s.reviewMu.Lock()
+	defer s.reviewMu.Unlock()
+
+	s.reviewsFile = filename
+	s.reviews = make(map[string]*ReviewItem)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*ReviewItem
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, r := range list {
+		switch r.Status {
+		case reviewPending:
+			s.processedTxs.Store(r.IdempotencyKey, heldForReview(r.ReviewId))
+		case reviewProcessing:
+			r.Status = reviewFailed
+			r.Result = "Interrupted by a gateway restart; check the transaction history"
+		}
+		s.reviews[r.ReviewId] = r
+	}
+	return nil
+}
+
+// saveReviews writes the review queue to disk. The caller must hold reviewMu.
+func (s *PaymentGatewayServer) saveReviews() {
+	list := make([]*ReviewItem, 0, len(s.reviews))
+	for _, r := range s.reviews {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling reviews: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.reviewsFile, data, 0644); err != nil {
+		log.Printf("Error writing reviews: %v", err)
+	}
+}
+
+// inHourRange reports whether hour falls in [start, end), wrapping past midnight.
+func inHourRange(hour, start, end int) bool {
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+// screenPayment scores a payment against the risk rules using the sender's payments in
+// the transaction history. It returns nil when no rules are configured.
+func (s *PaymentGatewayServer) screenPayment(ctx context.Context, req *paymentpb.TransactionRequest) *riskAssessment {
+	if review, ok := ctx.Value(approvedReviewKey{}).(*ReviewItem); ok {
+		return &riskAssessment{decision: riskApproved, score: review.Score, rules: review.Rules, reviewId: review.ReviewId}
+	}
+	engine := s.risk
+	if engine == nil {
+		return nil
+	}
+	rules := engine.rules
+	assessment := &riskAssessment{decision: riskAllow}
+	match := func(name string, score float64) {
+		assessment.score += score
+		assessment.rules = append(assessment.rules, name)
+	}
+
+	paidBefore := false
+	count, total := 0, 0.0
+	for _, rec := range s.loadTransactionRecords() {
+		if rec.Sender != req.SenderUsername || rec.Status != "" || rec.RefundOf != "" {
+			continue
+		}
+		count++
+		total += rec.Amount
+		if rec.Receiver == req.ReceiverUsername {
+			paidBefore = true
+		}
+	}
+	if rules.NewCounterparty.Score > 0 && !paidBefore {
+		match("new_counterparty", rules.NewCounterparty.Score)
+	}
+	spike := rules.AmountSpike
+	if spike.Score > 0 && spike.Multiplier > 0 && count > 0 && count >= spike.MinHistory && req.Amount > spike.Multiplier*total/float64(count) {
+		match("amount_spike", spike.Score)
+	}
+
+	now := time.Now()
+	if rules.RapidRetries.Score > 0 {
+		key := fmt.Sprintf("%s|%s|%.2f", req.SenderUsername, req.ReceiverUsername, req.Amount)
+		engine.mu.Lock()
+		var recent []time.Time
+		for _, at := range engine.attempts[key] {
+			if now.Sub(at) < engine.window {
+				recent = append(recent, at)
+			}
+		}
+		recent = append(recent, now)
+		engine.attempts[key] = recent
+		engine.mu.Unlock()
+		if len(recent) >= rules.RapidRetries.MaxAttempts {
+			match("rapid_retries", rules.RapidRetries.Score)
+		}
+	}
+	hour := rules.UnusualHour
+	if hour.Score > 0 && hour.Start != hour.End && inHourRange(now.UTC().Hour(), hour.Start, hour.End) {
+		match("unusual_hour", hour.Score)
+	}
+
+	switch {
+	case rules.DenyScore > 0 && assessment.score >= rules.DenyScore:
+		assessment.decision = riskDeny
+	case rules.ReviewScore > 0 && assessment.score >= rules.ReviewScore:
+		assessment.decision = riskReview
+	}
+	if assessment.decision != riskAllow {
+		log.Printf("Risk screening of transaction %s: %s (score %.0f: %s)", req.TransactionId, assessment.decision, assessment.score, strings.Join(assessment.rules, ", "))
+	}
+	return assessment
+}
+
+// screenDebit screens a payment made outside ProcessPayment, such as a leg of a
+// multi-party payment or the funding of an escrow. These cannot be held for manual review,
+// so a payment that would be held is refused as well.
+func (s *PaymentGatewayServer) screenDebit(ctx context.Context, req *paymentpb.TransactionRequest) (*riskAssessment, error) {
+	risk := s.screenPayment(ctx, req)
+	if risk == nil {
+		return nil, nil
+	}
+	switch risk.decision {
+	case riskDeny:
+		s.recordRiskEvent(req, risk, "Payment denied by risk screening")
+		return nil, status.Errorf(codes.PermissionDenied, "Payment denied by risk screening")
+	case riskReview:
+		s.recordRiskEvent(req, risk, "Payment needs manual review and was refused")
+		return nil, status.Errorf(codes.PermissionDenied, "Payment needs manual review by risk screening; send it as a single payment")
+	}
+	return risk, nil
+}
+
+// recordRiskEvent adds a payment that was denied, held or rejected by risk screening to
+// both users' history.
+func (s *PaymentGatewayServer) recordRiskEvent(req *paymentpb.TransactionRequest, risk *riskAssessment, message string) {
+	rec := TransactionRecord{
+		TransactionId: req.TransactionId,
+		Sender:        req.SenderUsername,
+		Receiver:      req.ReceiverUsername,
+		Amount:        req.Amount,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Message:       message,
+		Currency:      req.Currency,
+		SenderBank:    req.SenderBank,
+		ReceiverBank:  req.ReceiverBank,
+		Status:        risk.decision,
+	}
+	risk.applyTo(&rec)
+	s.storeTransactionRecord(rec)
+}
+
+// queueReview holds a payment for manual review and returns the review id.
+func (s *PaymentGatewayServer) queueReview(req *paymentpb.TransactionRequest, risk *riskAssessment) string {
+	item := &ReviewItem{
+		ReviewId:         uuid.New().String(),
+		TransactionId:    req.TransactionId,
+		Sender:           req.SenderUsername,
+		Receiver:         req.ReceiverUsername,
+		Amount:           req.Amount,
+		SenderBank:       req.SenderBank,
+		ReceiverBank:     req.ReceiverBank,
+		Currency:         req.Currency,
+		IdempotencyKey:   req.IdempotencyKey,
+		InvoiceReference: req.InvoiceReference,
+		Score:            risk.score,
+		Rules:            risk.rules,
+		CreatedAt:        time.Now().Format(time.RFC3339),
+		Status:           reviewPending,
+	}
+	risk.reviewId = item.ReviewId
+
+	s.reviewMu.Lock()
+	s.reviews[item.ReviewId] = item
+	s.saveReviews()
+	s.reviewMu.Unlock()
+	s.recordRiskEvent(req, risk, fmt.Sprintf("Payment held for manual review %s", item.ReviewId))
+	return item.ReviewId
+}
+
+// ListReviews returns the manual review queue.
+func (s *PaymentGatewayServer) ListReviews(ctx context.Context, req *paymentpb.ListReviewsRequest) (*paymentpb.ListReviewsResponse, error) {
+	s.reviewMu.Lock()
+	defer s.reviewMu.Unlock()
+	var list []*paymentpb.ReviewItem
+	for _, r := range s.reviews {
+		if req.PendingOnly && r.Status != reviewPending {
+			continue
+		}
+		list = append(list, r.toProto())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListReviewsResponse{Reviews: list}, nil
+}
+
+// claimReview moves a pending review to the given state on behalf of an operator.
+func (s *PaymentGatewayServer) claimReview(ctx context.Context, req *paymentpb.ReviewDecision, state string) (*ReviewItem, error) {
+	s.reviewMu.Lock()
+	defer s.reviewMu.Unlock()
+	item, ok := s.reviews[req.ReviewId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Review %s not found", req.ReviewId)
+	}
+	if item.Status != reviewPending {
+		return nil, status.Errorf(codes.FailedPrecondition, "Review %s is %s", req.ReviewId, item.Status)
+	}
+	item.Status = state
+	item.DecidedBy = authenticatedUser(ctx)
+	item.DecidedAt = time.Now().Format(time.RFC3339)
+	item.Note = req.Note
+	s.saveReviews()
+	return item, nil
+}
+
+// ApproveReview executes a held payment through ProcessPayment without screening it again.
+func (s *PaymentGatewayServer) ApproveReview(ctx context.Context, req *paymentpb.ReviewDecision) (*paymentpb.ReviewDecisionResponse, error) {
+	item, err := s.claimReview(ctx, req, reviewProcessing)
+	if err != nil {
+		return nil, err
+	}
+
+	// The idempotency key was parked while the payment waited for review.
+	s.processedTxs.Delete(item.IdempotencyKey)
+	payCtx := context.WithValue(context.Background(), approvedReviewKey{}, item)
+	resp, payErr := s.ProcessPayment(payCtx, item.request())
+
+	s.reviewMu.Lock()
+	if payErr != nil {
+		item.Status = reviewFailed
+		item.Result = status.Convert(payErr).Message()
+	} else {
+		item.Status = reviewApproved
+		item.Result = "Transaction committed successfully"
+	}
+	s.saveReviews()
+	s.reviewMu.Unlock()
+	s.reviewResolved(item.TransactionId, payErr)
+	s.batchReviewResolved(item.ReviewId, resp.GetFee(), payErr)
+	log.Printf("Review %s approved by %s: %s", item.ReviewId, item.DecidedBy, item.Result)
+
+	if payErr != nil {
+		return nil, payErr
+	}
+	return &paymentpb.ReviewDecisionResponse{Success: true, Message: "Payment approved and committed", TransactionId: item.TransactionId}, nil
+}
+
+// RejectReview refuses a held payment. Nothing was prepared at the banks, so there is
+// nothing to undo.
+func (s *PaymentGatewayServer) RejectReview(ctx context.Context, req *paymentpb.ReviewDecision) (*paymentpb.ReviewDecisionResponse, error) {
+	item, err := s.claimReview(ctx, req, reviewRejected)
+	if err != nil {
+		return nil, err
+	}
+	s.processedTxs.Delete(item.IdempotencyKey)
+	risk := &riskAssessment{decision: riskRejected, score: item.Score, rules: item.Rules, reviewId: item.ReviewId}
+	msg := fmt.Sprintf("Payment rejected after review by %s", item.DecidedBy)
+	if req.Note != "" {
+		msg += ": " + req.Note
+	}
+	s.recordRiskEvent(item.request(), risk, msg)
+	rejected := status.Errorf(codes.PermissionDenied, "%s", msg)
+	s.reviewResolved(item.TransactionId, rejected)
+	s.batchReviewResolved(item.ReviewId, 0, rejected)
+	log.Printf("Review %s rejected by %s", item.ReviewId, item.DecidedBy)
+	return &paymentpb.ReviewDecisionResponse{Success: true, Message: "Payment rejected", TransactionId: item.TransactionId}, nil
+}
@@ -0,0 +1,386 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Schedule states.
+const (
+	scheduleActive    = "active"
+	schedulePaused    = "paused"
+	scheduleCompleted = "completed"
+	scheduleCancelled = "cancelled"
+)
+
+// ScheduledPayment is a one-off or recurring payment persisted in the schedules file.
+type ScheduledPayment struct {
+	ScheduleId       string  `json:"scheduleId"`
+	SenderUsername   string  `json:"senderUsername"`
+	ReceiverUsername string  `json:"receiverUsername"`
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency,omitempty"`
+	SenderBank       string  `json:"senderBank"`
+	ReceiverBank     string  `json:"receiverBank"`
+	ExecuteAt        string  `json:"executeAt"`
+	Frequency        string  `json:"frequency"`
+	EndDate          string  `json:"endDate,omitempty"`
+	Count            int32   `json:"count,omitempty"`
+	Occurrences      int32   `json:"occurrences"`
+	NextRun          string  `json:"nextRun"`
+	Status           string  `json:"status"`
+	LastResult       string  `json:"lastResult,omitempty"`
+	// Firing is the transaction id of the occurrence being paid. It is saved before the
+	// payment starts, so an occurrence interrupted by a restart is never paid again.
+	Firing string `json:"firing,omitempty"`
+}
+
+func (sp *ScheduledPayment) toProto() *paymentpb.ScheduledPayment {
+	return &paymentpb.ScheduledPayment{
+		ScheduleId:       sp.ScheduleId,
+		SenderUsername:   sp.SenderUsername,
+		ReceiverUsername: sp.ReceiverUsername,
+		Amount:           sp.Amount,
+		Currency:         sp.Currency,
+		SenderBank:       sp.SenderBank,
+		ReceiverBank:     sp.ReceiverBank,
+		ExecuteAt:        sp.ExecuteAt,
+		Frequency:        sp.Frequency,
+		EndDate:          sp.EndDate,
+		Count:            sp.Count,
+		Occurrences:      sp.Occurrences,
+		NextRun:          sp.NextRun,
+		Status:           sp.Status,
+		LastResult:       sp.LastResult,
+	}
+}
+
+// occurrenceTime returns when the n-th payment (counting from 0) of a schedule is due.
+// Recurrences are computed from the first execution time so monthly payments keep their
+// day, falling on the last day of months that are too short for it.
+func occurrenceTime(start time.Time, frequency string, n int32) time.Time {
+	switch frequency {
+	case "daily":
+		return start.AddDate(0, 0, int(n))
+	case "weekly":
+		return start.AddDate(0, 0, 7*int(n))
+	case "monthly":
+		// AddDate normalizes Jan 31 + 1 month to Mar 3, so take the first of the target
+		// month and clamp the day to its length.
+		first := time.Date(start.Year(), start.Month(), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		target := first.AddDate(0, int(n), 0)
+		day := start.Day()
+		if last := target.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return target.AddDate(0, 0, day-1)
+	}
+	return start
+}
+
+// advance moves a schedule past its current occurrence and completes it once the
+// recurrence rule is exhausted.
+func (sp *ScheduledPayment) advance() {
+	sp.Occurrences++
+	start, _ := time.Parse(time.RFC3339, sp.ExecuteAt)
+	next := occurrenceTime(start, sp.Frequency, sp.Occurrences)
+	sp.NextRun = next.Format(time.RFC3339)
+
+	done := sp.Frequency == "once" || (sp.Count > 0 && sp.Occurrences >= sp.Count)
+	if sp.EndDate != "" {
+		end, _ := time.Parse(time.RFC3339, sp.EndDate)
+		done = done || next.After(end)
+	}
+	if done {
+		sp.Status = scheduleCompleted
+	}
+}
+
+// transactionFor builds the payment for a schedule's current occurrence. The transaction
+// id and idempotency key are derived from the schedule and occurrence, so an occurrence
+// is recognisable in the history and never produces two payments.
+func (sp *ScheduledPayment) transactionFor() *paymentpb.TransactionRequest {
+	id := fmt.Sprintf("%s-%d", sp.ScheduleId, sp.Occurrences+1)
+	return &paymentpb.TransactionRequest{
+		TransactionId:    id,
+		SenderUsername:   sp.SenderUsername,
+		ReceiverUsername: sp.ReceiverUsername,
+		Amount:           sp.Amount,
+		SenderBank:       sp.SenderBank,
+		ReceiverBank:     sp.ReceiverBank,
+		IdempotencyKey:   id,
+		Currency:         sp.Currency,
+	}
+}
+
+// LoadSchedules loads persisted schedules. A missing file means there are none yet. An
+// occurrence that was being paid when the gateway stopped is settled from the history
+// and not paid again.
+func (s *PaymentGatewayServer) LoadSchedules(filename string) error {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+
+	s.schedulesFile = filename
+	s.schedules = make(map[string]*ScheduledPayment)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var list []*ScheduledPayment
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	var records []TransactionRecord
+	loaded := false
+	for _, sp := range list {
+		s.schedules[sp.ScheduleId] = sp
+		if sp.Firing == "" {
+			continue
+		}
+		if !loaded {
+			records = s.loadTransactionRecords()
+			loaded = true
+		}
+		sp.LastResult = occurrenceResult(sp.Firing, records)
+		log.Printf("Scheduled payment %s was being paid when the gateway stopped: %s", sp.Firing, sp.LastResult)
+		sp.Firing = ""
+		if sp.Status != scheduleCancelled {
+			sp.advance()
+		}
+	}
+	if loaded {
+		s.saveSchedules()
+	}
+	return nil
+}
+
+// occurrenceResult describes the outcome of an interrupted occurrence from the last
+// record of its payment.
+func occurrenceResult(transactionId string, records []TransactionRecord) string {
+	result := "Interrupted by a gateway restart; not repeated, check the transaction history"
+	for _, rec := range records {
+		if rec.TransactionId != transactionId || rec.RefundOf != "" {
+			continue
+		}
+		switch rec.Status {
+		case "":
+			return "Transaction committed successfully"
+		case inDoubtStatus:
+			result = "Payment partially committed; awaiting reconciliation"
+		case riskReview:
+			result = fmt.Sprintf("Payment held for manual review %s", rec.ReviewId)
+		default:
+			result = fmt.Sprintf("Payment failed: %s", rec.Message)
+		}
+	}
+	return result
+}
+
+// saveSchedules writes all schedules to disk. The caller must hold scheduleMu.
+func (s *PaymentGatewayServer) saveSchedules() {
+	list := make([]*ScheduledPayment, 0, len(s.schedules))
+	for _, sp := range s.schedules {
+		list = append(list, sp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ScheduleId < list[j].ScheduleId })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling schedules: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.schedulesFile, data, 0644); err != nil {
+		log.Printf("Error writing schedules: %v", err)
+	}
+}
+
+// StartScheduler checks for due scheduled payments at the given interval.
+func (s *PaymentGatewayServer) StartScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.runDueSchedules(time.Now())
+		}
+	}()
+}
+
+// runDueSchedules fires every active schedule whose next run is due, through the same
+// ProcessPayment path as interactive payments.
+func (s *PaymentGatewayServer) runDueSchedules(now time.Time) {
+	s.scheduleMu.Lock()
+	due := make(map[string]*paymentpb.TransactionRequest)
+	for id, sp := range s.schedules {
+		next, _ := time.Parse(time.RFC3339, sp.NextRun)
+		if sp.Status == scheduleActive && sp.Firing == "" && !next.After(now) {
+			due[id] = sp.transactionFor()
+			sp.Firing = due[id].TransactionId
+		}
+	}
+	if len(due) > 0 {
+		s.saveSchedules()
+	}
+	s.scheduleMu.Unlock()
+
+	for id, txReq := range due {
+		result := "Transaction committed successfully"
+		// A payment found in the history was made before a restart; it is not repeated.
+		if _, _, err := s.findTransaction(txReq.TransactionId); status.Code(err) == codes.NotFound {
+			resp, err := s.payNow(context.Background(), txReq)
+			if err != nil {
+				result = fmt.Sprintf("Payment failed: %v", err)
+			} else if resp.ReviewId != "" {
+				result = fmt.Sprintf("Payment held for manual review %s", resp.ReviewId)
+			}
+		}
+		log.Printf("Scheduled payment %s: %s", txReq.TransactionId, result)
+
+		s.scheduleMu.Lock()
+		if sp, ok := s.schedules[id]; ok {
+			sp.LastResult = result
+			sp.Firing = ""
+			if sp.Status != scheduleCancelled {
+				sp.advance()
+			}
+			s.saveSchedules()
+		}
+		s.scheduleMu.Unlock()
+	}
+}
+
+// SchedulePayment stores a future or recurring payment from the authenticated user.
+func (s *PaymentGatewayServer) SchedulePayment(ctx context.Context, req *paymentpb.SchedulePaymentRequest) (*paymentpb.SchedulePaymentResponse, error) {
+	caller := authenticatedUser(ctx)
+	if caller != req.SenderUsername && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot schedule payments for %s", caller, req.SenderUsername)
+	}
+	if req.Amount <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Amount must be positive")
+	}
+	if req.Count < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Count must not be negative")
+	}
+	start, err := time.Parse(time.RFC3339, req.ExecuteAt)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid executeAt: %v", err)
+	}
+	frequency := req.Frequency
+	if frequency == "" {
+		frequency = "once"
+	}
+	switch frequency {
+	case "once", "daily", "weekly", "monthly":
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown frequency %q", req.Frequency)
+	}
+	if req.EndDate != "" {
+		end, err := time.Parse(time.RFC3339, req.EndDate)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid endDate: %v", err)
+		}
+		if end.Before(start) {
+			return nil, status.Errorf(codes.InvalidArgument, "endDate is before executeAt")
+		}
+	}
+
+	sp := &ScheduledPayment{
+		ScheduleId:       uuid.New().String(),
+		SenderUsername:   req.SenderUsername,
+		ReceiverUsername: req.ReceiverUsername,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		SenderBank:       s.bankOf("", req.SenderUsername),
+		ReceiverBank:     s.bankOf("", req.ReceiverUsername),
+		ExecuteAt:        start.Format(time.RFC3339),
+		Frequency:        frequency,
+		EndDate:          req.EndDate,
+		Count:            req.Count,
+		NextRun:          start.Format(time.RFC3339),
+		Status:           scheduleActive,
+	}
+
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	s.schedules[sp.ScheduleId] = sp
+	s.saveSchedules()
+	log.Printf("Scheduled payment %s from %s to %s (%s) starting %s", sp.ScheduleId, sp.SenderUsername, sp.ReceiverUsername, sp.Frequency, sp.ExecuteAt)
+
+	return &paymentpb.SchedulePaymentResponse{Success: true, Message: "Payment scheduled", ScheduleId: sp.ScheduleId}, nil
+}
+
+// ListSchedules returns the schedules of a user; operators may list everyone's by
+// leaving the username empty.
+func (s *PaymentGatewayServer) ListSchedules(ctx context.Context, req *paymentpb.ListSchedulesRequest) (*paymentpb.ListSchedulesResponse, error) {
+	caller := authenticatedUser(ctx)
+	if req.Username != caller && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot list schedules for %s", caller, req.Username)
+	}
+
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+	var list []*paymentpb.ScheduledPayment
+	for _, sp := range s.schedules {
+		if req.Username == "" || sp.SenderUsername == req.Username {
+			list = append(list, sp.toProto())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NextRun < list[j].NextRun })
+	return &paymentpb.ListSchedulesResponse{Schedules: list}, nil
+}
+
+// updateSchedule changes the status of a schedule owned by the caller (or any schedule
+// for operators), if the schedule is currently in one of the allowed states.
+func (s *PaymentGatewayServer) updateSchedule(ctx context.Context, scheduleId, newStatus string, allowed ...string) (*paymentpb.ScheduleActionResponse, error) {
+	s.scheduleMu.Lock()
+	defer s.scheduleMu.Unlock()
+
+	sp, ok := s.schedules[scheduleId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Schedule %s not found", scheduleId)
+	}
+	caller := authenticatedUser(ctx)
+	if caller != sp.SenderUsername && !isOperator(caller) {
+		return nil, status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot modify schedule %s", caller, scheduleId)
+	}
+	permitted := false
+	for _, st := range allowed {
+		permitted = permitted || sp.Status == st
+	}
+	if !permitted {
+		return nil, status.Errorf(codes.FailedPrecondition, "Schedule %s is %s", scheduleId, sp.Status)
+	}
+	sp.Status = newStatus
+	s.saveSchedules()
+	log.Printf("Schedule %s is now %s", scheduleId, newStatus)
+	return &paymentpb.ScheduleActionResponse{Success: true, Message: "Schedule " + newStatus}, nil
+}
+
+// PauseSchedule stops an active schedule from firing until it is resumed.
+func (s *PaymentGatewayServer) PauseSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return s.updateSchedule(ctx, req.ScheduleId, schedulePaused, scheduleActive)
+}
+
+// ResumeSchedule re-activates a paused schedule. Occurrences that fell due while it was
+// paused are made on the next scheduler run, one per run.
+func (s *PaymentGatewayServer) ResumeSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return s.updateSchedule(ctx, req.ScheduleId, scheduleActive, schedulePaused)
+}
+
+// CancelSchedule permanently stops a schedule.
+func (s *PaymentGatewayServer) CancelSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return s.updateSchedule(ctx, req.ScheduleId, scheduleCancelled, scheduleActive, schedulePaused)
+}
@@ -0,0 +1,155 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// PaymentGatewayServer implements the PaymentGateway service.
+type PaymentGatewayServer struct {
+	paymentpb.UnimplementedPaymentGatewayServer
+	processedTxs sync.Map // stores outcome keyed by idempotency key
+	users        sync.Map // stores registeredUser keyed by username
+
+	// Mutex for transaction history file.
+	historyMu sync.Mutex
+	// Path to the transaction history JSON file.
+	historyFile string
+
+	// Mutex for the settlement ledger file and the latest netting result.
+	settlementMu sync.Mutex
+	// Path to the inter-bank settlement ledger JSON file.
+	ledgerFile string
+	// Result of the most recent netting run.
+	lastNetting *nettingResult
+
+	// FX rate table used when sender and receiver currencies differ.
+	fx *fxTable
+	// Fee schedule applied to payments; nil when no fees are charged.
+	fees *FeeSchedule
+	// Spending limits checked before payments are prepared; nil when not enforced.
+	limits *spendingLimits
+	// Operator accounts keyed by username; read-only once loaded.
+	operators map[string]Operator
+	// Fraud screening rules; nil when payments are not screened.
+	risk *riskEngine
+
+	// Serializes refunds so partial refunds never exceed the original amount.
+	refundMu sync.Mutex
+
+	// Mutex for the scheduled payments and their JSON file.
+	scheduleMu    sync.Mutex
+	schedulesFile string
+	schedules     map[string]*ScheduledPayment
+
+	// Mutex for the payment requests and their JSON file.
+	requestMu       sync.Mutex
+	requestsFile    string
+	paymentRequests map[string]*PaymentRequest
+
+	// Mutex for the escrows and their JSON file.
+	escrowMu    sync.Mutex
+	escrowsFile string
+	escrows     map[string]*Escrow
+
+	// Mutex for the payment batches and their JSON file.
+	batchMu     sync.Mutex
+	batchesFile string
+	batches     map[string]*PaymentBatch
+
+	// Mutex for the manual review queue and its JSON file.
+	reviewMu    sync.Mutex
+	reviewsFile string
+	reviews     map[string]*ReviewItem
+
+	// Mutex for the disputes and their JSON file.
+	disputeMu    sync.Mutex
+	disputesFile string
+	disputes     map[string]*Dispute
+
+	// Mutex for the merchant API keys and their JSON file.
+	apiKeyMu    sync.Mutex
+	apiKeysFile string
+	apiKeys     map[string]*MerchantKey
+
+	// Mutex for the invoices and their JSON file.
+	invoiceMu    sync.Mutex
+	invoicesFile string
+	invoices     map[string]*Invoice
+
+	// Mutex for the webhook endpoints, the outbox and their JSON files.
+	webhookMu    sync.Mutex
+	webhooksFile string
+	outboxFile   string
+	webhooks     map[string]*WebhookEndpoint
+	outbox       []*WebhookDelivery
+	// Whether endpoints may be on loopback, private or link-local addresses.
+	webhookPrivateHosts bool
+
+	// Mutex for the recent events streamed by SubscribeEvents.
+	eventMu sync.Mutex
+	events  *eventLog
+
+	// Mutex for the asynchronous payments, their JSON file and the worker queue.
+	asyncMu       sync.Mutex
+	asyncFile     string
+	asyncPayments map[string]*AsyncPayment
+	asyncByKey    map[string]string // payment id keyed by idempotency key
+	paymentQueue  chan *AsyncPayment
+
+	// Mutex for the payments waiting for an unavailable bank and their JSON file.
+	bankQueueMu   sync.Mutex
+	bankQueueFile string
+	bankQueue     map[string]*QueuedPayment
+}
+
+// Global pointer to the active gateway instance.
+var gatewayInstance *PaymentGatewayServer
+
+// NewPaymentGatewayServer creates the gateway and makes it the active instance
+// used by the interceptors.
+func NewPaymentGatewayServer(historyFile, ledgerFile string) *PaymentGatewayServer {
+	s := &PaymentGatewayServer{
+		historyFile: historyFile,
+		ledgerFile:  ledgerFile,
+		events:      newEventLog(),
+	}
+	gatewayInstance = s
+	return s
+}
+
+// unaryInterceptors check, authorize and log every unary call, over gRPC and REST.
+var unaryInterceptors = []grpc.UnaryServerInterceptor{
+	authInterceptor,
+	authorizationInterceptor,
+	loggingInterceptor,
+}
+
+// UnaryInterceptors returns the interceptor chain for the gateway's gRPC server.
+func UnaryInterceptors() grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(unaryInterceptors...)
+}
+
+// unaryInterceptor runs unaryInterceptors as one interceptor, for calls that do not come
+// through the gRPC server.
+func unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	next := handler
+	for i := len(unaryInterceptors) - 1; i >= 0; i-- {
+		interceptor, inner := unaryInterceptors[i], next
+		next = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, inner)
+		}
+	}
+	return next(ctx, req)
+}
+
+// StreamInterceptors returns the interceptor chain for the gateway's streaming RPCs.
+func StreamInterceptors() grpc.ServerOption {
+	return grpc.ChainStreamInterceptor(
+		streamAuthInterceptor,
+	)
+}
@@ -0,0 +1,261 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// InterbankPosition records what one bank owes another for a single cross-bank payment.
+// The sender's bank has debited its customer and owes the amount to the receiver's bank
+// until the position is included in a settlement.
+type InterbankPosition struct {
+	TransactionId string  `json:"transactionId"`
+	DebtorBank    string  `json:"debtorBank"`
+	CreditorBank  string  `json:"creditorBank"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency,omitempty"`
+	Timestamp     string  `json:"timestamp"`
+	SettlementId  string  `json:"settlementId,omitempty"`
+	// The settlement being posted for this position, saved before any bank is posted to
+	// so an interrupted settlement is retried with the same id and positions.
+	PendingSettlementId string `json:"pendingSettlementId,omitempty"`
+}
+
+// nettingResult holds the net obligations computed by one netting run.
+type nettingResult struct {
+	positions  []*paymentpb.NetPosition
+	computedAt time.Time
+}
+
+// loadLedger reads the settlement ledger. The caller must hold settlementMu.
+func (s *PaymentGatewayServer) loadLedger() []InterbankPosition {
+	var ledger []InterbankPosition
+	if _, err := os.Stat(s.ledgerFile); err == nil {
+		data, err := ioutil.ReadFile(s.ledgerFile)
+		if err == nil {
+			json.Unmarshal(data, &ledger)
+		}
+	}
+	return ledger
+}
+
+// saveLedger writes the settlement ledger. The caller must hold settlementMu.
+func (s *PaymentGatewayServer) saveLedger(ledger []InterbankPosition) error {
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.ledgerFile, data, 0644)
+}
+
+// recordInterbankPosition appends an unsettled position to the settlement ledger.
+func (s *PaymentGatewayServer) recordInterbankPosition(pos InterbankPosition) {
+	s.settlementMu.Lock()
+	defer s.settlementMu.Unlock()
+
+	ledger := append(s.loadLedger(), pos)
+	if err := s.saveLedger(ledger); err != nil {
+		log.Printf("Error writing settlement ledger: %v", err)
+	}
+}
+
+// netPositions offsets the unsettled positions of every bank pair against each other
+// and returns one obligation per pair and currency, ordered by debtor, creditor and currency.
+func netPositions(ledger []InterbankPosition) []*paymentpb.NetPosition {
+	type bankPair struct{ low, high, currency string }
+	net := make(map[bankPair]float64)
+	counts := make(map[bankPair]int32)
+	for _, pos := range ledger {
+		if pos.SettlementId != "" {
+			continue
+		}
+		// Amounts owed by the lexically lower bank are positive, the reverse negative.
+		if pos.DebtorBank < pos.CreditorBank {
+			p := bankPair{pos.DebtorBank, pos.CreditorBank, pos.Currency}
+			net[p] += pos.Amount
+			counts[p]++
+		} else {
+			p := bankPair{pos.CreditorBank, pos.DebtorBank, pos.Currency}
+			net[p] -= pos.Amount
+			counts[p]++
+		}
+	}
+
+	var result []*paymentpb.NetPosition
+	for p, amount := range net {
+		amount = math.Round(amount*100) / 100
+		switch {
+		case amount > 0:
+			result = append(result, &paymentpb.NetPosition{DebtorBank: p.low, CreditorBank: p.high, Amount: amount, TransactionCount: counts[p], Currency: p.currency})
+		case amount < 0:
+			result = append(result, &paymentpb.NetPosition{DebtorBank: p.high, CreditorBank: p.low, Amount: -amount, TransactionCount: counts[p], Currency: p.currency})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].DebtorBank != result[j].DebtorBank {
+			return result[i].DebtorBank < result[j].DebtorBank
+		}
+		if result[i].CreditorBank != result[j].CreditorBank {
+			return result[i].CreditorBank < result[j].CreditorBank
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result
+}
+
+// runNetting computes the current net obligations and keeps them as the latest result.
+func (s *PaymentGatewayServer) runNetting() *nettingResult {
+	s.settlementMu.Lock()
+	defer s.settlementMu.Unlock()
+
+	result := &nettingResult{positions: netPositions(s.loadLedger()), computedAt: time.Now()}
+	s.lastNetting = result
+	return result
+}
+
+// StartNettingJob periodically recomputes the net obligations between banks.
+func (s *PaymentGatewayServer) StartNettingJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result := s.runNetting()
+			for _, np := range result.positions {
+				log.Printf("Netting: %s owes %s %.2f %s across %d transactions", np.DebtorBank, np.CreditorBank, np.Amount, np.Currency, np.TransactionCount)
+			}
+		}
+	}()
+}
+
+// postSettlement posts one side of a net settlement to a bank's settlement account.
+func postSettlement(ctx context.Context, bank string, req *paymentpb.PostSettlementRequest) error {
+	conn, err := grpc.Dial(bank, grpc.WithInsecure())
+	if err != nil {
+		return fmt.Errorf("failed to connect to bank %s: %v", bank, err)
+	}
+	defer conn.Close()
+	resp, err := paymentpb.NewBankServiceClient(conn).PostSettlement(ctx, req)
+	if err != nil {
+		return fmt.Errorf("error from bank %s: %v", bank, err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("bank %s rejected settlement: %s", bank, resp.Message)
+	}
+	return nil
+}
+
+// GetSettlementReport returns the net obligations from the latest netting run.
+func (s *PaymentGatewayServer) GetSettlementReport(ctx context.Context, req *paymentpb.SettlementReportRequest) (*paymentpb.SettlementReportResponse, error) {
+	s.settlementMu.Lock()
+	result := s.lastNetting
+	s.settlementMu.Unlock()
+	if result == nil {
+		result = s.runNetting()
+	}
+	return &paymentpb.SettlementReportResponse{
+		Positions:  result.positions,
+		ComputedAt: result.computedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// SettlePositions nets all unsettled positions and posts each net obligation to the
+// settlement accounts of both banks. The settlement id and the positions it covers are
+// saved before anything is posted, and positions are marked settled only when every
+// posting has succeeded. A failed settlement is retried, with the same id and positions,
+// by the next call, and banks ignore the postings they have already applied.
+func (s *PaymentGatewayServer) SettlePositions(ctx context.Context, req *paymentpb.SettlePositionsRequest) (*paymentpb.SettlePositionsResponse, error) {
+	s.settlementMu.Lock()
+	defer s.settlementMu.Unlock()
+
+	ledger := s.loadLedger()
+	id := ""
+	for _, pos := range ledger {
+		if pos.SettlementId == "" && pos.PendingSettlementId != "" {
+			id = pos.PendingSettlementId
+			break
+		}
+	}
+	if id == "" {
+		id = uuid.New().String()
+		pending := 0
+		for i := range ledger {
+			if ledger[i].SettlementId == "" {
+				ledger[i].PendingSettlementId = id
+				pending++
+			}
+		}
+		if pending == 0 {
+			return &paymentpb.SettlePositionsResponse{Success: true, Message: "No unsettled positions"}, nil
+		}
+		if err := s.saveLedger(ledger); err != nil {
+			return nil, status.Errorf(codes.Internal, "Error writing settlement ledger: %v", err)
+		}
+	} else {
+		log.Printf("Retrying interrupted settlement %s", id)
+	}
+
+	var covered []InterbankPosition
+	for _, pos := range ledger {
+		if pos.SettlementId == "" && pos.PendingSettlementId == id {
+			covered = append(covered, pos)
+		}
+	}
+	positions := netPositions(covered)
+
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	for _, np := range positions {
+		if err := postSettlement(ctx2, np.DebtorBank, &paymentpb.PostSettlementRequest{
+			SettlementId:     id,
+			CounterpartyBank: np.CreditorBank,
+			Amount:           np.Amount,
+			IsDebit:          true,
+			Currency:         np.Currency,
+		}); err != nil {
+			return nil, status.Errorf(codes.Aborted, "Settlement %s failed: %v", id, err)
+		}
+		if err := postSettlement(ctx2, np.CreditorBank, &paymentpb.PostSettlementRequest{
+			SettlementId:     id,
+			CounterpartyBank: np.DebtorBank,
+			Amount:           np.Amount,
+			IsDebit:          false,
+			Currency:         np.Currency,
+		}); err != nil {
+			return nil, status.Errorf(codes.Aborted, "Settlement %s failed: %v", id, err)
+		}
+	}
+
+	for i := range ledger {
+		if ledger[i].SettlementId == "" && ledger[i].PendingSettlementId == id {
+			ledger[i].SettlementId = id
+			ledger[i].PendingSettlementId = ""
+		}
+	}
+	if err := s.saveLedger(ledger); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error writing settlement ledger: %v", err)
+	}
+	s.lastNetting = nil
+	log.Printf("Settlement %s posted %d net obligations covering %d transactions", id, len(positions), len(covered))
+
+	return &paymentpb.SettlePositionsResponse{
+		Success:      true,
+		Message:      fmt.Sprintf("Settled %d transactions", len(covered)),
+		SettlementId: id,
+		Settled:      positions,
+	}, nil
+}
@@ -7,7 +7,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"sync"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
@@ -25,12 +25,69 @@ type TransactionRecord struct {
 	Amount        float64 `json:"amount"`
 	Timestamp     string  `json:"timestamp"`
 	Message       string  `json:"message"`
+	// Currency of Amount, and what the receiver was credited after any FX conversion.
+	Currency         string  `json:"currency,omitempty"`
+	ReceivedAmount   float64 `json:"receivedAmount,omitempty"`
+	ReceivedCurrency string  `json:"receivedCurrency,omitempty"`
+	FxRate           float64 `json:"fxRate,omitempty"`
+	FxSpread         float64 `json:"fxSpread,omitempty"`
+	// Fee charged to the sender on top of Amount, in the send currency.
+	Fee float64 `json:"fee,omitempty"`
+	// Original transaction id when this record is a refund.
+	RefundOf     string `json:"refundOf,omitempty"`
+	SenderBank   string `json:"senderBank,omitempty"`
+	ReceiverBank string `json:"receiverBank,omitempty"`
+	// Set on payment request, escrow and multi-party parent records; such records are not
+	// payments themselves.
+	PaymentRequestId string `json:"paymentRequestId,omitempty"`
+	Status           string `json:"status,omitempty"`
+	// Escrow the record belongs to, for escrow events and escrow releases.
+	EscrowId string `json:"escrowId,omitempty"`
+	// Parent transaction of a multi-party payment leg, and all users of a parent record.
+	ParentTransactionId string   `json:"parentTransactionId,omitempty"`
+	Participants        []string `json:"participants,omitempty"`
+	// Outcome of risk screening, and the manual review the payment went through, if any.
+	RiskDecision string   `json:"riskDecision,omitempty"`
+	RiskScore    float64  `json:"riskScore,omitempty"`
+	RiskRules    []string `json:"riskRules,omitempty"`
+	ReviewId     string   `json:"reviewId,omitempty"`
+	// Invoice the payment paid, if any.
+	InvoiceReference string `json:"invoiceReference,omitempty"`
 }
 
-// storeTransactionRecord appends a transaction record to the persistent JSON file.
+// newTransactionRecord builds the history record for a committed payment.
+func newTransactionRecord(req *paymentpb.TransactionRequest, quote *paymentQuote, risk *riskAssessment) TransactionRecord {
+	record := TransactionRecord{
+		TransactionId:    req.TransactionId,
+		Sender:           req.SenderUsername,
+		Receiver:         req.ReceiverUsername,
+		Amount:           quote.sendAmount,
+		Timestamp:        time.Now().Format(time.RFC3339),
+		Message:          "Transaction committed successfully",
+		Currency:         quote.sendCurrency,
+		ReceivedAmount:   quote.receiveAmount,
+		ReceivedCurrency: quote.receiveCurrency,
+		FxRate:           quote.rate,
+		FxSpread:         quote.spread,
+		Fee:              quote.fee,
+		SenderBank:       req.SenderBank,
+		ReceiverBank:     req.ReceiverBank,
+		InvoiceReference: req.InvoiceReference,
+	}
+	risk.applyTo(&record)
+	return record
+}
+
+// storeTransactionRecord appends a transaction record to the persistent JSON file. The
+// webhook events of a payment or refund are saved to the outbox first, so a record is
+// never in the history without its events; if the gateway stops in between, the events
+// of a payment the banks have committed are still delivered.
 func (s *PaymentGatewayServer) storeTransactionRecord(record TransactionRecord) {
 	s.historyMu.Lock()
 	defer s.historyMu.Unlock()
+	if record.Status == "" {
+		s.enqueuePaymentEvent(record)
+	}
 
 	var records []TransactionRecord
 	if _, err := os.Stat(s.historyFile); err == nil {
@@ -47,30 +104,190 @@ func (s *PaymentGatewayServer) storeTransactionRecord(record TransactionRecord)
 	}
 	if err := ioutil.WriteFile(s.historyFile, newData, 0644); err != nil {
 		log.Printf("Error writing transaction history: %v", err)
+		return
+	}
+	// Payments and refunds are reported to event subscribers; event records are not.
+	if record.Status == "" {
+		s.publishRecordEvents(record)
+	}
+}
+
+// paymentInDoubt records a payment that was partially committed in both users' history
+// and returns err. Its idempotency key stays claimed, so retries do not pay again.
+func (s *PaymentGatewayServer) paymentInDoubt(req *paymentpb.TransactionRequest, quote *paymentQuote, risk *riskAssessment, err error) error {
+	s.processedTxs.Store(req.IdempotencyKey, inDoubtStatus)
+	record := newTransactionRecord(req, quote, risk)
+	record.Status = inDoubtStatus
+	record.Message = "Payment partially committed; awaiting reconciliation"
+	s.storeTransactionRecord(record)
+	return err
+}
+
+// paymentNowKey marks the context of a payment made for another operation, such as a
+// payment request or a batch item. Such a payment must be made now: a replayed
+// idempotency key fails instead of reporting the outcome of an earlier payment.
+type paymentNowKey struct{}
+
+// payNow runs a payment for another operation through ProcessPayment. A nil error means
+// the payment committed, or was held for review or queued as the response says.
+func (s *PaymentGatewayServer) payNow(ctx context.Context, req *paymentpb.TransactionRequest) (*paymentpb.TransactionResponse, error) {
+	resp, err := s.ProcessPayment(context.WithValue(ctx, paymentNowKey{}, true), req)
+	if err == nil && !resp.Success && resp.ReviewId == "" && resp.QueueId == "" {
+		return nil, status.Errorf(codes.Aborted, "Payment not made: %s", resp.Message)
 	}
+	return resp, err
 }
 
 // ProcessPayment implements idempotency and two-phase commit.
-func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentpb.TransactionRequest) (*paymentpb.TransactionResponse, error) {
+func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentpb.TransactionRequest) (resp *paymentpb.TransactionResponse, err error) {
+	// An invoice payment takes its receiver, currency and amount from the invoice.
+	if req.InvoiceReference != "" {
+		if err := s.fillInvoicePayment(req); err != nil {
+			return nil, err
+		}
+	}
+
 	// First, verify that both sender and receiver are registered.
-	_, senderRegistered := s.users.Load(req.SenderUsername)
-	_, receiverRegistered := s.users.Load(req.ReceiverUsername)
+	senderVal, senderRegistered := s.users.Load(req.SenderUsername)
+	receiverVal, receiverRegistered := s.users.Load(req.ReceiverUsername)
 	if !senderRegistered || !receiverRegistered {
 		return nil, status.Errorf(codes.FailedPrecondition, "One or both users are not registered")
 	}
+	senderUser := senderVal.(registeredUser)
+	receiverUser := receiverVal.(registeredUser)
+	// The payment goes through the banks the users registered with, whatever banks the
+	// request names, so fees, positions and connections cannot be steered by the caller.
+	req.SenderBank = senderUser.bank
+	req.ReceiverBank = receiverUser.bank
 
 	idempotencyKey := req.IdempotencyKey
 	if idempotencyKey == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "IdempotencyKey must be provided")
 	}
-	// Check if the transaction has already been processed.
-	if result, exists := s.processedTxs.Load(idempotencyKey); exists {
+	// Check if the transaction has already been processed, claiming the key if not. A
+	// queued payment being delivered takes over the key it parked instead.
+	if queueId, delivering := ctx.Value(bankDeliveryKey{}).(string); delivering {
+		if !s.processedTxs.CompareAndSwap(idempotencyKey, queuedForBank(queueId), false) {
+			return nil, status.Errorf(codes.FailedPrecondition, "IdempotencyKey %s is no longer held by queued payment %s", idempotencyKey, queueId)
+		}
+	} else if result, exists := s.processedTxs.LoadOrStore(idempotencyKey, false); exists {
+		if ctx.Value(paymentNowKey{}) != nil {
+			return nil, status.Errorf(codes.AlreadyExists, "IdempotencyKey %s has already been used", idempotencyKey)
+		}
+		if reviewId, held := result.(heldForReview); held {
+			return &paymentpb.TransactionResponse{Success: false, Message: "Payment held for manual review", ReviewId: string(reviewId)}, nil
+		}
+		if queueId, queued := result.(queuedForBank); queued {
+			return &paymentpb.TransactionResponse{Success: false, Message: "Payment queued until its banks are available", QueueId: string(queueId)}, nil
+		}
+		if result == inDoubtStatus {
+			return nil, status.Errorf(codes.DataLoss, "Payment with IdempotencyKey %s was partially committed and awaits reconciliation", idempotencyKey)
+		}
 		msg := fmt.Sprintf("Transaction already processed: %v", result)
 		log.Println(msg)
 		return &paymentpb.TransactionResponse{Success: true, Message: msg}, nil
 	}
-	s.processedTxs.Store(idempotencyKey, false)
 	log.Printf("Processing transaction with idempotency key: %s", idempotencyKey)
+	s.publishPaymentStatus(req, "pending", "Payment received")
+
+	// Payments that do not commit are reported to event subscribers; committed ones are
+	// reported with their record. Payments the banks aborted also go to webhook endpoints.
+	defer func() {
+		switch {
+		case err == nil && resp.ReviewId != "":
+			s.publishPaymentStatus(req, "held_for_review", resp.Message)
+		case err == nil && resp.QueueId != "":
+			s.publishPaymentStatus(req, "queued", resp.Message)
+		case status.Code(err) == codes.Aborted:
+			s.publishPaymentStatus(req, "aborted", status.Convert(err).Message())
+		case status.Code(err) == codes.PermissionDenied:
+			s.publishPaymentStatus(req, "denied", status.Convert(err).Message())
+		case partiallyCommitted(err):
+			s.publishPaymentStatus(req, inDoubtStatus, status.Convert(err).Message())
+		case err != nil:
+			s.publishPaymentStatus(req, "failed", status.Convert(err).Message())
+		}
+		if status.Code(err) == codes.Aborted {
+			s.enqueueWebhookEvent(eventPaymentAborted, TransactionRecord{
+				TransactionId:    req.TransactionId,
+				Sender:           req.SenderUsername,
+				Receiver:         req.ReceiverUsername,
+				Amount:           req.Amount,
+				Timestamp:        time.Now().Format(time.RFC3339),
+				Message:          status.Convert(err).Message(),
+				Currency:         req.Currency,
+				SenderBank:       req.SenderBank,
+				ReceiverBank:     req.ReceiverBank,
+				InvoiceReference: req.InvoiceReference,
+			})
+		}
+	}()
+
+	// When a bank is down and the sender asked for it, the payment waits in the bank queue;
+	// limits, screening and fees are applied when it is delivered.
+	if req.QueueIfBankDown {
+		banks := s.paymentBanks(req, senderUser, receiverUser)
+		if down := unavailableBanks(banks); len(down) > 0 {
+			queueId, err := s.queueForBanks(req, banks, down)
+			if err != nil {
+				s.processedTxs.Delete(idempotencyKey)
+				return nil, err
+			}
+			s.processedTxs.Store(idempotencyKey, queuedForBank(queueId))
+			return &paymentpb.TransactionResponse{Success: false, Message: fmt.Sprintf("Bank %s unavailable; payment queued", strings.Join(down, ", ")), QueueId: queueId}, nil
+		}
+	}
+
+	// Check the sender's spending limits before any bank is involved; the fee counts
+	// towards them too. The payment stays counted against them only if it commits.
+	fee := s.feeFor(req)
+	currency := req.Currency
+	if currency == "" && s.limits != nil {
+		if currency, err = accountCurrency(ctx, req.SenderBank, req.SenderUsername); err != nil {
+			s.processedTxs.Delete(idempotencyKey)
+			return nil, err
+		}
+	}
+	spend, err := s.reserveSpend(req.SenderUsername, req.Amount+fee, currency)
+	if err != nil {
+		s.processedTxs.Delete(idempotencyKey)
+		return nil, err
+	}
+	defer spend.release()
+
+	// Hold the amount against the invoice so concurrent payments cannot overpay it. The
+	// invoice records the payment right after the payment's own record is written.
+	invoice, err := s.reserveInvoice(req)
+	if err != nil {
+		s.processedTxs.Delete(idempotencyKey)
+		return nil, err
+	}
+	defer invoice.release()
+
+	// Screen the payment for fraud. A denied payment releases its idempotency key; a held
+	// one keeps it parked until an operator decides, so retries do not queue it twice.
+	risk := s.screenPayment(ctx, req)
+	if risk != nil && risk.decision == riskDeny {
+		s.processedTxs.Delete(idempotencyKey)
+		s.recordRiskEvent(req, risk, "Payment denied by risk screening")
+		return nil, status.Errorf(codes.PermissionDenied, "Payment denied by risk screening")
+	}
+	if risk != nil && risk.decision == riskReview {
+		reviewId := s.queueReview(req, risk)
+		s.processedTxs.Store(idempotencyKey, heldForReview(reviewId))
+		return &paymentpb.TransactionResponse{Success: false, Message: "Payment held for manual review", ReviewId: reviewId}, nil
+	}
+
+	// Both accounts are held at the same bank, so a single atomic transfer replaces 2PC,
+	// unless a fee has to be credited at another bank.
+	if senderUser.bank == receiverUser.bank && (fee == 0 || s.fees.FeeBank == senderUser.bank) {
+		resp, err := s.transferWithinBank(ctx, req, senderUser.bank, fee, risk)
+		if err == nil {
+			spend.commit()
+			invoice.commit(req.TransactionId)
+		}
+		return resp, err
+	}
 
 	// Connect to bank servers (using insecure connections for internal communication).
 	senderConn, err := grpc.Dial(req.SenderBank, grpc.WithInsecure())
@@ -90,11 +307,36 @@ func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentp
 	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Phase 1: Prepare on sender.
+	quote, err := s.quotePayment(ctx2, req, senderClient, receiverClient)
+	if err != nil {
+		// Nothing has been prepared yet, so the same key may be retried.
+		s.processedTxs.Delete(idempotencyKey)
+		return nil, err
+	}
+	quote.fee = fee
+
+	// The fee account takes part in the 2PC as a third participant.
+	var feeClient paymentpb.BankServiceClient
+	if fee > 0 {
+		feeConn, err := grpc.Dial(s.fees.FeeBank, grpc.WithInsecure())
+		if err != nil {
+			s.processedTxs.Delete(idempotencyKey)
+			return nil, status.Errorf(codes.FailedPrecondition, "Error connecting to fee bank: %v", err)
+		}
+		defer feeConn.Close()
+		feeClient = paymentpb.NewBankServiceClient(feeConn)
+		if err := s.checkFeeAccount(ctx2, feeClient, quote.sendCurrency); err != nil {
+			s.processedTxs.Delete(idempotencyKey)
+			return nil, err
+		}
+	}
+
+	// Phase 1: Prepare on sender, covering the payment and the fee.
 	senderPrep, err := senderClient.PreparePayment(ctx2, &paymentpb.PrepareRequest{
 		TransactionId: req.TransactionId,
 		Account:       req.SenderUsername,
-		Amount:        req.Amount,
+		Amount:        quote.sendAmount + quote.fee,
+		Currency:      quote.sendCurrency,
 	})
 	if err != nil || !senderPrep.Vote {
 		s.processedTxs.Store(idempotencyKey, false)
@@ -105,7 +347,9 @@ func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentp
 	receiverPrep, err := receiverClient.PreparePayment(ctx2, &paymentpb.PrepareRequest{
 		TransactionId: req.TransactionId,
 		Account:       req.ReceiverUsername,
-		Amount:        req.Amount,
+		Amount:        quote.receiveAmount,
+		Currency:      quote.receiveCurrency,
+		IsCredit:      true,
 	})
 	if err != nil || !receiverPrep.Vote {
 		s.processedTxs.Store(idempotencyKey, false)
@@ -114,14 +358,34 @@ func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentp
 		return nil, status.Errorf(codes.Aborted, "Receiver bank aborted the transaction")
 	}
 
+	// Phase 1: Prepare on the fee account.
+	if feeClient != nil {
+		feePrep, err := feeClient.PreparePayment(ctx2, &paymentpb.PrepareRequest{
+			TransactionId: req.TransactionId,
+			Account:       s.fees.FeeAccount,
+			Amount:        quote.fee,
+			Currency:      quote.sendCurrency,
+			IsCredit:      true,
+		})
+		if err != nil || !feePrep.Vote {
+			s.processedTxs.Store(idempotencyKey, false)
+			senderClient.AbortPayment(ctx2, &paymentpb.AbortRequest{TransactionId: req.TransactionId})
+			receiverClient.AbortPayment(ctx2, &paymentpb.AbortRequest{TransactionId: req.TransactionId})
+			return nil, status.Errorf(codes.Aborted, "Fee bank aborted the transaction")
+		}
+	}
+
 	// Phase 2: Commit on sender.
 	senderCommit, err := senderClient.CommitPayment(ctx2, &paymentpb.CommitRequest{
 		TransactionId: req.TransactionId,
 		Account:       req.SenderUsername,
-		Amount:        req.Amount,
+		Amount:        quote.sendAmount + quote.fee,
 		IsSender:      true,
 	})
-	if err != nil || !senderCommit.Success {
+	if err != nil {
+		return nil, s.paymentInDoubt(req, quote, risk, partialCommitError(req.TransactionId, nil, req.SenderUsername+"@"+req.SenderBank))
+	}
+	if !senderCommit.Success {
 		s.processedTxs.Store(idempotencyKey, false)
 		return nil, status.Errorf(codes.Aborted, "Sender bank commit failed")
 	}
@@ -130,24 +394,53 @@ func (s *PaymentGatewayServer) ProcessPayment(ctx context.Context, req *paymentp
 	receiverCommit, err := receiverClient.CommitPayment(ctx2, &paymentpb.CommitRequest{
 		TransactionId: req.TransactionId,
 		Account:       req.ReceiverUsername,
-		Amount:        req.Amount,
+		Amount:        quote.receiveAmount,
 		IsSender:      false,
 	})
 	if err != nil || !receiverCommit.Success {
-		s.processedTxs.Store(idempotencyKey, false)
-		return nil, status.Errorf(codes.Aborted, "Receiver bank commit failed")
+		return nil, s.paymentInDoubt(req, quote, risk, partialCommitError(req.TransactionId,
+			[]string{req.SenderUsername + "@" + req.SenderBank}, req.ReceiverUsername+"@"+req.ReceiverBank))
 	}
 
-	s.processedTxs.Store(idempotencyKey, true)
-	record := TransactionRecord{
-		TransactionId: req.TransactionId,
-		Sender:        req.SenderUsername,
-		Receiver:      req.ReceiverUsername,
-		Amount:        req.Amount,
-		Timestamp:     time.Now().Format(time.RFC3339),
-		Message:       "Transaction committed successfully",
+	// Phase 2: Commit on the fee account.
+	if feeClient != nil {
+		feeCommit, err := feeClient.CommitPayment(ctx2, &paymentpb.CommitRequest{
+			TransactionId: req.TransactionId,
+			Account:       s.fees.FeeAccount,
+			Amount:        quote.fee,
+			IsSender:      false,
+		})
+		if err != nil || !feeCommit.Success {
+			return nil, s.paymentInDoubt(req, quote, risk, partialCommitError(req.TransactionId,
+				[]string{req.SenderUsername + "@" + req.SenderBank, req.ReceiverUsername + "@" + req.ReceiverBank}, s.fees.FeeAccount+"@"+s.fees.FeeBank))
+		}
 	}
+
+	s.processedTxs.Store(idempotencyKey, true)
+	spend.commit()
+	record := newTransactionRecord(req, quote, risk)
 	s.storeTransactionRecord(record)
+	invoice.commit(req.TransactionId)
+	if req.SenderBank != req.ReceiverBank {
+		s.recordInterbankPosition(InterbankPosition{
+			TransactionId: req.TransactionId,
+			DebtorBank:    req.SenderBank,
+			CreditorBank:  req.ReceiverBank,
+			Amount:        quote.receiveAmount,
+			Currency:      quote.receiveCurrency,
+			Timestamp:     record.Timestamp,
+		})
+	}
+	if feeClient != nil && req.SenderBank != s.fees.FeeBank {
+		s.recordInterbankPosition(InterbankPosition{
+			TransactionId: req.TransactionId,
+			DebtorBank:    req.SenderBank,
+			CreditorBank:  s.fees.FeeBank,
+			Amount:        quote.fee,
+			Currency:      quote.sendCurrency,
+			Timestamp:     record.Timestamp,
+		})
+	}
 
-	return &paymentpb.TransactionResponse{Success: true, Message: "Transaction committed successfully"}, nil
+	return &paymentpb.TransactionResponse{Success: true, Message: "Transaction committed successfully", Fee: quote.fee}, nil
 }
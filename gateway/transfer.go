@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// transferWithinBank completes a payment between two accounts of the same bank with a
+// single Transfer call, which also credits any fee to the fee account held at that bank.
+// The caller has already claimed the idempotency key.
+func (s *PaymentGatewayServer) transferWithinBank(ctx context.Context, req *paymentpb.TransactionRequest, bank string, fee float64, risk *riskAssessment) (*paymentpb.TransactionResponse, error) {
+	idempotencyKey := req.IdempotencyKey
+
+	conn, err := grpc.Dial(bank, grpc.WithInsecure())
+	if err != nil {
+		s.processedTxs.Store(idempotencyKey, false)
+		return nil, status.Errorf(codes.FailedPrecondition, "Error connecting to bank: %v", err)
+	}
+	defer conn.Close()
+	bankClient := paymentpb.NewBankServiceClient(conn)
+
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	quote, err := s.quotePayment(ctx2, req, bankClient, bankClient)
+	if err != nil {
+		s.processedTxs.Delete(idempotencyKey)
+		return nil, err
+	}
+	quote.fee = fee
+	feeAccount := ""
+	if fee > 0 {
+		if err := s.checkFeeAccount(ctx2, bankClient, quote.sendCurrency); err != nil {
+			s.processedTxs.Delete(idempotencyKey)
+			return nil, err
+		}
+		feeAccount = s.fees.FeeAccount
+	}
+
+	resp, err := bankClient.Transfer(ctx2, &paymentpb.TransferRequest{
+		TransactionId:  req.TransactionId,
+		FromAccount:    req.SenderUsername,
+		ToAccount:      req.ReceiverUsername,
+		Amount:         quote.sendAmount,
+		ToAmount:       quote.receiveAmount,
+		FeeAccount:     feeAccount,
+		Fee:            fee,
+		IdempotencyKey: idempotencyKey,
+	})
+	if err != nil || !resp.Success {
+		s.processedTxs.Store(idempotencyKey, false)
+		return nil, status.Errorf(codes.Aborted, "Bank rejected the transfer")
+	}
+	log.Printf("Transaction %s completed as a same-bank transfer at %s", req.TransactionId, bank)
+
+	s.processedTxs.Store(idempotencyKey, true)
+	s.storeTransactionRecord(newTransactionRecord(req, quote, risk))
+
+	return &paymentpb.TransactionResponse{Success: true, Message: "Transaction committed successfully", Fee: fee}, nil
+}
@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// paymentLeg is one account movement within a two-phase commit.
+type paymentLeg struct {
+	bank     string
+	account  string
+	amount   float64
+	currency string
+	debit    bool
+}
+
+// mergeLegs combines the debits of each account into one leg, and likewise its credits.
+// Banks check the balance at prepare without reserving it, so two debits of one account
+// would each pass prepare and the second could then fail at commit.
+func mergeLegs(legs []paymentLeg) []paymentLeg {
+	type key struct {
+		bank, account string
+		debit         bool
+	}
+	index := make(map[key]int)
+	var merged []paymentLeg
+	for _, leg := range legs {
+		k := key{leg.bank, leg.account, leg.debit}
+		if i, ok := index[k]; ok {
+			merged[i].amount += leg.amount
+			continue
+		}
+		index[k] = len(merged)
+		merged = append(merged, leg)
+	}
+	return merged
+}
+
+// inDoubtStatus marks the history record of a transaction that was partially committed.
+const inDoubtStatus = "in_doubt"
+
+// partiallyCommitted reports whether err says a transaction may have committed at some
+// banks but not at others. Such a transaction must not be retried or undone by the
+// caller: its idempotency key stays claimed until an operator reconciles it.
+func partiallyCommitted(err error) bool {
+	return status.Code(err) == codes.DataLoss
+}
+
+// partialCommitError logs a transaction that failed during the commit phase after some of
+// its legs may have committed, and returns the error reporting it.
+func partialCommitError(transactionId string, committed []string, failed string) error {
+	log.Printf("IN DOUBT: transaction %s failed to commit %s after committing [%s]; reconcile it with the banks",
+		transactionId, failed, strings.Join(committed, ", "))
+	return status.Errorf(codes.DataLoss, "Transaction %s partially committed: %s failed after [%s] committed; it needs to be reconciled by an operator",
+		transactionId, failed, strings.Join(committed, ", "))
+}
+
+// executeTwoPhase prepares every leg and, once all banks have voted to commit, commits
+// the debits and then the credits, so a debit failing at commit stops the payment before
+// anyone is paid. Legs of the same account are merged first. If any prepare fails, the
+// banks prepared so far are asked to abort. A commit that fails once another leg has
+// committed, or whose outcome is unknown, returns a partially committed error.
+func executeTwoPhase(ctx context.Context, transactionId string, legs []paymentLeg) error {
+	legs = mergeLegs(legs)
+	sort.SliceStable(legs, func(i, j int) bool { return legs[i].debit && !legs[j].debit })
+	// Connect to each participating bank once (insecure for internal communication).
+	clients := make(map[string]paymentpb.BankServiceClient)
+	for _, leg := range legs {
+		if _, ok := clients[leg.bank]; ok {
+			continue
+		}
+		conn, err := grpc.Dial(leg.bank, grpc.WithInsecure())
+		if err != nil {
+			return status.Errorf(codes.FailedPrecondition, "Error connecting to bank %s: %v", leg.bank, err)
+		}
+		defer conn.Close()
+		clients[leg.bank] = paymentpb.NewBankServiceClient(conn)
+	}
+
+	ctx2, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Phase 1: Prepare every leg.
+	prepared := make(map[string]bool)
+	for _, leg := range legs {
+		prep, err := clients[leg.bank].PreparePayment(ctx2, &paymentpb.PrepareRequest{
+			TransactionId: transactionId,
+			Account:       leg.account,
+			Amount:        leg.amount,
+			Currency:      leg.currency,
+			IsCredit:      !leg.debit,
+		})
+		if err != nil || !prep.Vote {
+			for bank := range prepared {
+				clients[bank].AbortPayment(ctx2, &paymentpb.AbortRequest{TransactionId: transactionId})
+			}
+			return status.Errorf(codes.Aborted, "Bank %s aborted the transaction for account %s", leg.bank, leg.account)
+		}
+		prepared[leg.bank] = true
+	}
+
+	// Phase 2: Commit every leg.
+	var committed []string
+	for _, leg := range legs {
+		commit, err := clients[leg.bank].CommitPayment(ctx2, &paymentpb.CommitRequest{
+			TransactionId: transactionId,
+			Account:       leg.account,
+			Amount:        leg.amount,
+			IsSender:      leg.debit,
+		})
+		if err == nil && commit.Success {
+			committed = append(committed, leg.account+"@"+leg.bank)
+			continue
+		}
+		// A refused commit moved nothing at that bank, but a failed call may have.
+		if err != nil || len(committed) > 0 {
+			return partialCommitError(transactionId, committed, leg.account+"@"+leg.bank)
+		}
+		for bank := range prepared {
+			clients[bank].AbortPayment(ctx2, &paymentpb.AbortRequest{TransactionId: transactionId})
+		}
+		return status.Errorf(codes.Aborted, "Bank %s commit failed for account %s", leg.bank, leg.account)
+	}
+	return nil
+}
@@ -0,0 +1,539 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Webhook event types.
+const (
+	eventPaymentCommitted = "payment.committed"
+	eventPaymentAborted   = "payment.aborted"
+	eventPaymentRefunded  = "payment.refunded"
+)
+
+// Delivery states. Delivered events are dropped from the outbox; dead ones stay in it as
+// the dead-letter list until they are redelivered.
+const (
+	deliveryPending = "pending"
+	deliveryDead    = "dead"
+)
+
+const (
+	webhookMaxAttempts = 8
+	webhookBaseDelay   = 5 * time.Second
+	webhookMaxDelay    = time.Hour
+	webhookTimeout     = 10 * time.Second
+)
+
+// WebhookEndpoint is a URL that receives a user's payment events. The secret signs every
+// payload and is stored as is, since the gateway needs it to sign.
+type WebhookEndpoint struct {
+	EndpointId string   `json:"endpointId"`
+	Owner      string   `json:"owner"`
+	Url        string   `json:"url"`
+	Events     []string `json:"events,omitempty"`
+	Secret     string   `json:"secret"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+func (e *WebhookEndpoint) toProto() *paymentpb.WebhookEndpoint {
+	return &paymentpb.WebhookEndpoint{EndpointId: e.EndpointId, Owner: e.Owner, Url: e.Url, Events: e.Events, CreatedAt: e.CreatedAt}
+}
+
+// wants reports whether the endpoint is subscribed to the event type.
+func (e *WebhookEndpoint) wants(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEvent is the JSON body posted to endpoints.
+type webhookEvent struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	CreatedAt string            `json:"createdAt"`
+	Data      TransactionRecord `json:"data"`
+}
+
+// WebhookDelivery is one event waiting to be delivered to one endpoint.
+type WebhookDelivery struct {
+	DeliveryId    string          `json:"deliveryId"`
+	EndpointId    string          `json:"endpointId"`
+	Owner         string          `json:"owner"`
+	EventId       string          `json:"eventId"`
+	Event         string          `json:"event"`
+	TransactionId string          `json:"transactionId"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt string          `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     string          `json:"createdAt"`
+}
+
+func (d *WebhookDelivery) toProto() *paymentpb.WebhookDelivery {
+	return &paymentpb.WebhookDelivery{
+		DeliveryId:    d.DeliveryId,
+		EndpointId:    d.EndpointId,
+		Event:         d.Event,
+		TransactionId: d.TransactionId,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError,
+		CreatedAt:     d.CreatedAt,
+	}
+}
+
+// webhookBackoff returns how long to wait after the given number of failed attempts.
+func webhookBackoff(attempts int32) time.Duration {
+	delay := webhookBaseDelay
+	for i := int32(1); i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxDelay {
+		delay = webhookMaxDelay
+	}
+	return delay
+}
+
+// signWebhook returns the signature of a payload sent at the given Unix time.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadWebhooks loads the webhook endpoints and the outbox. Missing files mean there are
+// none yet.
+func (s *PaymentGatewayServer) LoadWebhooks(endpointsFile, outboxFile string) error {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+
+	s.webhooksFile = endpointsFile
+	s.outboxFile = outboxFile
+	s.webhooks = make(map[string]*WebhookEndpoint)
+	s.outbox = nil
+	if data, err := ioutil.ReadFile(endpointsFile); err == nil {
+		var list []*WebhookEndpoint
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		for _, e := range list {
+			s.webhooks[e.EndpointId] = e
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if data, err := ioutil.ReadFile(outboxFile); err == nil {
+		if err := json.Unmarshal(data, &s.outbox); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// saveWebhooks writes the endpoints to disk. The caller must hold webhookMu.
+func (s *PaymentGatewayServer) saveWebhooks() {
+	list := make([]*WebhookEndpoint, 0, len(s.webhooks))
+	for _, e := range s.webhooks {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling webhooks: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.webhooksFile, data, 0600); err != nil {
+		log.Printf("Error writing webhooks: %v", err)
+	}
+}
+
+// saveOutbox writes the outbox to disk. The caller must hold webhookMu.
+func (s *PaymentGatewayServer) saveOutbox() {
+	data, err := json.MarshalIndent(s.outbox, "", "  ")
+	if err != nil {
+		log.Printf("Error marshalling webhook outbox: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(s.outboxFile, data, 0644); err != nil {
+		log.Printf("Error writing webhook outbox: %v", err)
+	}
+}
+
+// enqueueWebhookEvent writes an event for every endpoint of the sender or receiver that
+// is subscribed to it into the outbox, which is saved before this returns. Risk screening
+// is for operators only, so it is left out of the payload.
+func (s *PaymentGatewayServer) enqueueWebhookEvent(event string, rec TransactionRecord) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	if s.webhooks == nil {
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	payload := webhookEvent{Id: uuid.New().String(), Type: event, CreatedAt: now, Data: rec.withoutRisk()}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling webhook event: %v", err)
+		return
+	}
+	queued := false
+	for _, e := range s.webhooks {
+		if (e.Owner != rec.Sender && e.Owner != rec.Receiver) || !e.wants(event) {
+			continue
+		}
+		s.outbox = append(s.outbox, &WebhookDelivery{
+			DeliveryId:    uuid.New().String(),
+			EndpointId:    e.EndpointId,
+			Owner:         e.Owner,
+			EventId:       payload.Id,
+			Event:         event,
+			TransactionId: rec.TransactionId,
+			Payload:       body,
+			Status:        deliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		queued = true
+	}
+	if queued {
+		s.saveOutbox()
+	}
+}
+
+// withoutRisk returns the record without the outcome of risk screening, which only
+// operators may see.
+func (rec TransactionRecord) withoutRisk() TransactionRecord {
+	rec.RiskDecision = ""
+	rec.RiskScore = 0
+	rec.RiskRules = nil
+	rec.ReviewId = ""
+	return rec
+}
+
+// enqueuePaymentEvent queues the committed or refunded event of a payment record.
+func (s *PaymentGatewayServer) enqueuePaymentEvent(rec TransactionRecord) {
+	if rec.RefundOf != "" {
+		s.enqueueWebhookEvent(eventPaymentRefunded, rec)
+	} else {
+		s.enqueueWebhookEvent(eventPaymentCommitted, rec)
+	}
+}
+
+// AllowPrivateWebhookHosts lets endpoints use loopback, private and link-local
+// addresses, which are rejected by default so webhooks cannot reach the gateway's own
+// network.
+func (s *PaymentGatewayServer) AllowPrivateWebhookHosts(allow bool) {
+	s.webhookPrivateHosts = allow
+}
+
+// privateAddress reports whether an address is loopback, private, link-local, multicast
+// or unspecified.
+func privateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// checkWebhookHost rejects a host that resolves to a private address.
+func (s *PaymentGatewayServer) checkWebhookHost(ctx context.Context, host string) error {
+	if s.webhookPrivateHosts {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if privateAddress(addr.IP) {
+			return fmt.Errorf("%s resolves to the private address %s", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// webhookClient returns the HTTP client deliveries are posted with. Unless private hosts
+// are allowed, it refuses to connect to a private address, so a host whose DNS changed
+// after registration, or a redirect, cannot reach the gateway's own network either.
+func (s *PaymentGatewayServer) webhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !s.webhookPrivateHosts {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || privateAddress(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{Timeout: webhookTimeout, Transport: &http.Transport{DialContext: dialer.DialContext}}
+}
+
+// StartWebhookDispatcher delivers due outbox entries at the given interval.
+func (s *PaymentGatewayServer) StartWebhookDispatcher(interval time.Duration) {
+	go func() {
+		client := s.webhookClient()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.deliverDueWebhooks(client, time.Now())
+		}
+	}()
+}
+
+// deliverDueWebhooks posts every pending delivery whose next attempt is due. Endpoints
+// are served concurrently, so a slow endpoint does not hold up the others, and each
+// endpoint gets its deliveries in outbox order. A delivery that succeeds is removed from
+// the outbox; one that fails is retried with exponential backoff and becomes dead after
+// webhookMaxAttempts attempts.
+func (s *PaymentGatewayServer) deliverDueWebhooks(client *http.Client, now time.Time) {
+	type job struct {
+		delivery *WebhookDelivery
+		endpoint WebhookEndpoint
+	}
+	s.webhookMu.Lock()
+	jobs := make(map[string][]job)
+	count := 0
+	changed := false
+	for _, d := range s.outbox {
+		next, _ := time.Parse(time.RFC3339, d.NextAttemptAt)
+		if d.Status != deliveryPending || next.After(now) {
+			continue
+		}
+		e, ok := s.webhooks[d.EndpointId]
+		if !ok {
+			d.Status = deliveryDead
+			d.LastError = "endpoint deleted"
+			changed = true
+			continue
+		}
+		jobs[e.EndpointId] = append(jobs[e.EndpointId], job{delivery: d, endpoint: *e})
+		count++
+	}
+	s.webhookMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, endpointJobs := range jobs {
+		wg.Add(1)
+		go func(endpointJobs []job) {
+			defer wg.Done()
+			for _, j := range endpointJobs {
+				err := postWebhook(client, j.endpoint, j.delivery)
+
+				s.webhookMu.Lock()
+				d := j.delivery
+				d.Attempts++
+				if err == nil {
+					for i, o := range s.outbox {
+						if o == d {
+							s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+							break
+						}
+					}
+				} else {
+					d.LastError = err.Error()
+					if d.Attempts >= webhookMaxAttempts {
+						d.Status = deliveryDead
+						log.Printf("Webhook delivery %s to %s is dead after %d attempts: %v", d.DeliveryId, j.endpoint.Url, d.Attempts, err)
+					} else {
+						d.NextAttemptAt = time.Now().Add(webhookBackoff(d.Attempts)).Format(time.RFC3339)
+					}
+				}
+				s.webhookMu.Unlock()
+			}
+		}(endpointJobs)
+	}
+	wg.Wait()
+
+	s.webhookMu.Lock()
+	if changed || count > 0 {
+		s.saveOutbox()
+	}
+	s.webhookMu.Unlock()
+}
+
+// postWebhook sends one delivery, signed with the endpoint's secret. Any 2xx response
+// counts as delivered.
+func postWebhook(client *http.Client, e WebhookEndpoint, d *WebhookDelivery) error {
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	req, err := http.NewRequest(http.MethodPost, e.Url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", d.EventId)
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", signWebhook(e.Secret, timestamp, d.Payload))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookOwner checks that the caller may manage the owner's webhooks.
+func webhookOwner(ctx context.Context, owner string) error {
+	if caller := authenticatedUser(ctx); caller != owner && !isOperator(caller) {
+		return status.Errorf(codes.PermissionDenied, "unauthorized: %s cannot manage webhooks of %s", caller, owner)
+	}
+	return nil
+}
+
+// RegisterWebhook adds an endpoint for the owner's payment events and returns its
+// signing secret, which is not shown again.
+func (s *PaymentGatewayServer) RegisterWebhook(ctx context.Context, req *paymentpb.RegisterWebhookRequest) (*paymentpb.WebhookResponse, error) {
+	if err := webhookOwner(ctx, req.Owner); err != nil {
+		return nil, err
+	}
+	if _, ok := s.users.Load(req.Owner); !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "User %s is not registered", req.Owner)
+	}
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid webhook URL %q", req.Url)
+	}
+	if err := s.checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Webhook URL %q not allowed: %v", req.Url, err)
+	}
+	for _, ev := range req.Events {
+		if ev != eventPaymentCommitted && ev != eventPaymentAborted && ev != eventPaymentRefunded {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown event %q", ev)
+		}
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error generating webhook secret: %v", err)
+	}
+	e := &WebhookEndpoint{
+		EndpointId: uuid.New().String(),
+		Owner:      req.Owner,
+		Url:        req.Url,
+		Events:     req.Events,
+		Secret:     "whsec_" + secret,
+		CreatedAt:  time.Now().Format(time.RFC3339),
+	}
+
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	s.webhooks[e.EndpointId] = e
+	s.saveWebhooks()
+	log.Printf("Webhook %s registered for %s: %s", e.EndpointId, e.Owner, e.Url)
+	return &paymentpb.WebhookResponse{Success: true, Message: "Webhook registered", Endpoint: e.toProto(), Secret: e.Secret}, nil
+}
+
+// ListWebhooks returns the owner's endpoints without their secrets.
+func (s *PaymentGatewayServer) ListWebhooks(ctx context.Context, req *paymentpb.ListWebhooksRequest) (*paymentpb.ListWebhooksResponse, error) {
+	if err := webhookOwner(ctx, req.Owner); err != nil {
+		return nil, err
+	}
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	var list []*paymentpb.WebhookEndpoint
+	for _, e := range s.webhooks {
+		if e.Owner == req.Owner {
+			list = append(list, e.toProto())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return &paymentpb.ListWebhooksResponse{Endpoints: list}, nil
+}
+
+// DeleteWebhook removes an endpoint. Its pending deliveries become dead.
+func (s *PaymentGatewayServer) DeleteWebhook(ctx context.Context, req *paymentpb.WebhookAction) (*paymentpb.WebhookResponse, error) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	e, ok := s.webhooks[req.EndpointId]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Webhook %s not found", req.EndpointId)
+	}
+	if err := webhookOwner(ctx, e.Owner); err != nil {
+		return nil, err
+	}
+	delete(s.webhooks, e.EndpointId)
+	s.saveWebhooks()
+	log.Printf("Webhook %s of %s deleted", e.EndpointId, e.Owner)
+	return &paymentpb.WebhookResponse{Success: true, Message: "Webhook deleted", Endpoint: e.toProto()}, nil
+}
+
+// ListWebhookDeliveries returns the owner's undelivered events, or only the dead ones.
+func (s *PaymentGatewayServer) ListWebhookDeliveries(ctx context.Context, req *paymentpb.ListWebhookDeliveriesRequest) (*paymentpb.ListWebhookDeliveriesResponse, error) {
+	if err := webhookOwner(ctx, req.Owner); err != nil {
+		return nil, err
+	}
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	var list []*paymentpb.WebhookDelivery
+	for _, d := range s.outbox {
+		if d.Owner != req.Owner || (req.DeadOnly && d.Status != deliveryDead) {
+			continue
+		}
+		list = append(list, d.toProto())
+	}
+	return &paymentpb.ListWebhookDeliveriesResponse{Deliveries: list}, nil
+}
+
+// RedeliverWebhook moves a dead delivery back to pending with a fresh set of attempts.
+func (s *PaymentGatewayServer) RedeliverWebhook(ctx context.Context, req *paymentpb.WebhookDeliveryAction) (*paymentpb.WebhookDeliveryResponse, error) {
+	s.webhookMu.Lock()
+	defer s.webhookMu.Unlock()
+	for _, d := range s.outbox {
+		if d.DeliveryId != req.DeliveryId {
+			continue
+		}
+		if err := webhookOwner(ctx, d.Owner); err != nil {
+			return nil, err
+		}
+		if d.Status != deliveryDead {
+			return nil, status.Errorf(codes.FailedPrecondition, "Delivery %s is %s", d.DeliveryId, d.Status)
+		}
+		if _, ok := s.webhooks[d.EndpointId]; !ok {
+			return nil, status.Errorf(codes.FailedPrecondition, "Webhook %s was deleted", d.EndpointId)
+		}
+		d.Status = deliveryPending
+		d.Attempts = 0
+		d.NextAttemptAt = time.Now().Format(time.RFC3339)
+		s.saveOutbox()
+		return &paymentpb.WebhookDeliveryResponse{Success: true, Message: "Delivery queued again", Delivery: d.toProto()}, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "Delivery %s not found", req.DeliveryId)
+}
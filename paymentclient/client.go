@@ -0,0 +1,220 @@
+// Package paymentclient is a Go client for the PaymentGateway service. It keeps one
+// connection, authenticates every call as a user or with a merchant API key, applies a
+// default timeout and retry policy, fills in missing idempotency keys and returns errors
+// as *Error values.
+//
+//	client, err := paymentclient.New("localhost:50051",
+//		paymentclient.WithTLSFiles("certs/alice.crt", "certs/alice.key", "certs/ca.crt"),
+//		paymentclient.WithCredentials("alice", "secretalice"))
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	balance, err := client.GetBalance(ctx, &paymentpb.BalanceRequest{Username: "alice"})
+package paymentclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// DefaultTimeout bounds a call whose context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
+// RetryPolicy decides which failed calls are tried again. Only codes that mean the
+// gateway never handled the call should be retried; payments are safe to retry because
+// they carry an idempotency key.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 1 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt; it doubles up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Codes are the gRPC codes that are retried.
+	Codes []codes.Code
+}
+
+// DefaultRetryPolicy retries calls that could not reach the gateway.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Codes:          []codes.Code{codes.Unavailable},
+}
+
+func (p RetryPolicy) retries(code codes.Code) bool {
+	for _, c := range p.Codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+type options struct {
+	creds                     credentials.TransportCredentials
+	certFile, keyFile, caFile string
+	username, password        string
+	apiKey                    string
+	timeout                   time.Duration
+	retry                     RetryPolicy
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+// WithTLSFiles sets the client certificate, its key and the CA certificate used for
+// mutual TLS with the gateway.
+func WithTLSFiles(certFile, keyFile, caFile string) Option {
+	return func(o *options) {
+		o.certFile, o.keyFile, o.caFile = certFile, keyFile, caFile
+	}
+}
+
+// WithTransportCredentials sets ready-made transport credentials instead of TLS files.
+func WithTransportCredentials(creds credentials.TransportCredentials) Option {
+	return func(o *options) { o.creds = creds }
+}
+
+// WithCredentials authenticates calls as a registered user.
+func WithCredentials(username, password string) Option {
+	return func(o *options) { o.username, o.password = username, password }
+}
+
+// WithAPIKey authenticates calls with a merchant API key instead of a user's password.
+func WithAPIKey(apiKey string) Option {
+	return func(o *options) { o.apiKey = apiKey }
+}
+
+// WithTimeout changes DefaultTimeout for this client.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithRetryPolicy changes DefaultRetryPolicy for this client.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) { o.retry = policy }
+}
+
+// Client calls the PaymentGateway service. It is safe for concurrent use.
+type Client struct {
+	conn    *grpc.ClientConn
+	gateway paymentpb.PaymentGatewayClient
+	opts    options
+	// shared is set on copies made by As, which must not close the connection.
+	shared bool
+}
+
+// New connects to the gateway at address. The connection is established lazily, so an
+// unreachable gateway shows up as an Unavailable error on the first call.
+func New(address string, opts ...Option) (*Client, error) {
+	o := options{timeout: DefaultTimeout, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	creds := o.creds
+	if creds == nil {
+		if o.certFile == "" {
+			return nil, fmt.Errorf("paymentclient: no TLS files or transport credentials given")
+		}
+		var err error
+		if creds, err = loadTLS(o.certFile, o.keyFile, o.caFile); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("paymentclient: cannot connect to %s: %w", address, err)
+	}
+	return &Client{conn: conn, gateway: paymentpb.NewPaymentGatewayClient(conn), opts: o}, nil
+}
+
+func loadTLS(certFile, keyFile, caFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("paymentclient: cannot load client certificate: %w", err)
+	}
+	caCert, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("paymentclient: cannot load CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("paymentclient: no certificate found in %s", caFile)
+	}
+	return credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool}), nil
+}
+
+// As returns a client that shares this client's connection and settings but
+// authenticates as another user. Closing it does not close the connection.
+func (c *Client) As(username, password string) *Client {
+	copy := *c
+	copy.opts.username, copy.opts.password, copy.opts.apiKey = username, password, ""
+	copy.shared = true
+	return &copy
+}
+
+// Username returns the user calls are authenticated as, if any.
+func (c *Client) Username() string {
+	return c.opts.username
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	if c.shared {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// outgoing adds the credentials to ctx.
+func (c *Client) outgoing(ctx context.Context) context.Context {
+	if c.opts.apiKey != "" {
+		return metadata.AppendToOutgoingContext(ctx, "api-key", c.opts.apiKey)
+	}
+	if c.opts.username != "" {
+		return metadata.AppendToOutgoingContext(ctx, "username", c.opts.username, "password", c.opts.password)
+	}
+	return ctx
+}
+
+// call runs a unary RPC with credentials, the default timeout and the retry policy.
+func call[Req, Resp any](ctx context.Context, c *Client, req Req, rpc func(context.Context, Req, ...grpc.CallOption) (Resp, error)) (Resp, error) {
+	if _, ok := ctx.Deadline(); !ok && c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+	ctx = c.outgoing(ctx)
+
+	backoff := c.opts.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		resp, err := rpc(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		e := fromError(err)
+		if attempt >= c.opts.retry.MaxAttempts || !c.opts.retry.retries(e.Code) {
+			return resp, e
+		}
+		select {
+		case <-ctx.Done():
+			return resp, e
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.opts.retry.MaxBackoff {
+			backoff = c.opts.retry.MaxBackoff
+		}
+	}
+}
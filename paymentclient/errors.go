@@ -0,0 +1,102 @@
+package paymentclient
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// Sentinel errors for the gRPC codes the gateway returns. Every *Error matches the one
+// for its code with errors.Is.
+var (
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrNotFound           = errors.New("not found")
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrUnauthenticated    = errors.New("unauthenticated")
+	ErrFailedPrecondition = errors.New("failed precondition")
+	ErrResourceExhausted  = errors.New("resource exhausted")
+	ErrAborted            = errors.New("aborted")
+	ErrOutOfRange         = errors.New("out of range")
+	ErrUnavailable        = errors.New("unavailable")
+	ErrDeadlineExceeded   = errors.New("deadline exceeded")
+	ErrCanceled           = errors.New("canceled")
+	ErrInternal           = errors.New("internal error")
+)
+
+var sentinels = map[codes.Code]error{
+	codes.InvalidArgument:    ErrInvalidArgument,
+	codes.NotFound:           ErrNotFound,
+	codes.AlreadyExists:      ErrAlreadyExists,
+	codes.PermissionDenied:   ErrPermissionDenied,
+	codes.Unauthenticated:    ErrUnauthenticated,
+	codes.FailedPrecondition: ErrFailedPrecondition,
+	codes.ResourceExhausted:  ErrResourceExhausted,
+	codes.Aborted:            ErrAborted,
+	codes.OutOfRange:         ErrOutOfRange,
+	codes.Unavailable:        ErrUnavailable,
+	codes.DeadlineExceeded:   ErrDeadlineExceeded,
+	codes.Canceled:           ErrCanceled,
+	codes.Internal:           ErrInternal,
+}
+
+// Error is a failed gateway call. It keeps the gRPC status, so status.Code and
+// status.Convert work on it as on the original error.
+type Error struct {
+	Code    codes.Code
+	Message string
+	status  *status.Status
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is matches the sentinel error for the code.
+func (e *Error) Is(target error) bool {
+	return sentinels[e.Code] == target
+}
+
+// GRPCStatus returns the status the gateway sent.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// LimitExceeded returns the details of a spending limit violation, or nil.
+func (e *Error) LimitExceeded() *paymentpb.LimitExceeded {
+	for _, d := range e.status.Details() {
+		if detail, ok := d.(*paymentpb.LimitExceeded); ok {
+			return detail
+		}
+	}
+	return nil
+}
+
+// Retryable reports whether the call did not reach the gateway or timed out, so it may
+// be sent again with the same idempotency key.
+func (e *Error) Retryable() bool {
+	return e.Code == codes.Unavailable || e.Code == codes.DeadlineExceeded
+}
+
+// fromError converts an error from a gRPC call.
+func fromError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	s := status.Convert(err)
+	return &Error{Code: s.Code(), Message: s.Message(), status: s}
+}
+
+// AsError returns err as an *Error. Errors that did not come from the gateway are
+// reported with code Unknown.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	return fromError(err)
+}
@@ -0,0 +1,290 @@
+package paymentclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+
+	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
+)
+
+// One method per PaymentGateway RPC, with the same request and response types. Methods
+// with extra behaviour are documented; the others only add credentials, the timeout and
+// retries.
+
+// fillPayment gives a payment a transaction id and idempotency key when it has none.
+func fillPayment(req *paymentpb.TransactionRequest) {
+	fillKey(&req.TransactionId)
+	fillKey(&req.IdempotencyKey)
+}
+
+// fillKey sets a missing idempotency key or id to a new uuid. The methods that take one call it before the
+// first attempt, so every retry of the request carries the same key.
+func fillKey(key *string) {
+	if *key == "" {
+		*key = uuid.New().String()
+	}
+}
+
+// ProcessPayment executes a payment. A missing transaction id or idempotency key is
+// generated and set on req, so a retry of the same req cannot pay twice.
+func (c *Client) ProcessPayment(ctx context.Context, req *paymentpb.TransactionRequest) (*paymentpb.TransactionResponse, error) {
+	fillPayment(req)
+	return call(ctx, c, req, c.gateway.ProcessPayment)
+}
+
+// SubmitPayment hands a payment to the gateway's worker pool. Like ProcessPayment, it
+// fills in a missing transaction id and idempotency key.
+func (c *Client) SubmitPayment(ctx context.Context, req *paymentpb.TransactionRequest) (*paymentpb.PaymentStatus, error) {
+	fillPayment(req)
+	return call(ctx, c, req, c.gateway.SubmitPayment)
+}
+
+// WaitForPayment waits for an asynchronous payment. Without a deadline on ctx it allows
+// the requested wait plus the client timeout.
+func (c *Client) WaitForPayment(ctx context.Context, req *paymentpb.WaitForPaymentRequest) (*paymentpb.PaymentStatus, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutSeconds)*time.Second+c.opts.timeout)
+		defer cancel()
+	}
+	return call(ctx, c, req, c.gateway.WaitForPayment)
+}
+
+// SubmitPaymentBatch submits a batch of payments. A missing batch id and missing item
+// idempotency keys are generated and set on req, so a retry does not submit it twice.
+func (c *Client) SubmitPaymentBatch(ctx context.Context, req *paymentpb.PaymentBatchRequest) (*paymentpb.PaymentBatchResponse, error) {
+	fillKey(&req.BatchId)
+	for _, item := range req.Items {
+		fillKey(&item.IdempotencyKey)
+	}
+	return call(ctx, c, req, c.gateway.SubmitPaymentBatch)
+}
+
+// WatchPaymentBatch streams a batch's status until it completes. The stream is not
+// bounded by the client timeout; cancel ctx to stop it.
+func (c *Client) WatchPaymentBatch(ctx context.Context, req *paymentpb.PaymentBatchStatusRequest) (grpc.ServerStreamingClient[paymentpb.PaymentBatchStatus], error) {
+	stream, err := c.gateway.WatchPaymentBatch(c.outgoing(ctx), req)
+	if err != nil {
+		return nil, fromError(err)
+	}
+	return stream, nil
+}
+
+// SubscribeEvents streams payment and balance events. The stream is not bounded by the
+// client timeout; cancel ctx to stop it.
+func (c *Client) SubscribeEvents(ctx context.Context, req *paymentpb.SubscribeEventsRequest) (grpc.ServerStreamingClient[paymentpb.GatewayEvent], error) {
+	stream, err := c.gateway.SubscribeEvents(c.outgoing(ctx), req)
+	if err != nil {
+		return nil, fromError(err)
+	}
+	return stream, nil
+}
+
+func (c *Client) Register(ctx context.Context, req *paymentpb.RegisterRequest) (*paymentpb.RegisterResponse, error) {
+	return call(ctx, c, req, c.gateway.Register)
+}
+
+func (c *Client) GetBalance(ctx context.Context, req *paymentpb.BalanceRequest) (*paymentpb.BalanceResponse, error) {
+	return call(ctx, c, req, c.gateway.GetBalance)
+}
+
+func (c *Client) GetTransactionHistory(ctx context.Context, req *paymentpb.HistoryRequest) (*paymentpb.HistoryResponse, error) {
+	return call(ctx, c, req, c.gateway.GetTransactionHistory)
+}
+
+func (c *Client) Unregister(ctx context.Context, req *paymentpb.UnregisterRequest) (*paymentpb.UnregisterResponse, error) {
+	return call(ctx, c, req, c.gateway.Unregister)
+}
+
+func (c *Client) GetSettlementReport(ctx context.Context, req *paymentpb.SettlementReportRequest) (*paymentpb.SettlementReportResponse, error) {
+	return call(ctx, c, req, c.gateway.GetSettlementReport)
+}
+
+func (c *Client) SettlePositions(ctx context.Context, req *paymentpb.SettlePositionsRequest) (*paymentpb.SettlePositionsResponse, error) {
+	return call(ctx, c, req, c.gateway.SettlePositions)
+}
+
+func (c *Client) ReloadFXRates(ctx context.Context, req *paymentpb.ReloadFXRatesRequest) (*paymentpb.ReloadFXRatesResponse, error) {
+	return call(ctx, c, req, c.gateway.ReloadFXRates)
+}
+
+// RefundPayment refunds a payment. A missing idempotency key is generated and set on req.
+func (c *Client) RefundPayment(ctx context.Context, req *paymentpb.RefundRequest) (*paymentpb.RefundResponse, error) {
+	fillKey(&req.IdempotencyKey)
+	return call(ctx, c, req, c.gateway.RefundPayment)
+}
+
+func (c *Client) SchedulePayment(ctx context.Context, req *paymentpb.SchedulePaymentRequest) (*paymentpb.SchedulePaymentResponse, error) {
+	return call(ctx, c, req, c.gateway.SchedulePayment)
+}
+
+func (c *Client) ListSchedules(ctx context.Context, req *paymentpb.ListSchedulesRequest) (*paymentpb.ListSchedulesResponse, error) {
+	return call(ctx, c, req, c.gateway.ListSchedules)
+}
+
+func (c *Client) PauseSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return call(ctx, c, req, c.gateway.PauseSchedule)
+}
+
+func (c *Client) ResumeSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return call(ctx, c, req, c.gateway.ResumeSchedule)
+}
+
+func (c *Client) CancelSchedule(ctx context.Context, req *paymentpb.ScheduleActionRequest) (*paymentpb.ScheduleActionResponse, error) {
+	return call(ctx, c, req, c.gateway.CancelSchedule)
+}
+
+func (c *Client) RequestPayment(ctx context.Context, req *paymentpb.RequestPaymentRequest) (*paymentpb.RequestPaymentResponse, error) {
+	return call(ctx, c, req, c.gateway.RequestPayment)
+}
+
+func (c *Client) ListPaymentRequests(ctx context.Context, req *paymentpb.ListPaymentRequestsRequest) (*paymentpb.ListPaymentRequestsResponse, error) {
+	return call(ctx, c, req, c.gateway.ListPaymentRequests)
+}
+
+// ApprovePaymentRequest pays a payment request. A missing idempotency key is generated
+// and set on req.
+func (c *Client) ApprovePaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	fillKey(&req.IdempotencyKey)
+	return call(ctx, c, req, c.gateway.ApprovePaymentRequest)
+}
+
+func (c *Client) DeclinePaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	return call(ctx, c, req, c.gateway.DeclinePaymentRequest)
+}
+
+func (c *Client) CancelPaymentRequest(ctx context.Context, req *paymentpb.PaymentRequestAction) (*paymentpb.PaymentRequestActionResponse, error) {
+	return call(ctx, c, req, c.gateway.CancelPaymentRequest)
+}
+
+// CreateEscrow funds an escrow. A missing idempotency key is generated and set on req.
+func (c *Client) CreateEscrow(ctx context.Context, req *paymentpb.CreateEscrowRequest) (*paymentpb.EscrowResponse, error) {
+	fillKey(&req.IdempotencyKey)
+	return call(ctx, c, req, c.gateway.CreateEscrow)
+}
+
+func (c *Client) ReleaseEscrow(ctx context.Context, req *paymentpb.EscrowAction) (*paymentpb.EscrowResponse, error) {
+	return call(ctx, c, req, c.gateway.ReleaseEscrow)
+}
+
+func (c *Client) RefundEscrow(ctx context.Context, req *paymentpb.EscrowAction) (*paymentpb.EscrowResponse, error) {
+	return call(ctx, c, req, c.gateway.RefundEscrow)
+}
+
+func (c *Client) ListEscrows(ctx context.Context, req *paymentpb.ListEscrowsRequest) (*paymentpb.ListEscrowsResponse, error) {
+	return call(ctx, c, req, c.gateway.ListEscrows)
+}
+
+// ProcessMultiPayment executes a multi-party payment. A missing idempotency key is
+// generated and set on req.
+func (c *Client) ProcessMultiPayment(ctx context.Context, req *paymentpb.MultiPaymentRequest) (*paymentpb.MultiPaymentResponse, error) {
+	fillKey(&req.IdempotencyKey)
+	return call(ctx, c, req, c.gateway.ProcessMultiPayment)
+}
+
+func (c *Client) GetPaymentBatch(ctx context.Context, req *paymentpb.PaymentBatchStatusRequest) (*paymentpb.PaymentBatchStatus, error) {
+	return call(ctx, c, req, c.gateway.GetPaymentBatch)
+}
+
+func (c *Client) SetSpendingLimit(ctx context.Context, req *paymentpb.SetSpendingLimitRequest) (*paymentpb.SpendingLimitResponse, error) {
+	return call(ctx, c, req, c.gateway.SetSpendingLimit)
+}
+
+func (c *Client) SetUserRole(ctx context.Context, req *paymentpb.SetUserRoleRequest) (*paymentpb.SpendingLimitResponse, error) {
+	return call(ctx, c, req, c.gateway.SetUserRole)
+}
+
+func (c *Client) GetSpendingHeadroom(ctx context.Context, req *paymentpb.HeadroomRequest) (*paymentpb.HeadroomResponse, error) {
+	return call(ctx, c, req, c.gateway.GetSpendingHeadroom)
+}
+
+func (c *Client) ListReviews(ctx context.Context, req *paymentpb.ListReviewsRequest) (*paymentpb.ListReviewsResponse, error) {
+	return call(ctx, c, req, c.gateway.ListReviews)
+}
+
+func (c *Client) ApproveReview(ctx context.Context, req *paymentpb.ReviewDecision) (*paymentpb.ReviewDecisionResponse, error) {
+	return call(ctx, c, req, c.gateway.ApproveReview)
+}
+
+func (c *Client) RejectReview(ctx context.Context, req *paymentpb.ReviewDecision) (*paymentpb.ReviewDecisionResponse, error) {
+	return call(ctx, c, req, c.gateway.RejectReview)
+}
+
+func (c *Client) OpenDispute(ctx context.Context, req *paymentpb.OpenDisputeRequest) (*paymentpb.DisputeResponse, error) {
+	return call(ctx, c, req, c.gateway.OpenDispute)
+}
+
+func (c *Client) InvestigateDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	return call(ctx, c, req, c.gateway.InvestigateDispute)
+}
+
+func (c *Client) AcceptDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	return call(ctx, c, req, c.gateway.AcceptDispute)
+}
+
+func (c *Client) RejectDispute(ctx context.Context, req *paymentpb.DisputeAction) (*paymentpb.DisputeResponse, error) {
+	return call(ctx, c, req, c.gateway.RejectDispute)
+}
+
+func (c *Client) ListDisputes(ctx context.Context, req *paymentpb.ListDisputesRequest) (*paymentpb.ListDisputesResponse, error) {
+	return call(ctx, c, req, c.gateway.ListDisputes)
+}
+
+func (c *Client) CreateApiKey(ctx context.Context, req *paymentpb.CreateApiKeyRequest) (*paymentpb.ApiKeyResponse, error) {
+	return call(ctx, c, req, c.gateway.CreateApiKey)
+}
+
+func (c *Client) ListApiKeys(ctx context.Context, req *paymentpb.ListApiKeysRequest) (*paymentpb.ListApiKeysResponse, error) {
+	return call(ctx, c, req, c.gateway.ListApiKeys)
+}
+
+func (c *Client) RotateApiKey(ctx context.Context, req *paymentpb.ApiKeyAction) (*paymentpb.ApiKeyResponse, error) {
+	return call(ctx, c, req, c.gateway.RotateApiKey)
+}
+
+func (c *Client) RevokeApiKey(ctx context.Context, req *paymentpb.ApiKeyAction) (*paymentpb.ApiKeyResponse, error) {
+	return call(ctx, c, req, c.gateway.RevokeApiKey)
+}
+
+func (c *Client) CreateInvoice(ctx context.Context, req *paymentpb.CreateInvoiceRequest) (*paymentpb.InvoiceResponse, error) {
+	return call(ctx, c, req, c.gateway.CreateInvoice)
+}
+
+func (c *Client) GetInvoice(ctx context.Context, req *paymentpb.GetInvoiceRequest) (*paymentpb.InvoiceResponse, error) {
+	return call(ctx, c, req, c.gateway.GetInvoice)
+}
+
+func (c *Client) ListInvoices(ctx context.Context, req *paymentpb.ListInvoicesRequest) (*paymentpb.ListInvoicesResponse, error) {
+	return call(ctx, c, req, c.gateway.ListInvoices)
+}
+
+func (c *Client) RegisterWebhook(ctx context.Context, req *paymentpb.RegisterWebhookRequest) (*paymentpb.WebhookResponse, error) {
+	return call(ctx, c, req, c.gateway.RegisterWebhook)
+}
+
+func (c *Client) ListWebhooks(ctx context.Context, req *paymentpb.ListWebhooksRequest) (*paymentpb.ListWebhooksResponse, error) {
+	return call(ctx, c, req, c.gateway.ListWebhooks)
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, req *paymentpb.WebhookAction) (*paymentpb.WebhookResponse, error) {
+	return call(ctx, c, req, c.gateway.DeleteWebhook)
+}
+
+func (c *Client) ListWebhookDeliveries(ctx context.Context, req *paymentpb.ListWebhookDeliveriesRequest) (*paymentpb.ListWebhookDeliveriesResponse, error) {
+	return call(ctx, c, req, c.gateway.ListWebhookDeliveries)
+}
+
+func (c *Client) RedeliverWebhook(ctx context.Context, req *paymentpb.WebhookDeliveryAction) (*paymentpb.WebhookDeliveryResponse, error) {
+	return call(ctx, c, req, c.gateway.RedeliverWebhook)
+}
+
+func (c *Client) GetPaymentStatus(ctx context.Context, req *paymentpb.PaymentStatusRequest) (*paymentpb.PaymentStatus, error) {
+	return call(ctx, c, req, c.gateway.GetPaymentStatus)
+}
+
+func (c *Client) ListQueuedPayments(ctx context.Context, req *paymentpb.ListQueuedPaymentsRequest) (*paymentpb.ListQueuedPaymentsResponse, error) {
+	return call(ctx, c, req, c.gateway.ListQueuedPayments)
+}
@@ -24,6 +24,56 @@ const (
 	PaymentGateway_GetBalance_FullMethodName            = "/payment.PaymentGateway/GetBalance"
 	PaymentGateway_GetTransactionHistory_FullMethodName = "/payment.PaymentGateway/GetTransactionHistory"
 	PaymentGateway_Unregister_FullMethodName            = "/payment.PaymentGateway/Unregister"
+	PaymentGateway_GetSettlementReport_FullMethodName   = "/payment.PaymentGateway/GetSettlementReport"
+	PaymentGateway_SettlePositions_FullMethodName       = "/payment.PaymentGateway/SettlePositions"
+	PaymentGateway_ReloadFXRates_FullMethodName         = "/payment.PaymentGateway/ReloadFXRates"
+	PaymentGateway_RefundPayment_FullMethodName         = "/payment.PaymentGateway/RefundPayment"
+	PaymentGateway_SchedulePayment_FullMethodName       = "/payment.PaymentGateway/SchedulePayment"
+	PaymentGateway_ListSchedules_FullMethodName         = "/payment.PaymentGateway/ListSchedules"
+	PaymentGateway_PauseSchedule_FullMethodName         = "/payment.PaymentGateway/PauseSchedule"
+	PaymentGateway_ResumeSchedule_FullMethodName        = "/payment.PaymentGateway/ResumeSchedule"
+	PaymentGateway_CancelSchedule_FullMethodName        = "/payment.PaymentGateway/CancelSchedule"
+	PaymentGateway_RequestPayment_FullMethodName        = "/payment.PaymentGateway/RequestPayment"
+	PaymentGateway_ListPaymentRequests_FullMethodName   = "/payment.PaymentGateway/ListPaymentRequests"
+	PaymentGateway_ApprovePaymentRequest_FullMethodName = "/payment.PaymentGateway/ApprovePaymentRequest"
+	PaymentGateway_DeclinePaymentRequest_FullMethodName = "/payment.PaymentGateway/DeclinePaymentRequest"
+	PaymentGateway_CancelPaymentRequest_FullMethodName  = "/payment.PaymentGateway/CancelPaymentRequest"
+	PaymentGateway_CreateEscrow_FullMethodName          = "/payment.PaymentGateway/CreateEscrow"
+	PaymentGateway_ReleaseEscrow_FullMethodName         = "/payment.PaymentGateway/ReleaseEscrow"
+	PaymentGateway_RefundEscrow_FullMethodName          = "/payment.PaymentGateway/RefundEscrow"
+	PaymentGateway_ListEscrows_FullMethodName           = "/payment.PaymentGateway/ListEscrows"
+	PaymentGateway_ProcessMultiPayment_FullMethodName   = "/payment.PaymentGateway/ProcessMultiPayment"
+	PaymentGateway_SubmitPaymentBatch_FullMethodName    = "/payment.PaymentGateway/SubmitPaymentBatch"
+	PaymentGateway_GetPaymentBatch_FullMethodName       = "/payment.PaymentGateway/GetPaymentBatch"
+	PaymentGateway_WatchPaymentBatch_FullMethodName     = "/payment.PaymentGateway/WatchPaymentBatch"
+	PaymentGateway_SetSpendingLimit_FullMethodName      = "/payment.PaymentGateway/SetSpendingLimit"
+	PaymentGateway_SetUserRole_FullMethodName           = "/payment.PaymentGateway/SetUserRole"
+	PaymentGateway_GetSpendingHeadroom_FullMethodName   = "/payment.PaymentGateway/GetSpendingHeadroom"
+	PaymentGateway_ListReviews_FullMethodName           = "/payment.PaymentGateway/ListReviews"
+	PaymentGateway_ApproveReview_FullMethodName         = "/payment.PaymentGateway/ApproveReview"
+	PaymentGateway_RejectReview_FullMethodName          = "/payment.PaymentGateway/RejectReview"
+	PaymentGateway_OpenDispute_FullMethodName           = "/payment.PaymentGateway/OpenDispute"
+	PaymentGateway_InvestigateDispute_FullMethodName    = "/payment.PaymentGateway/InvestigateDispute"
+	PaymentGateway_AcceptDispute_FullMethodName         = "/payment.PaymentGateway/AcceptDispute"
+	PaymentGateway_RejectDispute_FullMethodName         = "/payment.PaymentGateway/RejectDispute"
+	PaymentGateway_ListDisputes_FullMethodName          = "/payment.PaymentGateway/ListDisputes"
+	PaymentGateway_CreateApiKey_FullMethodName          = "/payment.PaymentGateway/CreateApiKey"
+	PaymentGateway_ListApiKeys_FullMethodName           = "/payment.PaymentGateway/ListApiKeys"
+	PaymentGateway_RotateApiKey_FullMethodName          = "/payment.PaymentGateway/RotateApiKey"
+	PaymentGateway_RevokeApiKey_FullMethodName          = "/payment.PaymentGateway/RevokeApiKey"
+	PaymentGateway_CreateInvoice_FullMethodName         = "/payment.PaymentGateway/CreateInvoice"
+	PaymentGateway_GetInvoice_FullMethodName            = "/payment.PaymentGateway/GetInvoice"
+	PaymentGateway_ListInvoices_FullMethodName          = "/payment.PaymentGateway/ListInvoices"
+	PaymentGateway_RegisterWebhook_FullMethodName       = "/payment.PaymentGateway/RegisterWebhook"
+	PaymentGateway_ListWebhooks_FullMethodName          = "/payment.PaymentGateway/ListWebhooks"
+	PaymentGateway_DeleteWebhook_FullMethodName         = "/payment.PaymentGateway/DeleteWebhook"
+	PaymentGateway_ListWebhookDeliveries_FullMethodName = "/payment.PaymentGateway/ListWebhookDeliveries"
+	PaymentGateway_RedeliverWebhook_FullMethodName      = "/payment.PaymentGateway/RedeliverWebhook"
+	PaymentGateway_SubscribeEvents_FullMethodName       = "/payment.PaymentGateway/SubscribeEvents"
+	PaymentGateway_SubmitPayment_FullMethodName         = "/payment.PaymentGateway/SubmitPayment"
+	PaymentGateway_GetPaymentStatus_FullMethodName      = "/payment.PaymentGateway/GetPaymentStatus"
+	PaymentGateway_WaitForPayment_FullMethodName        = "/payment.PaymentGateway/WaitForPayment"
+	PaymentGateway_ListQueuedPayments_FullMethodName    = "/payment.PaymentGateway/ListQueuedPayments"
 )
 
 // PaymentGatewayClient is the client API for PaymentGateway service.
@@ -37,6 +87,56 @@ type PaymentGatewayClient interface {
 	GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
 	GetTransactionHistory(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
 	Unregister(ctx context.Context, in *UnregisterRequest, opts ...grpc.CallOption) (*UnregisterResponse, error)
+	GetSettlementReport(ctx context.Context, in *SettlementReportRequest, opts ...grpc.CallOption) (*SettlementReportResponse, error)
+	SettlePositions(ctx context.Context, in *SettlePositionsRequest, opts ...grpc.CallOption) (*SettlePositionsResponse, error)
+	ReloadFXRates(ctx context.Context, in *ReloadFXRatesRequest, opts ...grpc.CallOption) (*ReloadFXRatesResponse, error)
+	RefundPayment(ctx context.Context, in *RefundRequest, opts ...grpc.CallOption) (*RefundResponse, error)
+	SchedulePayment(ctx context.Context, in *SchedulePaymentRequest, opts ...grpc.CallOption) (*SchedulePaymentResponse, error)
+	ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error)
+	PauseSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error)
+	ResumeSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error)
+	CancelSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error)
+	RequestPayment(ctx context.Context, in *RequestPaymentRequest, opts ...grpc.CallOption) (*RequestPaymentResponse, error)
+	ListPaymentRequests(ctx context.Context, in *ListPaymentRequestsRequest, opts ...grpc.CallOption) (*ListPaymentRequestsResponse, error)
+	ApprovePaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error)
+	DeclinePaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error)
+	CancelPaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error)
+	CreateEscrow(ctx context.Context, in *CreateEscrowRequest, opts ...grpc.CallOption) (*EscrowResponse, error)
+	ReleaseEscrow(ctx context.Context, in *EscrowAction, opts ...grpc.CallOption) (*EscrowResponse, error)
+	RefundEscrow(ctx context.Context, in *EscrowAction, opts ...grpc.CallOption) (*EscrowResponse, error)
+	ListEscrows(ctx context.Context, in *ListEscrowsRequest, opts ...grpc.CallOption) (*ListEscrowsResponse, error)
+	ProcessMultiPayment(ctx context.Context, in *MultiPaymentRequest, opts ...grpc.CallOption) (*MultiPaymentResponse, error)
+	SubmitPaymentBatch(ctx context.Context, in *PaymentBatchRequest, opts ...grpc.CallOption) (*PaymentBatchResponse, error)
+	GetPaymentBatch(ctx context.Context, in *PaymentBatchStatusRequest, opts ...grpc.CallOption) (*PaymentBatchStatus, error)
+	WatchPaymentBatch(ctx context.Context, in *PaymentBatchStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PaymentBatchStatus], error)
+	SetSpendingLimit(ctx context.Context, in *SetSpendingLimitRequest, opts ...grpc.CallOption) (*SpendingLimitResponse, error)
+	SetUserRole(ctx context.Context, in *SetUserRoleRequest, opts ...grpc.CallOption) (*SpendingLimitResponse, error)
+	GetSpendingHeadroom(ctx context.Context, in *HeadroomRequest, opts ...grpc.CallOption) (*HeadroomResponse, error)
+	ListReviews(ctx context.Context, in *ListReviewsRequest, opts ...grpc.CallOption) (*ListReviewsResponse, error)
+	ApproveReview(ctx context.Context, in *ReviewDecision, opts ...grpc.CallOption) (*ReviewDecisionResponse, error)
+	RejectReview(ctx context.Context, in *ReviewDecision, opts ...grpc.CallOption) (*ReviewDecisionResponse, error)
+	OpenDispute(ctx context.Context, in *OpenDisputeRequest, opts ...grpc.CallOption) (*DisputeResponse, error)
+	InvestigateDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error)
+	AcceptDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error)
+	RejectDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error)
+	ListDisputes(ctx context.Context, in *ListDisputesRequest, opts ...grpc.CallOption) (*ListDisputesResponse, error)
+	CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*ApiKeyResponse, error)
+	ListApiKeys(ctx context.Context, in *ListApiKeysRequest, opts ...grpc.CallOption) (*ListApiKeysResponse, error)
+	RotateApiKey(ctx context.Context, in *ApiKeyAction, opts ...grpc.CallOption) (*ApiKeyResponse, error)
+	RevokeApiKey(ctx context.Context, in *ApiKeyAction, opts ...grpc.CallOption) (*ApiKeyResponse, error)
+	CreateInvoice(ctx context.Context, in *CreateInvoiceRequest, opts ...grpc.CallOption) (*InvoiceResponse, error)
+	GetInvoice(ctx context.Context, in *GetInvoiceRequest, opts ...grpc.CallOption) (*InvoiceResponse, error)
+	ListInvoices(ctx context.Context, in *ListInvoicesRequest, opts ...grpc.CallOption) (*ListInvoicesResponse, error)
+	RegisterWebhook(ctx context.Context, in *RegisterWebhookRequest, opts ...grpc.CallOption) (*WebhookResponse, error)
+	ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error)
+	DeleteWebhook(ctx context.Context, in *WebhookAction, opts ...grpc.CallOption) (*WebhookResponse, error)
+	ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error)
+	RedeliverWebhook(ctx context.Context, in *WebhookDeliveryAction, opts ...grpc.CallOption) (*WebhookDeliveryResponse, error)
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GatewayEvent], error)
+	SubmitPayment(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*PaymentStatus, error)
+	GetPaymentStatus(ctx context.Context, in *PaymentStatusRequest, opts ...grpc.CallOption) (*PaymentStatus, error)
+	WaitForPayment(ctx context.Context, in *WaitForPaymentRequest, opts ...grpc.CallOption) (*PaymentStatus, error)
+	ListQueuedPayments(ctx context.Context, in *ListQueuedPaymentsRequest, opts ...grpc.CallOption) (*ListQueuedPaymentsResponse, error)
 }
 
 type paymentGatewayClient struct {
@@ -97,6 +197,524 @@ func (c *paymentGatewayClient) Unregister(ctx context.Context, in *UnregisterReq
 	return out, nil
 }
 
+func (c *paymentGatewayClient) GetSettlementReport(ctx context.Context, in *SettlementReportRequest, opts ...grpc.CallOption) (*SettlementReportResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SettlementReportResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_GetSettlementReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) SettlePositions(ctx context.Context, in *SettlePositionsRequest, opts ...grpc.CallOption) (*SettlePositionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SettlePositionsResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_SettlePositions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ReloadFXRates(ctx context.Context, in *ReloadFXRatesRequest, opts ...grpc.CallOption) (*ReloadFXRatesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReloadFXRatesResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ReloadFXRates_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RefundPayment(ctx context.Context, in *RefundRequest, opts ...grpc.CallOption) (*RefundResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RefundResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RefundPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) SchedulePayment(ctx context.Context, in *SchedulePaymentRequest, opts ...grpc.CallOption) (*SchedulePaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SchedulePaymentResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_SchedulePayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListSchedules(ctx context.Context, in *ListSchedulesRequest, opts ...grpc.CallOption) (*ListSchedulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSchedulesResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListSchedules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) PauseSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScheduleActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_PauseSchedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ResumeSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScheduleActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ResumeSchedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) CancelSchedule(ctx context.Context, in *ScheduleActionRequest, opts ...grpc.CallOption) (*ScheduleActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScheduleActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_CancelSchedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RequestPayment(ctx context.Context, in *RequestPaymentRequest, opts ...grpc.CallOption) (*RequestPaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RequestPaymentResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RequestPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListPaymentRequests(ctx context.Context, in *ListPaymentRequestsRequest, opts ...grpc.CallOption) (*ListPaymentRequestsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPaymentRequestsResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListPaymentRequests_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ApprovePaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentRequestActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ApprovePaymentRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) DeclinePaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentRequestActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_DeclinePaymentRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) CancelPaymentRequest(ctx context.Context, in *PaymentRequestAction, opts ...grpc.CallOption) (*PaymentRequestActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentRequestActionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_CancelPaymentRequest_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) CreateEscrow(ctx context.Context, in *CreateEscrowRequest, opts ...grpc.CallOption) (*EscrowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EscrowResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_CreateEscrow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ReleaseEscrow(ctx context.Context, in *EscrowAction, opts ...grpc.CallOption) (*EscrowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EscrowResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ReleaseEscrow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RefundEscrow(ctx context.Context, in *EscrowAction, opts ...grpc.CallOption) (*EscrowResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EscrowResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RefundEscrow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListEscrows(ctx context.Context, in *ListEscrowsRequest, opts ...grpc.CallOption) (*ListEscrowsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEscrowsResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListEscrows_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ProcessMultiPayment(ctx context.Context, in *MultiPaymentRequest, opts ...grpc.CallOption) (*MultiPaymentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MultiPaymentResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ProcessMultiPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) SubmitPaymentBatch(ctx context.Context, in *PaymentBatchRequest, opts ...grpc.CallOption) (*PaymentBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentBatchResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_SubmitPaymentBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) GetPaymentBatch(ctx context.Context, in *PaymentBatchStatusRequest, opts ...grpc.CallOption) (*PaymentBatchStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentBatchStatus)
+	err := c.cc.Invoke(ctx, PaymentGateway_GetPaymentBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) WatchPaymentBatch(ctx context.Context, in *PaymentBatchStatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PaymentBatchStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PaymentGateway_ServiceDesc.Streams[0], PaymentGateway_WatchPaymentBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PaymentBatchStatusRequest, PaymentBatchStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentGateway_WatchPaymentBatchClient = grpc.ServerStreamingClient[PaymentBatchStatus]
+
+func (c *paymentGatewayClient) SetSpendingLimit(ctx context.Context, in *SetSpendingLimitRequest, opts ...grpc.CallOption) (*SpendingLimitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SpendingLimitResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_SetSpendingLimit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) SetUserRole(ctx context.Context, in *SetUserRoleRequest, opts ...grpc.CallOption) (*SpendingLimitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SpendingLimitResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_SetUserRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) GetSpendingHeadroom(ctx context.Context, in *HeadroomRequest, opts ...grpc.CallOption) (*HeadroomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HeadroomResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_GetSpendingHeadroom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListReviews(ctx context.Context, in *ListReviewsRequest, opts ...grpc.CallOption) (*ListReviewsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListReviewsResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListReviews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ApproveReview(ctx context.Context, in *ReviewDecision, opts ...grpc.CallOption) (*ReviewDecisionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReviewDecisionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ApproveReview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RejectReview(ctx context.Context, in *ReviewDecision, opts ...grpc.CallOption) (*ReviewDecisionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReviewDecisionResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RejectReview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) OpenDispute(ctx context.Context, in *OpenDisputeRequest, opts ...grpc.CallOption) (*DisputeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisputeResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_OpenDispute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) InvestigateDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisputeResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_InvestigateDispute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) AcceptDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisputeResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_AcceptDispute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RejectDispute(ctx context.Context, in *DisputeAction, opts ...grpc.CallOption) (*DisputeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DisputeResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RejectDispute_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListDisputes(ctx context.Context, in *ListDisputesRequest, opts ...grpc.CallOption) (*ListDisputesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDisputesResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListDisputes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) CreateApiKey(ctx context.Context, in *CreateApiKeyRequest, opts ...grpc.CallOption) (*ApiKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApiKeyResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_CreateApiKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListApiKeys(ctx context.Context, in *ListApiKeysRequest, opts ...grpc.CallOption) (*ListApiKeysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListApiKeysResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListApiKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RotateApiKey(ctx context.Context, in *ApiKeyAction, opts ...grpc.CallOption) (*ApiKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApiKeyResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RotateApiKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RevokeApiKey(ctx context.Context, in *ApiKeyAction, opts ...grpc.CallOption) (*ApiKeyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApiKeyResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RevokeApiKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) CreateInvoice(ctx context.Context, in *CreateInvoiceRequest, opts ...grpc.CallOption) (*InvoiceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InvoiceResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_CreateInvoice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) GetInvoice(ctx context.Context, in *GetInvoiceRequest, opts ...grpc.CallOption) (*InvoiceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InvoiceResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_GetInvoice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListInvoices(ctx context.Context, in *ListInvoicesRequest, opts ...grpc.CallOption) (*ListInvoicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListInvoicesResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListInvoices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RegisterWebhook(ctx context.Context, in *RegisterWebhookRequest, opts ...grpc.CallOption) (*WebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WebhookResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RegisterWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListWebhooksResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListWebhooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) DeleteWebhook(ctx context.Context, in *WebhookAction, opts ...grpc.CallOption) (*WebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WebhookResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_DeleteWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListWebhookDeliveries(ctx context.Context, in *ListWebhookDeliveriesRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListWebhookDeliveriesResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListWebhookDeliveries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) RedeliverWebhook(ctx context.Context, in *WebhookDeliveryAction, opts ...grpc.CallOption) (*WebhookDeliveryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WebhookDeliveryResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_RedeliverWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GatewayEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PaymentGateway_ServiceDesc.Streams[1], PaymentGateway_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, GatewayEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentGateway_SubscribeEventsClient = grpc.ServerStreamingClient[GatewayEvent]
+
+func (c *paymentGatewayClient) SubmitPayment(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*PaymentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentStatus)
+	err := c.cc.Invoke(ctx, PaymentGateway_SubmitPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) GetPaymentStatus(ctx context.Context, in *PaymentStatusRequest, opts ...grpc.CallOption) (*PaymentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentStatus)
+	err := c.cc.Invoke(ctx, PaymentGateway_GetPaymentStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) WaitForPayment(ctx context.Context, in *WaitForPaymentRequest, opts ...grpc.CallOption) (*PaymentStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PaymentStatus)
+	err := c.cc.Invoke(ctx, PaymentGateway_WaitForPayment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentGatewayClient) ListQueuedPayments(ctx context.Context, in *ListQueuedPaymentsRequest, opts ...grpc.CallOption) (*ListQueuedPaymentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListQueuedPaymentsResponse)
+	err := c.cc.Invoke(ctx, PaymentGateway_ListQueuedPayments_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // PaymentGatewayServer is the server API for PaymentGateway service.
 // All implementations must embed UnimplementedPaymentGatewayServer
 // for forward compatibility.
@@ -108,138 +726,1224 @@ type PaymentGatewayServer interface {
 	GetBalance(context.Context, *BalanceRequest) (*BalanceResponse, error)
 	GetTransactionHistory(context.Context, *HistoryRequest) (*HistoryResponse, error)
 	Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error)
+	GetSettlementReport(context.Context, *SettlementReportRequest) (*SettlementReportResponse, error)
+	SettlePositions(context.Context, *SettlePositionsRequest) (*SettlePositionsResponse, error)
+	ReloadFXRates(context.Context, *ReloadFXRatesRequest) (*ReloadFXRatesResponse, error)
+	RefundPayment(context.Context, *RefundRequest) (*RefundResponse, error)
+	SchedulePayment(context.Context, *SchedulePaymentRequest) (*SchedulePaymentResponse, error)
+	ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error)
+	PauseSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error)
+	ResumeSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error)
+	CancelSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error)
+	RequestPayment(context.Context, *RequestPaymentRequest) (*RequestPaymentResponse, error)
+	ListPaymentRequests(context.Context, *ListPaymentRequestsRequest) (*ListPaymentRequestsResponse, error)
+	ApprovePaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error)
+	DeclinePaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error)
+	CancelPaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error)
+	CreateEscrow(context.Context, *CreateEscrowRequest) (*EscrowResponse, error)
+	ReleaseEscrow(context.Context, *EscrowAction) (*EscrowResponse, error)
+	RefundEscrow(context.Context, *EscrowAction) (*EscrowResponse, error)
+	ListEscrows(context.Context, *ListEscrowsRequest) (*ListEscrowsResponse, error)
+	ProcessMultiPayment(context.Context, *MultiPaymentRequest) (*MultiPaymentResponse, error)
+	SubmitPaymentBatch(context.Context, *PaymentBatchRequest) (*PaymentBatchResponse, error)
+	GetPaymentBatch(context.Context, *PaymentBatchStatusRequest) (*PaymentBatchStatus, error)
+	WatchPaymentBatch(*PaymentBatchStatusRequest, grpc.ServerStreamingServer[PaymentBatchStatus]) error
+	SetSpendingLimit(context.Context, *SetSpendingLimitRequest) (*SpendingLimitResponse, error)
+	SetUserRole(context.Context, *SetUserRoleRequest) (*SpendingLimitResponse, error)
+	GetSpendingHeadroom(context.Context, *HeadroomRequest) (*HeadroomResponse, error)
+	ListReviews(context.Context, *ListReviewsRequest) (*ListReviewsResponse, error)
+	ApproveReview(context.Context, *ReviewDecision) (*ReviewDecisionResponse, error)
+	RejectReview(context.Context, *ReviewDecision) (*ReviewDecisionResponse, error)
+	OpenDispute(context.Context, *OpenDisputeRequest) (*DisputeResponse, error)
+	InvestigateDispute(context.Context, *DisputeAction) (*DisputeResponse, error)
+	AcceptDispute(context.Context, *DisputeAction) (*DisputeResponse, error)
+	RejectDispute(context.Context, *DisputeAction) (*DisputeResponse, error)
+	ListDisputes(context.Context, *ListDisputesRequest) (*ListDisputesResponse, error)
+	CreateApiKey(context.Context, *CreateApiKeyRequest) (*ApiKeyResponse, error)
+	ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error)
+	RotateApiKey(context.Context, *ApiKeyAction) (*ApiKeyResponse, error)
+	RevokeApiKey(context.Context, *ApiKeyAction) (*ApiKeyResponse, error)
+	CreateInvoice(context.Context, *CreateInvoiceRequest) (*InvoiceResponse, error)
+	GetInvoice(context.Context, *GetInvoiceRequest) (*InvoiceResponse, error)
+	ListInvoices(context.Context, *ListInvoicesRequest) (*ListInvoicesResponse, error)
+	RegisterWebhook(context.Context, *RegisterWebhookRequest) (*WebhookResponse, error)
+	ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error)
+	DeleteWebhook(context.Context, *WebhookAction) (*WebhookResponse, error)
+	ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error)
+	RedeliverWebhook(context.Context, *WebhookDeliveryAction) (*WebhookDeliveryResponse, error)
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[GatewayEvent]) error
+	SubmitPayment(context.Context, *TransactionRequest) (*PaymentStatus, error)
+	GetPaymentStatus(context.Context, *PaymentStatusRequest) (*PaymentStatus, error)
+	WaitForPayment(context.Context, *WaitForPaymentRequest) (*PaymentStatus, error)
+	ListQueuedPayments(context.Context, *ListQueuedPaymentsRequest) (*ListQueuedPaymentsResponse, error)
+	mustEmbedUnimplementedPaymentGatewayServer()
+}
+
+// UnimplementedPaymentGatewayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPaymentGatewayServer struct{}
+
+func (UnimplementedPaymentGatewayServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ProcessPayment(context.Context, *TransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetBalance(context.Context, *BalanceRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetTransactionHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionHistory not implemented")
+}
+func (UnimplementedPaymentGatewayServer) Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unregister not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetSettlementReport(context.Context, *SettlementReportRequest) (*SettlementReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSettlementReport not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SettlePositions(context.Context, *SettlePositionsRequest) (*SettlePositionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SettlePositions not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ReloadFXRates(context.Context, *ReloadFXRatesRequest) (*ReloadFXRatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadFXRates not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RefundPayment(context.Context, *RefundRequest) (*RefundResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefundPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SchedulePayment(context.Context, *SchedulePaymentRequest) (*SchedulePaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SchedulePayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListSchedules(context.Context, *ListSchedulesRequest) (*ListSchedulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSchedules not implemented")
+}
+func (UnimplementedPaymentGatewayServer) PauseSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseSchedule not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ResumeSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeSchedule not implemented")
+}
+func (UnimplementedPaymentGatewayServer) CancelSchedule(context.Context, *ScheduleActionRequest) (*ScheduleActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelSchedule not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RequestPayment(context.Context, *RequestPaymentRequest) (*RequestPaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListPaymentRequests(context.Context, *ListPaymentRequestsRequest) (*ListPaymentRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPaymentRequests not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ApprovePaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApprovePaymentRequest not implemented")
+}
+func (UnimplementedPaymentGatewayServer) DeclinePaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeclinePaymentRequest not implemented")
+}
+func (UnimplementedPaymentGatewayServer) CancelPaymentRequest(context.Context, *PaymentRequestAction) (*PaymentRequestActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelPaymentRequest not implemented")
+}
+func (UnimplementedPaymentGatewayServer) CreateEscrow(context.Context, *CreateEscrowRequest) (*EscrowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEscrow not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ReleaseEscrow(context.Context, *EscrowAction) (*EscrowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseEscrow not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RefundEscrow(context.Context, *EscrowAction) (*EscrowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefundEscrow not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListEscrows(context.Context, *ListEscrowsRequest) (*ListEscrowsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEscrows not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ProcessMultiPayment(context.Context, *MultiPaymentRequest) (*MultiPaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessMultiPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SubmitPaymentBatch(context.Context, *PaymentBatchRequest) (*PaymentBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitPaymentBatch not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetPaymentBatch(context.Context, *PaymentBatchStatusRequest) (*PaymentBatchStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPaymentBatch not implemented")
+}
+func (UnimplementedPaymentGatewayServer) WatchPaymentBatch(*PaymentBatchStatusRequest, grpc.ServerStreamingServer[PaymentBatchStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchPaymentBatch not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SetSpendingLimit(context.Context, *SetSpendingLimitRequest) (*SpendingLimitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSpendingLimit not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SetUserRole(context.Context, *SetUserRoleRequest) (*SpendingLimitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserRole not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetSpendingHeadroom(context.Context, *HeadroomRequest) (*HeadroomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSpendingHeadroom not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListReviews(context.Context, *ListReviewsRequest) (*ListReviewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReviews not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ApproveReview(context.Context, *ReviewDecision) (*ReviewDecisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveReview not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RejectReview(context.Context, *ReviewDecision) (*ReviewDecisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectReview not implemented")
+}
+func (UnimplementedPaymentGatewayServer) OpenDispute(context.Context, *OpenDisputeRequest) (*DisputeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OpenDispute not implemented")
+}
+func (UnimplementedPaymentGatewayServer) InvestigateDispute(context.Context, *DisputeAction) (*DisputeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InvestigateDispute not implemented")
+}
+func (UnimplementedPaymentGatewayServer) AcceptDispute(context.Context, *DisputeAction) (*DisputeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptDispute not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RejectDispute(context.Context, *DisputeAction) (*DisputeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectDispute not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListDisputes(context.Context, *ListDisputesRequest) (*ListDisputesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDisputes not implemented")
+}
+func (UnimplementedPaymentGatewayServer) CreateApiKey(context.Context, *CreateApiKeyRequest) (*ApiKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateApiKey not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListApiKeys(context.Context, *ListApiKeysRequest) (*ListApiKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListApiKeys not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RotateApiKey(context.Context, *ApiKeyAction) (*ApiKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateApiKey not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RevokeApiKey(context.Context, *ApiKeyAction) (*ApiKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeApiKey not implemented")
+}
+func (UnimplementedPaymentGatewayServer) CreateInvoice(context.Context, *CreateInvoiceRequest) (*InvoiceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateInvoice not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetInvoice(context.Context, *GetInvoiceRequest) (*InvoiceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInvoice not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListInvoices(context.Context, *ListInvoicesRequest) (*ListInvoicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListInvoices not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RegisterWebhook(context.Context, *RegisterWebhookRequest) (*WebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterWebhook not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedPaymentGatewayServer) DeleteWebhook(context.Context, *WebhookAction) (*WebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListWebhookDeliveries(context.Context, *ListWebhookDeliveriesRequest) (*ListWebhookDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeliveries not implemented")
+}
+func (UnimplementedPaymentGatewayServer) RedeliverWebhook(context.Context, *WebhookDeliveryAction) (*WebhookDeliveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeliverWebhook not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[GatewayEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedPaymentGatewayServer) SubmitPayment(context.Context, *TransactionRequest) (*PaymentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) GetPaymentStatus(context.Context, *PaymentStatusRequest) (*PaymentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPaymentStatus not implemented")
+}
+func (UnimplementedPaymentGatewayServer) WaitForPayment(context.Context, *WaitForPaymentRequest) (*PaymentStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WaitForPayment not implemented")
+}
+func (UnimplementedPaymentGatewayServer) ListQueuedPayments(context.Context, *ListQueuedPaymentsRequest) (*ListQueuedPaymentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListQueuedPayments not implemented")
+}
+func (UnimplementedPaymentGatewayServer) mustEmbedUnimplementedPaymentGatewayServer() {}
+func (UnimplementedPaymentGatewayServer) testEmbeddedByValue()                        {}
+
+// UnsafePaymentGatewayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PaymentGatewayServer will
+// result in compilation errors.
+type UnsafePaymentGatewayServer interface {
 	mustEmbedUnimplementedPaymentGatewayServer()
 }
 
-// UnimplementedPaymentGatewayServer must be embedded to have
-// forward compatible implementations.
-//
-// NOTE: this should be embedded by value instead of pointer to avoid a nil
-// pointer dereference when methods are called.
-type UnimplementedPaymentGatewayServer struct{}
+func RegisterPaymentGatewayServer(s grpc.ServiceRegistrar, srv PaymentGatewayServer) {
+	// If the following call pancis, it indicates UnimplementedPaymentGatewayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PaymentGateway_ServiceDesc, srv)
+}
+
+func _PaymentGateway_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ProcessPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ProcessPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ProcessPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ProcessPayment(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetBalance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_GetTransactionHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetTransactionHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetTransactionHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetTransactionHistory(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_Unregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).Unregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_Unregister_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).Unregister(ctx, req.(*UnregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_GetSettlementReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettlementReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetSettlementReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetSettlementReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetSettlementReport(ctx, req.(*SettlementReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_SettlePositions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SettlePositionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).SettlePositions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_SettlePositions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).SettlePositions(ctx, req.(*SettlePositionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ReloadFXRates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadFXRatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ReloadFXRates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ReloadFXRates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ReloadFXRates(ctx, req.(*ReloadFXRatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RefundPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RefundPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RefundPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RefundPayment(ctx, req.(*RefundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_SchedulePayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SchedulePaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).SchedulePayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_SchedulePayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).SchedulePayment(ctx, req.(*SchedulePaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListSchedules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSchedulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListSchedules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListSchedules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListSchedules(ctx, req.(*ListSchedulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_PauseSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).PauseSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_PauseSchedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).PauseSchedule(ctx, req.(*ScheduleActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ResumeSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ResumeSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ResumeSchedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ResumeSchedule(ctx, req.(*ScheduleActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_CancelSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).CancelSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_CancelSchedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).CancelSchedule(ctx, req.(*ScheduleActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RequestPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestPaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RequestPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RequestPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RequestPayment(ctx, req.(*RequestPaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListPaymentRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPaymentRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListPaymentRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListPaymentRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListPaymentRequests(ctx, req.(*ListPaymentRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ApprovePaymentRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentRequestAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ApprovePaymentRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ApprovePaymentRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ApprovePaymentRequest(ctx, req.(*PaymentRequestAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_DeclinePaymentRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentRequestAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).DeclinePaymentRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_DeclinePaymentRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).DeclinePaymentRequest(ctx, req.(*PaymentRequestAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_CancelPaymentRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentRequestAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).CancelPaymentRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_CancelPaymentRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).CancelPaymentRequest(ctx, req.(*PaymentRequestAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_CreateEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateEscrowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).CreateEscrow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_CreateEscrow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).CreateEscrow(ctx, req.(*CreateEscrowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ReleaseEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EscrowAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ReleaseEscrow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ReleaseEscrow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ReleaseEscrow(ctx, req.(*EscrowAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RefundEscrow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EscrowAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RefundEscrow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RefundEscrow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RefundEscrow(ctx, req.(*EscrowAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListEscrows_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListEscrowsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListEscrows(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListEscrows_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListEscrows(ctx, req.(*ListEscrowsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ProcessMultiPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultiPaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ProcessMultiPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ProcessMultiPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ProcessMultiPayment(ctx, req.(*MultiPaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_SubmitPaymentBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).SubmitPaymentBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_SubmitPaymentBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).SubmitPaymentBatch(ctx, req.(*PaymentBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-func (UnimplementedPaymentGatewayServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+func _PaymentGateway_GetPaymentBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentBatchStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetPaymentBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetPaymentBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetPaymentBatch(ctx, req.(*PaymentBatchStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPaymentGatewayServer) ProcessPayment(context.Context, *TransactionRequest) (*TransactionResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ProcessPayment not implemented")
+
+func _PaymentGateway_WatchPaymentBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PaymentBatchStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PaymentGatewayServer).WatchPaymentBatch(m, &grpc.GenericServerStream[PaymentBatchStatusRequest, PaymentBatchStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentGateway_WatchPaymentBatchServer = grpc.ServerStreamingServer[PaymentBatchStatus]
+
+func _PaymentGateway_SetSpendingLimit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSpendingLimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).SetSpendingLimit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_SetSpendingLimit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).SetSpendingLimit(ctx, req.(*SetSpendingLimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_SetUserRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).SetUserRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_SetUserRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).SetUserRole(ctx, req.(*SetUserRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_GetSpendingHeadroom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeadroomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetSpendingHeadroom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetSpendingHeadroom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetSpendingHeadroom(ctx, req.(*HeadroomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListReviews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReviewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListReviews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListReviews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListReviews(ctx, req.(*ListReviewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ApproveReview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ApproveReview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ApproveReview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ApproveReview(ctx, req.(*ReviewDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RejectReview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReviewDecision)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RejectReview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RejectReview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RejectReview(ctx, req.(*ReviewDecision))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_OpenDispute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenDisputeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).OpenDispute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_OpenDispute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).OpenDispute(ctx, req.(*OpenDisputeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_InvestigateDispute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisputeAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).InvestigateDispute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_InvestigateDispute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).InvestigateDispute(ctx, req.(*DisputeAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_AcceptDispute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisputeAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).AcceptDispute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_AcceptDispute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).AcceptDispute(ctx, req.(*DisputeAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RejectDispute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisputeAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RejectDispute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RejectDispute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RejectDispute(ctx, req.(*DisputeAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListDisputes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDisputesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListDisputes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListDisputes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListDisputes(ctx, req.(*ListDisputesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_CreateApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateApiKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).CreateApiKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_CreateApiKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).CreateApiKey(ctx, req.(*CreateApiKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_ListApiKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListApiKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListApiKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListApiKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListApiKeys(ctx, req.(*ListApiKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RotateApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApiKeyAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RotateApiKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RotateApiKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RotateApiKey(ctx, req.(*ApiKeyAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_RevokeApiKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApiKeyAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RevokeApiKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RevokeApiKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RevokeApiKey(ctx, req.(*ApiKeyAction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_CreateInvoice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInvoiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).CreateInvoice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_CreateInvoice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).CreateInvoice(ctx, req.(*CreateInvoiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PaymentGateway_GetInvoice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInvoiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).GetInvoice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_GetInvoice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).GetInvoice(ctx, req.(*GetInvoiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPaymentGatewayServer) GetBalance(context.Context, *BalanceRequest) (*BalanceResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+
+func _PaymentGateway_ListInvoices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListInvoicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListInvoices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListInvoices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListInvoices(ctx, req.(*ListInvoicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPaymentGatewayServer) GetTransactionHistory(context.Context, *HistoryRequest) (*HistoryResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionHistory not implemented")
+
+func _PaymentGateway_RegisterWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).RegisterWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_RegisterWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).RegisterWebhook(ctx, req.(*RegisterWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPaymentGatewayServer) Unregister(context.Context, *UnregisterRequest) (*UnregisterResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Unregister not implemented")
+
+func _PaymentGateway_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListWebhooks(ctx, req.(*ListWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-func (UnimplementedPaymentGatewayServer) mustEmbedUnimplementedPaymentGatewayServer() {}
-func (UnimplementedPaymentGatewayServer) testEmbeddedByValue()                        {}
 
-// UnsafePaymentGatewayServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to PaymentGatewayServer will
-// result in compilation errors.
-type UnsafePaymentGatewayServer interface {
-	mustEmbedUnimplementedPaymentGatewayServer()
+func _PaymentGateway_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookAction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).DeleteWebhook(ctx, req.(*WebhookAction))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func RegisterPaymentGatewayServer(s grpc.ServiceRegistrar, srv PaymentGatewayServer) {
-	// If the following call pancis, it indicates UnimplementedPaymentGatewayServer was
-	// embedded by pointer and is nil.  This will cause panics if an
-	// unimplemented method is ever invoked, so we test this at initialization
-	// time to prevent it from happening at runtime later due to I/O.
-	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
-		t.testEmbeddedByValue()
+func _PaymentGateway_ListWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	s.RegisterService(&PaymentGateway_ServiceDesc, srv)
+	if interceptor == nil {
+		return srv.(PaymentGatewayServer).ListWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PaymentGateway_ListWebhookDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentGatewayServer).ListWebhookDeliveries(ctx, req.(*ListWebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func _PaymentGateway_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(RegisterRequest)
+func _PaymentGateway_RedeliverWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookDeliveryAction)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PaymentGatewayServer).Register(ctx, in)
+		return srv.(PaymentGatewayServer).RedeliverWebhook(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PaymentGateway_Register_FullMethodName,
+		FullMethod: PaymentGateway_RedeliverWebhook_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PaymentGatewayServer).Register(ctx, req.(*RegisterRequest))
+		return srv.(PaymentGatewayServer).RedeliverWebhook(ctx, req.(*WebhookDeliveryAction))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PaymentGateway_ProcessPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _PaymentGateway_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PaymentGatewayServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, GatewayEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PaymentGateway_SubscribeEventsServer = grpc.ServerStreamingServer[GatewayEvent]
+
+func _PaymentGateway_SubmitPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TransactionRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PaymentGatewayServer).ProcessPayment(ctx, in)
+		return srv.(PaymentGatewayServer).SubmitPayment(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PaymentGateway_ProcessPayment_FullMethodName,
+		FullMethod: PaymentGateway_SubmitPayment_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PaymentGatewayServer).ProcessPayment(ctx, req.(*TransactionRequest))
+		return srv.(PaymentGatewayServer).SubmitPayment(ctx, req.(*TransactionRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PaymentGateway_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(BalanceRequest)
+func _PaymentGateway_GetPaymentStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentStatusRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PaymentGatewayServer).GetBalance(ctx, in)
+		return srv.(PaymentGatewayServer).GetPaymentStatus(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PaymentGateway_GetBalance_FullMethodName,
+		FullMethod: PaymentGateway_GetPaymentStatus_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PaymentGatewayServer).GetBalance(ctx, req.(*BalanceRequest))
+		return srv.(PaymentGatewayServer).GetPaymentStatus(ctx, req.(*PaymentStatusRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PaymentGateway_GetTransactionHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(HistoryRequest)
+func _PaymentGateway_WaitForPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitForPaymentRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PaymentGatewayServer).GetTransactionHistory(ctx, in)
+		return srv.(PaymentGatewayServer).WaitForPayment(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PaymentGateway_GetTransactionHistory_FullMethodName,
+		FullMethod: PaymentGateway_WaitForPayment_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PaymentGatewayServer).GetTransactionHistory(ctx, req.(*HistoryRequest))
+		return srv.(PaymentGatewayServer).WaitForPayment(ctx, req.(*WaitForPaymentRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _PaymentGateway_Unregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UnregisterRequest)
+func _PaymentGateway_ListQueuedPayments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListQueuedPaymentsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(PaymentGatewayServer).Unregister(ctx, in)
+		return srv.(PaymentGatewayServer).ListQueuedPayments(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: PaymentGateway_Unregister_FullMethodName,
+		FullMethod: PaymentGateway_ListQueuedPayments_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PaymentGatewayServer).Unregister(ctx, req.(*UnregisterRequest))
+		return srv.(PaymentGatewayServer).ListQueuedPayments(ctx, req.(*ListQueuedPaymentsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -271,8 +1975,211 @@ var PaymentGateway_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Unregister",
 			Handler:    _PaymentGateway_Unregister_Handler,
 		},
+		{
+			MethodName: "GetSettlementReport",
+			Handler:    _PaymentGateway_GetSettlementReport_Handler,
+		},
+		{
+			MethodName: "SettlePositions",
+			Handler:    _PaymentGateway_SettlePositions_Handler,
+		},
+		{
+			MethodName: "ReloadFXRates",
+			Handler:    _PaymentGateway_ReloadFXRates_Handler,
+		},
+		{
+			MethodName: "RefundPayment",
+			Handler:    _PaymentGateway_RefundPayment_Handler,
+		},
+		{
+			MethodName: "SchedulePayment",
+			Handler:    _PaymentGateway_SchedulePayment_Handler,
+		},
+		{
+			MethodName: "ListSchedules",
+			Handler:    _PaymentGateway_ListSchedules_Handler,
+		},
+		{
+			MethodName: "PauseSchedule",
+			Handler:    _PaymentGateway_PauseSchedule_Handler,
+		},
+		{
+			MethodName: "ResumeSchedule",
+			Handler:    _PaymentGateway_ResumeSchedule_Handler,
+		},
+		{
+			MethodName: "CancelSchedule",
+			Handler:    _PaymentGateway_CancelSchedule_Handler,
+		},
+		{
+			MethodName: "RequestPayment",
+			Handler:    _PaymentGateway_RequestPayment_Handler,
+		},
+		{
+			MethodName: "ListPaymentRequests",
+			Handler:    _PaymentGateway_ListPaymentRequests_Handler,
+		},
+		{
+			MethodName: "ApprovePaymentRequest",
+			Handler:    _PaymentGateway_ApprovePaymentRequest_Handler,
+		},
+		{
+			MethodName: "DeclinePaymentRequest",
+			Handler:    _PaymentGateway_DeclinePaymentRequest_Handler,
+		},
+		{
+			MethodName: "CancelPaymentRequest",
+			Handler:    _PaymentGateway_CancelPaymentRequest_Handler,
+		},
+		{
+			MethodName: "CreateEscrow",
+			Handler:    _PaymentGateway_CreateEscrow_Handler,
+		},
+		{
+			MethodName: "ReleaseEscrow",
+			Handler:    _PaymentGateway_ReleaseEscrow_Handler,
+		},
+		{
+			MethodName: "RefundEscrow",
+			Handler:    _PaymentGateway_RefundEscrow_Handler,
+		},
+		{
+			MethodName: "ListEscrows",
+			Handler:    _PaymentGateway_ListEscrows_Handler,
+		},
+		{
+			MethodName: "ProcessMultiPayment",
+			Handler:    _PaymentGateway_ProcessMultiPayment_Handler,
+		},
+		{
+			MethodName: "SubmitPaymentBatch",
+			Handler:    _PaymentGateway_SubmitPaymentBatch_Handler,
+		},
+		{
+			MethodName: "GetPaymentBatch",
+			Handler:    _PaymentGateway_GetPaymentBatch_Handler,
+		},
+		{
+			MethodName: "SetSpendingLimit",
+			Handler:    _PaymentGateway_SetSpendingLimit_Handler,
+		},
+		{
+			MethodName: "SetUserRole",
+			Handler:    _PaymentGateway_SetUserRole_Handler,
+		},
+		{
+			MethodName: "GetSpendingHeadroom",
+			Handler:    _PaymentGateway_GetSpendingHeadroom_Handler,
+		},
+		{
+			MethodName: "ListReviews",
+			Handler:    _PaymentGateway_ListReviews_Handler,
+		},
+		{
+			MethodName: "ApproveReview",
+			Handler:    _PaymentGateway_ApproveReview_Handler,
+		},
+		{
+			MethodName: "RejectReview",
+			Handler:    _PaymentGateway_RejectReview_Handler,
+		},
+		{
+			MethodName: "OpenDispute",
+			Handler:    _PaymentGateway_OpenDispute_Handler,
+		},
+		{
+			MethodName: "InvestigateDispute",
+			Handler:    _PaymentGateway_InvestigateDispute_Handler,
+		},
+		{
+			MethodName: "AcceptDispute",
+			Handler:    _PaymentGateway_AcceptDispute_Handler,
+		},
+		{
+			MethodName: "RejectDispute",
+			Handler:    _PaymentGateway_RejectDispute_Handler,
+		},
+		{
+			MethodName: "ListDisputes",
+			Handler:    _PaymentGateway_ListDisputes_Handler,
+		},
+		{
+			MethodName: "CreateApiKey",
+			Handler:    _PaymentGateway_CreateApiKey_Handler,
+		},
+		{
+			MethodName: "ListApiKeys",
+			Handler:    _PaymentGateway_ListApiKeys_Handler,
+		},
+		{
+			MethodName: "RotateApiKey",
+			Handler:    _PaymentGateway_RotateApiKey_Handler,
+		},
+		{
+			MethodName: "RevokeApiKey",
+			Handler:    _PaymentGateway_RevokeApiKey_Handler,
+		},
+		{
+			MethodName: "CreateInvoice",
+			Handler:    _PaymentGateway_CreateInvoice_Handler,
+		},
+		{
+			MethodName: "GetInvoice",
+			Handler:    _PaymentGateway_GetInvoice_Handler,
+		},
+		{
+			MethodName: "ListInvoices",
+			Handler:    _PaymentGateway_ListInvoices_Handler,
+		},
+		{
+			MethodName: "RegisterWebhook",
+			Handler:    _PaymentGateway_RegisterWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _PaymentGateway_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _PaymentGateway_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeliveries",
+			Handler:    _PaymentGateway_ListWebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "RedeliverWebhook",
+			Handler:    _PaymentGateway_RedeliverWebhook_Handler,
+		},
+		{
+			MethodName: "SubmitPayment",
+			Handler:    _PaymentGateway_SubmitPayment_Handler,
+		},
+		{
+			MethodName: "GetPaymentStatus",
+			Handler:    _PaymentGateway_GetPaymentStatus_Handler,
+		},
+		{
+			MethodName: "WaitForPayment",
+			Handler:    _PaymentGateway_WaitForPayment_Handler,
+		},
+		{
+			MethodName: "ListQueuedPayments",
+			Handler:    _PaymentGateway_ListQueuedPayments_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchPaymentBatch",
+			Handler:       _PaymentGateway_WatchPaymentBatch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _PaymentGateway_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protofiles/payment.proto",
 }
 
@@ -281,6 +2188,8 @@ const (
 	BankService_CommitPayment_FullMethodName  = "/payment.BankService/CommitPayment"
 	BankService_AbortPayment_FullMethodName   = "/payment.BankService/AbortPayment"
 	BankService_GetBalance_FullMethodName     = "/payment.BankService/GetBalance"
+	BankService_PostSettlement_FullMethodName = "/payment.BankService/PostSettlement"
+	BankService_Transfer_FullMethodName       = "/payment.BankService/Transfer"
 )
 
 // BankServiceClient is the client API for BankService service.
@@ -293,6 +2202,8 @@ type BankServiceClient interface {
 	CommitPayment(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
 	AbortPayment(ctx context.Context, in *AbortRequest, opts ...grpc.CallOption) (*AbortResponse, error)
 	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*GetBalanceResponse, error)
+	PostSettlement(ctx context.Context, in *PostSettlementRequest, opts ...grpc.CallOption) (*PostSettlementResponse, error)
+	Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
 }
 
 type bankServiceClient struct {
@@ -343,6 +2254,26 @@ func (c *bankServiceClient) GetBalance(ctx context.Context, in *GetBalanceReques
 	return out, nil
 }
 
+func (c *bankServiceClient) PostSettlement(ctx context.Context, in *PostSettlementRequest, opts ...grpc.CallOption) (*PostSettlementResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PostSettlementResponse)
+	err := c.cc.Invoke(ctx, BankService_PostSettlement_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bankServiceClient) Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, BankService_Transfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BankServiceServer is the server API for BankService service.
 // All implementations must embed UnimplementedBankServiceServer
 // for forward compatibility.
@@ -353,6 +2284,8 @@ type BankServiceServer interface {
 	CommitPayment(context.Context, *CommitRequest) (*CommitResponse, error)
 	AbortPayment(context.Context, *AbortRequest) (*AbortResponse, error)
 	GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error)
+	PostSettlement(context.Context, *PostSettlementRequest) (*PostSettlementResponse, error)
+	Transfer(context.Context, *TransferRequest) (*TransferResponse, error)
 	mustEmbedUnimplementedBankServiceServer()
 }
 
@@ -375,6 +2308,12 @@ func (UnimplementedBankServiceServer) AbortPayment(context.Context, *AbortReques
 func (UnimplementedBankServiceServer) GetBalance(context.Context, *GetBalanceRequest) (*GetBalanceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
 }
+func (UnimplementedBankServiceServer) PostSettlement(context.Context, *PostSettlementRequest) (*PostSettlementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PostSettlement not implemented")
+}
+func (UnimplementedBankServiceServer) Transfer(context.Context, *TransferRequest) (*TransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transfer not implemented")
+}
 func (UnimplementedBankServiceServer) mustEmbedUnimplementedBankServiceServer() {}
 func (UnimplementedBankServiceServer) testEmbeddedByValue()                     {}
 
@@ -468,6 +2407,42 @@ func _BankService_GetBalance_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BankService_PostSettlement_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PostSettlementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankServiceServer).PostSettlement(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BankService_PostSettlement_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankServiceServer).PostSettlement(ctx, req.(*PostSettlementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BankService_Transfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BankServiceServer).Transfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BankService_Transfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BankServiceServer).Transfer(ctx, req.(*TransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BankService_ServiceDesc is the grpc.ServiceDesc for BankService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -491,6 +2466,14 @@ var BankService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetBalance",
 			Handler:    _BankService_GetBalance_Handler,
 		},
+		{
+			MethodName: "PostSettlement",
+			Handler:    _BankService_PostSettlement_Handler,
+		},
+		{
+			MethodName: "Transfer",
+			Handler:    _BankService_Transfer_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "protofiles/payment.proto",
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -16,6 +17,13 @@ type BankServer struct {
 	mu       sync.Mutex
 	bankName string
 	filename string // JSON file for persistence.
+
+	defaultCurrency string // Currency assigned to accounts that do not specify one.
+
+	settlementAccount  string          // Account holding the bank's inter-bank settlement position.
+	postedSettlements  map[string]bool // Settlement postings already applied, keyed by id, counterparty and side.
+	completedTransfers map[string]bool // Same-bank transfers already applied, keyed by idempotency key or transaction id.
+	committedPayments  map[string]bool // Two-phase commits already applied, keyed by transaction id, account and side.
 }
 
 // PreparePayment checks that the account exists and (if sender) has sufficient funds.
@@ -26,17 +34,26 @@ func (s *BankServer) PreparePayment(ctx context.Context, req *paymentpb.PrepareR
 	if !ok {
 		return &paymentpb.PrepareResponse{Vote: false, Message: "Account not found"}, nil
 	}
-	if acc.Balance < req.Amount {
+	if req.Currency != "" && req.Currency != acc.Currency {
+		return &paymentpb.PrepareResponse{Vote: false, Message: "Currency mismatch"}, nil
+	}
+	if !req.IsCredit && acc.Balance < req.Amount {
 		return &paymentpb.PrepareResponse{Vote: false, Message: "Insufficient funds"}, nil
 	}
 	log.Printf("Bank %s: Prepared transaction %s for account %s", s.bankName, req.TransactionId, req.Account)
 	return &paymentpb.PrepareResponse{Vote: true, Message: "Prepared successfully"}, nil
 }
 
-// CommitPayment applies the transaction and persists updated balances.
+// CommitPayment applies the transaction and persists updated balances. A commit already
+// applied for the same transaction, account and side succeeds without applying it again,
+// so a gateway retrying a commit whose answer it lost cannot move the money twice.
 func (s *BankServer) CommitPayment(ctx context.Context, req *paymentpb.CommitRequest) (*paymentpb.CommitResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s|%s|%t", req.TransactionId, req.Account, req.IsSender)
+	if s.committedPayments[key] {
+		return &paymentpb.CommitResponse{Success: true, Message: "Commit already applied"}, nil
+	}
 	acc, ok := s.accounts[req.Account]
 	if !ok {
 		return &paymentpb.CommitResponse{Success: false, Message: "Account not found"}, nil
@@ -51,12 +68,65 @@ func (s *BankServer) CommitPayment(ctx context.Context, req *paymentpb.CommitReq
 		acc.Balance += req.Amount
 		log.Printf("Bank %s: Committed transaction %s. Account %s new balance: %.2f (credited)", s.bankName, req.TransactionId, acc.Username, acc.Balance)
 	}
+	if s.committedPayments == nil {
+		s.committedPayments = make(map[string]bool)
+	}
+	s.committedPayments[key] = true
 	if err := s.persistAccounts(); err != nil {
 		log.Printf("Bank %s: Error persisting accounts: %v", s.bankName, err)
 	}
 	return &paymentpb.CommitResponse{Success: true, Message: "Commit successful"}, nil
 }
 
+// Transfer atomically moves funds between two accounts held at this bank. A transfer is
+// applied once per idempotency key, or per transaction id when it has no key.
+func (s *BankServer) Transfer(ctx context.Context, req *paymentpb.TransferRequest) (*paymentpb.TransferResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := req.IdempotencyKey
+	if key == "" {
+		key = req.TransactionId
+	}
+	if s.completedTransfers[key] {
+		return &paymentpb.TransferResponse{Success: true, Message: "Transfer already applied"}, nil
+	}
+	from, ok := s.accounts[req.FromAccount]
+	if !ok {
+		return &paymentpb.TransferResponse{Success: false, Message: "Sender account not found"}, nil
+	}
+	to, ok := s.accounts[req.ToAccount]
+	if !ok {
+		return &paymentpb.TransferResponse{Success: false, Message: "Receiver account not found"}, nil
+	}
+	var feeAcc *Account
+	if req.Fee > 0 {
+		feeAcc, ok = s.accounts[req.FeeAccount]
+		if !ok {
+			return &paymentpb.TransferResponse{Success: false, Message: "Fee account not found"}, nil
+		}
+		if feeAcc.Currency != from.Currency {
+			return &paymentpb.TransferResponse{Success: false, Message: "Fee account currency mismatch"}, nil
+		}
+	}
+	if from.Balance < req.Amount+req.Fee {
+		return &paymentpb.TransferResponse{Success: false, Message: "Insufficient funds"}, nil
+	}
+	from.Balance -= req.Amount + req.Fee
+	to.Balance += req.ToAmount
+	if feeAcc != nil {
+		feeAcc.Balance += req.Fee
+	}
+	if s.completedTransfers == nil {
+		s.completedTransfers = make(map[string]bool)
+	}
+	s.completedTransfers[key] = true
+	log.Printf("Bank %s: Transferred %.2f %s from %s to %s (%.2f %s) for transaction %s", s.bankName, req.Amount, from.Currency, from.Username, to.Username, req.ToAmount, to.Currency, req.TransactionId)
+	if err := s.persistAccounts(); err != nil {
+		log.Printf("Bank %s: Error persisting accounts: %v", s.bankName, err)
+	}
+	return &paymentpb.TransferResponse{Success: true, Message: "Transfer successful"}, nil
+}
+
 // AbortPayment handles a transaction abort with a configurable timeout.
 func (s *BankServer) AbortPayment(ctx context.Context, req *paymentpb.AbortRequest) (*paymentpb.AbortResponse, error) {
 	log.Printf("Bank %s: Initiating abort for transaction %s", s.bankName, req.TransactionId)
@@ -80,5 +150,48 @@ func (s *BankServer) GetBalance(ctx context.Context, req *paymentpb.GetBalanceRe
 		return nil, fmt.Errorf("account not found")
 	}
 	log.Printf("Bank %s: Returning balance for account %s: %.2f", s.bankName, req.Username, acc.Balance)
-	return &paymentpb.GetBalanceResponse{Balance: acc.Balance}, nil
-}
\ No newline at end of file
+	return &paymentpb.GetBalanceResponse{Balance: acc.Balance, Currency: acc.Currency}, nil
+}
+
+// settlementAccountFor returns the settlement account holding positions in the given currency.
+func (s *BankServer) settlementAccountFor(currency string) string {
+	if currency == "" || currency == s.defaultCurrency {
+		return s.settlementAccount
+	}
+	return s.settlementAccount + "_" + currency
+}
+
+// PostSettlement applies one side of a net inter-bank settlement to the bank's settlement
+// account. The account may go negative, as it tracks the bank's position against other banks.
+func (s *BankServer) PostSettlement(ctx context.Context, req *paymentpb.PostSettlementRequest) (*paymentpb.PostSettlementResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s|%s|%s|%t", req.SettlementId, req.CounterpartyBank, req.Currency, req.IsDebit)
+	if s.postedSettlements[key] {
+		return &paymentpb.PostSettlementResponse{Success: true, Message: "Settlement already posted"}, nil
+	}
+	name := s.settlementAccountFor(req.Currency)
+	acc, ok := s.accounts[name]
+	if !ok {
+		currency := req.Currency
+		if currency == "" {
+			currency = s.defaultCurrency
+		}
+		acc = &Account{Username: name, Currency: currency}
+		s.accounts[name] = acc
+	}
+	if req.IsDebit {
+		acc.Balance -= req.Amount
+	} else {
+		acc.Balance += req.Amount
+	}
+	if s.postedSettlements == nil {
+		s.postedSettlements = make(map[string]bool)
+	}
+	s.postedSettlements[key] = true
+	log.Printf("Bank %s: Posted settlement %s against %s (debit=%t, amount=%.2f %s). Settlement balance: %.2f", s.bankName, req.SettlementId, req.CounterpartyBank, req.IsDebit, req.Amount, acc.Currency, acc.Balance)
+	if err := s.persistAccounts(); err != nil {
+		log.Printf("Bank %s: Error persisting accounts: %v", s.bankName, err)
+	}
+	return &paymentpb.PostSettlementResponse{Success: true, Message: "Settlement posted"}, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthService is the health service name of the BankService service.
+const healthService = "payment.BankService"
+
+// startHealthChecks sets the serving status of the bank on hs now and then at the given
+// interval. The bank is serving while its accounts are loaded and the accounts file,
+// where every change is persisted, can still be read.
+func (s *BankServer) startHealthChecks(hs *health.Server, interval time.Duration) {
+	last := s.checkHealth(hs, "")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			last = s.checkHealth(hs, last)
+		}
+	}()
+}
+
+// checkHealth updates the statuses on hs and returns the problem found, if any. A change
+// from the previous problem is logged.
+func (s *BankServer) checkHealth(hs *health.Server, previous string) string {
+	problem := ""
+	if err := s.checkAccounts(); err != nil {
+		problem = err.Error()
+	}
+	st := healthpb.HealthCheckResponse_SERVING
+	if problem != "" {
+		st = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if problem != previous {
+		if problem == "" {
+			log.Printf("Bank %s is serving", s.bankName)
+		} else {
+			log.Printf("Bank %s not serving: %s", s.bankName, problem)
+		}
+	}
+	hs.SetServingStatus("", st)
+	hs.SetServingStatus(healthService, st)
+	return problem
+}
+
+// checkAccounts checks that the accounts are loaded and the accounts file is readable.
+func (s *BankServer) checkAccounts() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.accounts == nil {
+		return fmt.Errorf("accounts not loaded")
+	}
+	data, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return fmt.Errorf("accounts file unreadable: %v", err)
+	}
+	if _, err := parseAccountsFile(data); err != nil {
+		return fmt.Errorf("accounts file unreadable: %v", err)
+	}
+	return nil
+}
@@ -4,10 +4,12 @@ import (
 	"flag"
 	"log"
 	"net"
-	"os"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	paymentpb "github.com/jahnu05/Assignment-2/P-3/protofiles"
 )
 
@@ -16,28 +18,39 @@ var abortTimeout = 5 * time.Second
 
 func main() {
 	abortTimeoutFlag := flag.Duration("abort_timeout", 5*time.Second, "Timeout duration for aborting transactions")
+	settlementAccount := flag.String("settlement_account", "settlement", "Account used for inter-bank settlement postings")
+	defaultCurrency := flag.String("currency", "USD", "Currency of accounts that do not specify one")
+	healthInterval := flag.Duration("health_interval", 10*time.Second, "Interval between readiness checks reported by the health service")
+	enableReflection := flag.Bool("reflection", false, "Register the gRPC reflection service")
 	flag.Parse()
 	abortTimeout = *abortTimeoutFlag
 
-	if len(os.Args) < 3 {
-		log.Fatalf("Usage: bank_server [bankName] [accounts.json] [port(optional)]")
+	args := flag.Args()
+	if len(args) < 2 {
+		log.Fatalf("Usage: bank_server [flags] [bankName] [accounts.json] [port(optional)]")
 	}
-	bankName := os.Args[1]
-	accountsFile := os.Args[2]
+	bankName := args[0]
+	accountsFile := args[1]
 	port := ":50052"
-	if len(os.Args) >= 4 {
-		port = os.Args[3]
+	if len(args) >= 3 {
+		port = args[2]
 	}
 	lis, err := net.Listen("tcp", port)
 	if err != nil {
 		log.Fatalf("Failed to listen on %s: %v", port, err)
 	}
 	grpcServer := grpc.NewServer()
-	bankServer := &BankServer{bankName: bankName}
+	bankServer := &BankServer{bankName: bankName, settlementAccount: *settlementAccount, defaultCurrency: *defaultCurrency}
 	if err := bankServer.loadAccounts(accountsFile); err != nil {
 		log.Fatalf("Error loading accounts: %v", err)
 	}
 	paymentpb.RegisterBankServiceServer(grpcServer, bankServer)
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	bankServer.startHealthChecks(healthServer, *healthInterval)
+	if *enableReflection {
+		reflection.Register(grpcServer)
+	}
 	log.Printf("Bank server %s started on %s", bankName, port)
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("Failed to serve: %v", err)
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"sort"
 )
 
 // Account represents a user account.
@@ -10,6 +12,28 @@ type Account struct {
 	Username string  `json:"username"`
 	Password string  `json:"password"`
 	Balance  float64 `json:"balance"`
+	Currency string  `json:"currency"`
+}
+
+// accountsFile is the persisted state of a bank: its accounts and the settlement
+// postings, transfers and commits already applied, kept in one file so a posting and its balance
+// change are saved together. Older files hold just the array of accounts.
+type accountsFile struct {
+	Accounts           []Account `json:"accounts"`
+	PostedSettlements  []string  `json:"postedSettlements,omitempty"`
+	CompletedTransfers []string  `json:"completedTransfers,omitempty"`
+	CommittedPayments  []string  `json:"committedPayments,omitempty"`
+}
+
+// parseAccountsFile reads either file format.
+func parseAccountsFile(data []byte) (accountsFile, error) {
+	var f accountsFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(data, &f.Accounts)
+		return f, err
+	}
+	err := json.Unmarshal(data, &f)
+	return f, err
 }
 
 // loadAccounts loads accounts from a JSON file.
@@ -19,30 +43,61 @@ func (s *BankServer) loadAccounts(filename string) error {
 	if err != nil {
 		return err
 	}
-	var accs []Account
-	if err := json.Unmarshal(data, &accs); err != nil {
+	f, err := parseAccountsFile(data)
+	if err != nil {
 		return err
 	}
 	s.accounts = make(map[string]*Account)
-	for _, a := range accs {
+	for _, a := range f.Accounts {
+		currency := a.Currency
+		if currency == "" {
+			currency = s.defaultCurrency
+		}
 		s.accounts[a.Username] = &Account{
 			Username: a.Username,
 			Password: a.Password,
 			Balance:  a.Balance,
+			Currency: currency,
 		}
 	}
+	s.postedSettlements = make(map[string]bool)
+	for _, key := range f.PostedSettlements {
+		s.postedSettlements[key] = true
+	}
+	s.completedTransfers = make(map[string]bool)
+	for _, id := range f.CompletedTransfers {
+		s.completedTransfers[id] = true
+	}
+	s.committedPayments = make(map[string]bool)
+	for _, key := range f.CommittedPayments {
+		s.committedPayments[key] = true
+	}
 	return nil
 }
 
-// persistAccounts writes updated account data to the JSON file.
+// persistAccounts writes updated account data and the applied postings, transfers and
+// commits to the JSON file.
 func (s *BankServer) persistAccounts() error {
-	var accs []Account
+	var f accountsFile
 	for _, a := range s.accounts {
-		accs = append(accs, *a)
+		f.Accounts = append(f.Accounts, *a)
+	}
+	sort.Slice(f.Accounts, func(i, j int) bool { return f.Accounts[i].Username < f.Accounts[j].Username })
+	for key := range s.postedSettlements {
+		f.PostedSettlements = append(f.PostedSettlements, key)
 	}
-	data, err := json.MarshalIndent(accs, "", "  ")
+	sort.Strings(f.PostedSettlements)
+	for id := range s.completedTransfers {
+		f.CompletedTransfers = append(f.CompletedTransfers, id)
+	}
+	sort.Strings(f.CompletedTransfers)
+	for key := range s.committedPayments {
+		f.CommittedPayments = append(f.CommittedPayments, key)
+	}
+	sort.Strings(f.CommittedPayments)
+	data, err := json.MarshalIndent(f, "", "  ")
 	if err != nil {
 		return err
 	}
 	return ioutil.WriteFile(s.filename, data, 0644)
-}
\ No newline at end of file
+}
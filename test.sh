@@ -23,59 +23,59 @@ BANKB_PID=$!
 sleep 3
 
 echo "=== Registration: Register user 'alice' for BankA and 'bob' for BankB ==="
-./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt register localhost:50051 alice secretalice localhost:50052
+./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt --user alice --password secretalice register --bank localhost:50052
 sleep 2
-./client_file --cert=certs/bob.crt --key=certs/bob.key --ca=certs/ca.crt register localhost:50051 bob secretbob localhost:50053
+./client_file --cert=certs/bob.crt --key=certs/bob.key --ca=certs/ca.crt --user bob --password secretbob register --bank localhost:50053
 sleep 2
 echo "=== Payment Test: Correct credentials (alice pays bob 100.50) ==="
-./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 50
+./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 50
 # sleep 3
 # echo "=== Payment Test: Incorrect credentials (should be rejected and NOT queued) ==="
-# ./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt --senderPass=wrongpass pay localhost:50051 localhost:50052 localhost:50053 alice bob 20.00
+# ./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt --user alice --senderPass=wrongpass pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 20.00
 # sleep 3
 
 
 echo "=== Unregister Alice ==="
 echo  \n
-./client_file --senderPass=secretalice unregister localhost:50051 alice
+./client_file --user alice --senderPass=secretalice unregister
 # sleep 3
 
 echo "=== Make Payment(To be added to queue) ==="
 
-./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 30
+./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 30
 
 # sleep 3
 echo "=== register Alice ==="
 
-./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt register localhost:50051 alice secretalice localhost:50052
+./client_file --cert=certs/alice.crt --key=certs/alice.key --ca=certs/ca.crt --user alice --password secretalice register --bank localhost:50052
 echo "=== Make Payment(To be executed) ==="
 
-./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 15
+./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 15
 
 # # sleep 10
 # sleep 3
 echo "=== Unregister Bob ==="
 echo  \n
-./client_file --senderPass=secretalice unregister localhost:50051 bob
+./client_file --user bob --senderPass=secretbob unregister
 
 # sleep 3
 echo "=== Make Payment(To be added to queue) ==="
 
-./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 20
+./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 20
 
 
 echo "=== Make register bob ==="
 
-./client_file --cert=certs/bob.crt --key=certs/bob.key --ca=certs/ca.crt register localhost:50051 bob secretbob localhost:50053
+./client_file --cert=certs/bob.crt --key=certs/bob.key --ca=certs/ca.crt --user bob --password secretbob register --bank localhost:50053
 # sleep 3
 echo "=== Make Payment(To be added to queue) ==="
 
-./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 10
+./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 10
 
 
 
 # echo "=== Testing GetTransactionHistory (authorization): Alice querying her history ==="
-# ./client_file --senderPass=secretalice gethistory localhost:50051 alice
+# ./client_file --user alice --senderPass=secretalice gethistory
 # sleep 3
 
 # echo "=== Testing Offline Payments: Stopping BankB (receiver bank) ==="
@@ -83,7 +83,7 @@ echo "=== Make Payment(To be added to queue) ==="
 # sleep 3
 
 # echo "Attempting payment while BankB is offline (transaction will be queued)..."
-# ./client_file --senderPass=secretalice pay localhost:50051 localhost:50052 localhost:50053 alice bob 50
+# ./client_file --user alice --senderPass=secretalice pay --sender_bank localhost:50052 --receiver_bank localhost:50053 --receiver bob --amount 50
 # OFFLINE_CLIENT_PID=$!
 # sleep 5
 